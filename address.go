@@ -3,8 +3,6 @@ package fimpgo
 import (
 	"fmt"
 	"strings"
-
-	"github.com/pkg/errors"
 )
 
 const (
@@ -21,6 +19,15 @@ const (
 	ResourceTypeLocation  = "loc"
 )
 
+// MQTT wildcards , which can replace any address segment. Multi-level wildcard must be the last segment.
+const (
+	WildcardSingleLevel = "+"
+	WildcardMultiLevel  = "#"
+)
+
+// Address is FIMP topic [global prefix/]pt:<payload type>/mt:<msg type>/rt:<resource type>/rn:<resource name>/ad:<resource address>/sv:<service name>/ad:<service address>[/<extra segments>].
+// Resource and service parts are optional , segments following service address (for instance repeated ad or unknown keys) are kept in Extra.
+// Any segment can be replaced by wildcard , in that case the field is set to "+" or "#". Address is comparable , so it can be used as map key.
 type Address struct {
 	GlobalPrefix    string
 	PayloadType     string
@@ -30,87 +37,286 @@ type Address struct {
 	ResourceAddress string
 	ServiceName     string
 	ServiceAddress  string
+	Extra           string // extra segments separated by / , for instance "ad:2/ch:3"
+	// positional segments (bit per segment , pt to service address) of parsed topic , 0 if they are the same as for address created in code.
+	// It keeps missing optional segments missing , so parsed topic is serialized back into the same topic.
+	layout uint8
 }
 
+// AddressSegment is key:value segment of the address. Wildcard segment has empty key.
+type AddressSegment struct {
+	Key   string
+	Value string
+}
+
+// segment keys in the order they appear in the address
+const (
+	keyPayloadType     = "pt"
+	keyMsgType         = "mt"
+	keyResourceType    = "rt"
+	keyResourceName    = "rn"
+	keyResourceAddress = "ad"
+	keyServiceName     = "sv"
+	keyServiceAddress  = "ad"
+)
+
 func (adr *Address) Serialize() string {
 	if adr.PayloadType == "" {
 		adr.PayloadType = DefaultPayload
 	}
-	result := ""
-
-	switch adr.ResourceType {
-
-	case ResourceTypeAdapter, ResourceTypeApp, ResourceTypeCloud:
-		result = fmt.Sprintf("%s/%s/%s/%s/%s",
-			adr.prepComp("pt", adr.PayloadType),
-			adr.prepComp("mt", adr.MsgType),
-			adr.prepComp("rt", adr.ResourceType),
-			adr.prepComp("rn", adr.ResourceName),
-			adr.prepComp("ad", adr.ResourceAddress))
-	case ResourceTypeDevice:
-		result = fmt.Sprintf("%s/%s/%s/%s/%s/%s/%s",
-			adr.prepComp("pt", adr.PayloadType),
-			adr.prepComp("mt", adr.MsgType),
-			adr.prepComp("rt", adr.ResourceType),
-			adr.prepComp("rn", adr.ResourceName),
-			adr.prepComp("ad", adr.ResourceAddress),
-			adr.prepComp("sv", adr.ServiceName),
-			adr.prepComp("ad", adr.ServiceAddress))
-	case ResourceTypeDiscovery:
-		result = fmt.Sprintf("%s/%s/%s",
-			adr.prepComp("pt", adr.PayloadType),
-			adr.prepComp("mt", adr.MsgType),
-			adr.prepComp("rt", adr.ResourceType))
-	}
+	var sb strings.Builder
 	if adr.GlobalPrefix != "" {
-		result = adr.GlobalPrefix + "/" + result
+		sb.WriteString(adr.GlobalPrefix)
+		sb.WriteByte('/')
+	}
+	for i, seg := range adr.segments() {
+		if i > 0 {
+			sb.WriteByte('/')
+		}
+		sb.WriteString(adr.prepComp(seg.Key, seg.Value))
+		if seg.Value == WildcardMultiLevel {
+			return sb.String()
+		}
+	}
+	if adr.Extra != "" {
+		sb.WriteByte('/')
+		sb.WriteString(adr.Extra)
+	}
+	return sb.String()
+}
+
+// ExtraSegments returns extra segments of the address.
+func (adr *Address) ExtraSegments() []AddressSegment {
+	if adr.Extra == "" {
+		return nil
+	}
+	tokens := strings.Split(adr.Extra, "/")
+	segments := make([]AddressSegment, len(tokens))
+	for i, token := range tokens {
+		if isWildcard(token) {
+			segments[i] = AddressSegment{Value: token}
+			continue
+		}
+		segments[i].Key, segments[i].Value, _ = strings.Cut(token, ":")
+	}
+	return segments
+}
+
+// segments returns positional segments of the address , which are present in the topic.
+func (adr *Address) segments() []AddressSegment {
+	all := []AddressSegment{
+		{keyPayloadType, adr.PayloadType},
+		{keyMsgType, adr.MsgType},
+		{keyResourceType, adr.ResourceType},
+		{keyResourceName, adr.ResourceName},
+		{keyResourceAddress, adr.ResourceAddress},
+		{keyServiceName, adr.ServiceName},
+		{keyServiceAddress, adr.ServiceAddress},
+	}
+	layout := adr.positionalLayout()
+	segments := make([]AddressSegment, 0, len(all))
+	for i, seg := range all {
+		if layout&(1<<i) != 0 {
+			segments = append(segments, seg)
+		}
+	}
+	return segments
+}
+
+// bits of positional segments in the layout
+const (
+	layoutRequired        uint8 = 0x07 // pt , mt and rt
+	layoutResourceName    uint8 = 1 << 3
+	layoutResourceAddress uint8 = 1 << 4
+	layoutServiceName     uint8 = 1 << 5
+	layoutServiceAddress  uint8 = 1 << 6
+	layoutResource        uint8 = layoutRequired | layoutResourceName | layoutResourceAddress
+	layoutFull            uint8 = layoutResource | layoutServiceName | layoutServiceAddress
+)
+
+// positionalLayout returns positional segments , which are serialized before extra segments.
+func (adr *Address) positionalLayout() uint8 {
+	if adr.layout == 0 {
+		return adr.defaultLayout()
+	}
+	layout := adr.layout
+	// fields set after parsing extend the address up to the last set field
+	optional := []string{adr.ResourceName, adr.ResourceAddress, adr.ServiceName, adr.ServiceAddress}
+	for i := len(optional) - 1; i >= 0; i-- {
+		bit := uint8(1) << (i + 3)
+		if optional[i] != "" && layout&bit == 0 {
+			layout |= bit<<1 - 1
+			break
+		}
+	}
+	// extra segments can follow only complete resource or service part
+	if adr.Extra != "" {
+		if layout&layoutResourceName != 0 {
+			layout |= layoutResourceAddress
+		}
+		if layout&layoutServiceName != 0 {
+			layout |= layoutServiceAddress
+		}
+	}
+	return layout | layoutRequired
+}
+
+// defaultLayout returns positional segments of address created in code. Resource part is omitted only by discovery
+// addresses , service part is present if any of its fields or extra segments are set.
+func (adr *Address) defaultLayout() uint8 {
+	if adr.ServiceName != "" || adr.ServiceAddress != "" || adr.Extra != "" {
+		return layoutFull
+	}
+	if adr.ResourceType != ResourceTypeDiscovery || adr.ResourceName != "" || adr.ResourceAddress != "" {
+		return layoutResource
 	}
-	return result
+	return layoutRequired
 }
 
 func (adr *Address) prepComp(prefix string, comp string) string {
-	if comp == "+" || comp == "#" {
-		return fmt.Sprintf("%s", comp)
-	} else {
-		return fmt.Sprintf("%s:%s", prefix, comp)
+	if isWildcard(comp) {
+		return comp
+	}
+	return prefix + ":" + comp
+}
+
+// Validate checks that address can be serialized into valid MQTT topic and parsed back into the same address.
+func (adr *Address) Validate() error {
+	if strings.ContainsAny(adr.GlobalPrefix, "/:#") || (strings.Contains(adr.GlobalPrefix, "+") && adr.GlobalPrefix != WildcardSingleLevel) {
+		return fmt.Errorf("%w: global prefix %q", errInvalidAddress, adr.GlobalPrefix)
+	}
+	if adr.GlobalPrefix == WildcardSingleLevel && isWildcard(adr.PayloadType) {
+		return fmt.Errorf("%w: wildcard global prefix requires payload type", errInvalidAddress)
+	}
+	payloadType := adr.PayloadType
+	if payloadType == "" {
+		payloadType = DefaultPayload
+	}
+	segments := adr.segments()
+	segments[0].Value = payloadType
+	for i, seg := range segments {
+		if seg.Value == WildcardMultiLevel {
+			// segments following multi-level wildcard are not serialized
+			for _, next := range segments[i+1:] {
+				if next.Value != "" {
+					return fmt.Errorf("%w: %s must be the last segment", errInvalidAddress, WildcardMultiLevel)
+				}
+			}
+			if adr.Extra != "" {
+				return fmt.Errorf("%w: %s must be the last segment", errInvalidAddress, WildcardMultiLevel)
+			}
+			break
+		}
+		if seg.Value == "" && (seg.Key == keyMsgType || seg.Key == keyResourceType) {
+			return fmt.Errorf("%w: segment %s is required", errInvalidAddress, seg.Key)
+		}
+		if strings.Contains(seg.Value, "/") || (strings.ContainsAny(seg.Value, "+#") && !isWildcard(seg.Value)) {
+			return fmt.Errorf("%w: segment %s has invalid value %q", errInvalidAddress, seg.Key, seg.Value)
+		}
+	}
+	if adr.Extra == "" {
+		return nil
+	}
+	tokens := strings.Split(adr.Extra, "/")
+	for i, token := range tokens {
+		if isWildcard(token) {
+			if token == WildcardMultiLevel && i != len(tokens)-1 {
+				return fmt.Errorf("%w: %s must be the last segment", errInvalidAddress, WildcardMultiLevel)
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || key == "" || strings.ContainsAny(key, "+#") || strings.ContainsAny(value, "+#") {
+			return fmt.Errorf("%w: extra segment %q", errInvalidAddress, token)
+		}
 	}
+	return nil
 }
 
+// NewAddressFromString parses FIMP topic. Segments are validated by position , wildcard segment sets the field at its position to the wildcard.
 func NewAddressFromString(address string) (*Address, error) {
 	adr := Address{}
 	tokens := strings.Split(address, "/")
-	var err error
-	for index, _ := range tokens {
-		keyVal := strings.Split(tokens[index], ":")
-		// detecting global prefix
-		if len(keyVal) == 1 && index == 0 {
-			adr.GlobalPrefix = keyVal[0]
-		} else if len(keyVal) == 2 {
-			switch keyVal[0] {
-			case "pt":
-				adr.PayloadType = keyVal[1]
-			case "mt":
-				adr.MsgType = keyVal[1]
-			case "rt":
-				adr.ResourceType = keyVal[1]
-			case "rn":
-				adr.ResourceName = keyVal[1]
-			case "ad":
-				if adr.ServiceName == "" {
-					adr.ResourceAddress = keyVal[1]
-				} else {
-					adr.ServiceAddress = keyVal[1]
-				}
 
-			case "sv":
-				adr.ServiceName = keyVal[1]
+	// detecting global prefix , wildcard is prefix only if followed by payload type
+	if len(tokens) > 1 && !strings.Contains(tokens[0], ":") {
+		if !isWildcard(tokens[0]) || strings.HasPrefix(tokens[1], keyPayloadType+":") {
+			adr.GlobalPrefix = tokens[0]
+			tokens = tokens[1:]
+		}
+	}
+
+	fields := []struct {
+		key      string
+		value    *string
+		optional bool // start of optional part , which is skipped if key doesn't match
+	}{
+		{keyPayloadType, &adr.PayloadType, false},
+		{keyMsgType, &adr.MsgType, false},
+		{keyResourceType, &adr.ResourceType, false},
+		{keyResourceName, &adr.ResourceName, true},
+		{keyResourceAddress, &adr.ResourceAddress, false},
+		{keyServiceName, &adr.ServiceName, true},
+		{keyServiceAddress, &adr.ServiceAddress, false},
+	}
+
+	var extra []string
+	var layout uint8
+	pos, lastPos := 0, 0
+	for i, token := range tokens {
+		if token == WildcardMultiLevel && i != len(tokens)-1 {
+			return nil, fmt.Errorf("%w: %s must be the last segment of %s", errInvalidAddress, WildcardMultiLevel, address)
+		}
+		key, value := "", token
+		if !isWildcard(token) {
+			var ok bool
+			if key, value, ok = strings.Cut(token, ":"); !ok || key == "" {
+				return nil, fmt.Errorf("%w: incorrectly formatted segment %q of %s", errInvalidAddress, token, address)
+			}
+		}
 
+		if pos < len(fields) && key != "" && key != fields[pos].key {
+			switch {
+			case !fields[pos].optional:
+				return nil, fmt.Errorf("%w: expected %s segment , got %q in %s", errInvalidAddress, fields[pos].key, token, address)
+			// ad and sv are recognized by key , so resource name or the whole resource part can be missing
+			case fields[pos].key == keyResourceName && key == keyResourceAddress:
+				pos++
+			case fields[pos].key == keyResourceName && key == keyServiceName:
+				pos += 2
+			default:
+				// remaining segments are extra
+				pos = len(fields)
 			}
+		}
+		if pos < len(fields) {
+			*fields[pos].value = value
+			layout |= 1 << pos
+			lastPos = pos
+			pos++
 		} else {
-			return nil, errors.New("Incorrectly formatted address")
+			extra = append(extra, token)
 		}
 	}
+	adr.Extra = strings.Join(extra, "/")
+	if adr.PayloadType == "" {
+		return nil, fmt.Errorf("%w: empty payload type in %s", errInvalidAddress, address)
+	}
+	// segments following multi-level wildcard are never serialized , so they don't matter
+	compared := ^uint8(0)
+	if *fields[lastPos].value == WildcardMultiLevel {
+		compared = uint8(1)<<(lastPos+1) - 1
+	}
+	if layout&compared != adr.defaultLayout()&compared {
+		adr.layout = layout
+	}
 
-	return &adr, err
+	if err := adr.Validate(); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, address)
+	}
+	return &adr, nil
+}
 
+func isWildcard(value string) bool {
+	return value == WildcardSingleLevel || value == WildcardMultiLevel
 }
@@ -0,0 +1,177 @@
+package fimpgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AddressPattern matches FIMP addresses. Fields which are not set match any value.
+// Pattern compiles into MQTT topic filter used for subscription and matches parsed addresses without splitting topics , for instance :
+//
+//	fimpgo.Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp")
+type AddressPattern struct {
+	addr Address
+	err  error
+}
+
+// Match creates pattern , which matches all addresses.
+func Match() *AddressPattern {
+	return &AddressPattern{}
+}
+
+// PayloadType sets payload type , by default pattern matches all payload types.
+func (p *AddressPattern) PayloadType(payloadType string) *AddressPattern {
+	return p.set(&p.addr.PayloadType, payloadType)
+}
+
+// MsgType sets message type (mt).
+func (p *AddressPattern) MsgType(msgType string) *AddressPattern {
+	return p.set(&p.addr.MsgType, msgType)
+}
+
+func (p *AddressPattern) Cmd() *AddressPattern {
+	return p.MsgType(MsgTypeCmd)
+}
+
+func (p *AddressPattern) Evt() *AddressPattern {
+	return p.MsgType(MsgTypeEvt)
+}
+
+func (p *AddressPattern) Rsp() *AddressPattern {
+	return p.MsgType(MsgTypeRsp)
+}
+
+// ResourceType sets resource type (rt).
+func (p *AddressPattern) ResourceType(resourceType string) *AddressPattern {
+	return p.set(&p.addr.ResourceType, resourceType)
+}
+
+func (p *AddressPattern) Dev() *AddressPattern {
+	return p.ResourceType(ResourceTypeDevice)
+}
+
+func (p *AddressPattern) Location() *AddressPattern {
+	return p.ResourceType(ResourceTypeLocation)
+}
+
+func (p *AddressPattern) Discovery() *AddressPattern {
+	return p.ResourceType(ResourceTypeDiscovery)
+}
+
+// App sets application resource type and application name.
+func (p *AddressPattern) App(name string) *AddressPattern {
+	return p.ResourceType(ResourceTypeApp).ResourceName(name)
+}
+
+// Cloud sets cloud resource type and cloud service name.
+func (p *AddressPattern) Cloud(name string) *AddressPattern {
+	return p.ResourceType(ResourceTypeCloud).ResourceName(name)
+}
+
+// Adapter sets adapter name , which is resource name of both adapter and device addresses.
+func (p *AddressPattern) Adapter(name string) *AddressPattern {
+	return p.ResourceName(name)
+}
+
+// ResourceName sets resource name (rn).
+func (p *AddressPattern) ResourceName(name string) *AddressPattern {
+	return p.set(&p.addr.ResourceName, name)
+}
+
+// ResourceAddress sets resource address , for devices it's address of the device within adapter.
+func (p *AddressPattern) ResourceAddress(address string) *AddressPattern {
+	return p.set(&p.addr.ResourceAddress, address)
+}
+
+// Service sets service name (sv).
+func (p *AddressPattern) Service(name string) *AddressPattern {
+	return p.set(&p.addr.ServiceName, name)
+}
+
+// ServiceAddress sets address of the service.
+func (p *AddressPattern) ServiceAddress(address string) *AddressPattern {
+	return p.set(&p.addr.ServiceAddress, address)
+}
+
+// Topic returns MQTT topic filter. Fields which are not set become + , fields following the last set field are replaced by # ,
+// so the filter matches also addresses with extra segments.
+func (p *AddressPattern) Topic() string {
+	if p.err != nil {
+		return ""
+	}
+	segments := []AddressSegment{
+		{keyPayloadType, p.addr.PayloadType},
+		{keyMsgType, p.addr.MsgType},
+		{keyResourceType, p.addr.ResourceType},
+		{keyResourceName, p.addr.ResourceName},
+		{keyResourceAddress, p.addr.ResourceAddress},
+		{keyServiceName, p.addr.ServiceName},
+		{keyServiceAddress, p.addr.ServiceAddress},
+	}
+	var sb strings.Builder
+	for _, seg := range segments[:p.lastSetField()+1] {
+		if seg.Value == "" {
+			sb.WriteString(WildcardSingleLevel)
+		} else {
+			sb.WriteString(p.addr.prepComp(seg.Key, seg.Value))
+		}
+		sb.WriteByte('/')
+	}
+	sb.WriteString(WildcardMultiLevel)
+	return sb.String()
+}
+
+func (p *AddressPattern) String() string {
+	return p.Topic()
+}
+
+// Matches returns true if address matches the pattern. Global prefix of the address is ignored.
+func (p *AddressPattern) Matches(addr *Address) bool {
+	if addr == nil || p.err != nil {
+		return false
+	}
+	return patternMatches(p.addr.PayloadType, addr.PayloadType) &&
+		patternMatches(p.addr.MsgType, addr.MsgType) &&
+		patternMatches(p.addr.ResourceType, addr.ResourceType) &&
+		patternMatches(p.addr.ResourceName, addr.ResourceName) &&
+		patternMatches(p.addr.ResourceAddress, addr.ResourceAddress) &&
+		patternMatches(p.addr.ServiceName, addr.ServiceName) &&
+		patternMatches(p.addr.ServiceAddress, addr.ServiceAddress)
+}
+
+// lastSetField returns index of the last field , which is set , or -1 if pattern matches all addresses.
+func (p *AddressPattern) lastSetField() int {
+	fields := []string{p.addr.PayloadType, p.addr.MsgType, p.addr.ResourceType, p.addr.ResourceName, p.addr.ResourceAddress, p.addr.ServiceName, p.addr.ServiceAddress}
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i] != "" {
+			return i
+		}
+	}
+	return -1
+}
+
+// set sets pattern field , wildcard makes the field not set. Values containing topic separator or wildcard characters can't be
+// matched by MQTT topic filter , such value makes the pattern invalid.
+func (p *AddressPattern) set(field *string, value string) *AddressPattern {
+	if isWildcard(value) {
+		*field = ""
+		return p
+	}
+	if strings.ContainsAny(value, "/+#") {
+		if p.err == nil {
+			p.err = fmt.Errorf("%w: pattern value %q", errInvalidAddress, value)
+		}
+		return p
+	}
+	*field = value
+	return p
+}
+
+// Err returns error if any of pattern values is invalid. Invalid pattern has empty topic filter and doesn't match any address.
+func (p *AddressPattern) Err() error {
+	return p.err
+}
+
+func patternMatches(pattern, value string) bool {
+	return pattern == "" || pattern == value
+}
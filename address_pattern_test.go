@@ -0,0 +1,144 @@
+package fimpgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo/utils"
+)
+
+func TestAddressPattern_Topic(t *testing.T) {
+	tests := []struct {
+		pattern *AddressPattern
+		topic   string
+	}{
+		{Match(), "#"},
+		{Match().Evt(), "+/mt:evt/#"},
+		{Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp"), "+/mt:evt/rt:dev/rn:zigbee/+/sv:sensor_temp/#"},
+		{Match().PayloadType(DefaultPayload).Cmd().App("vinculum").ResourceAddress("1"), "pt:j1/mt:cmd/rt:app/rn:vinculum/ad:1/#"},
+		{Match().Rsp().Cloud("backend"), "+/mt:rsp/rt:cloud/rn:backend/#"},
+		{Match().Evt().Location().ResourceName("+"), "+/mt:evt/rt:loc/#"},
+		{Match().Discovery(), "+/+/rt:discovery/#"},
+		{Match().Service("out_bin_switch").ServiceAddress("1:2"), "+/+/+/+/+/sv:out_bin_switch/ad:1:2/#"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.topic, tt.pattern.Topic())
+		assert.Equal(t, tt.topic, tt.pattern.String())
+	}
+}
+
+func TestAddressPattern_Matches(t *testing.T) {
+	patterns := []*AddressPattern{
+		Match(),
+		Match().Evt(),
+		Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp"),
+		Match().PayloadType(DefaultPayload).Cmd().Dev().ResourceAddress("1"),
+		Match().Cmd().App("vinculum"),
+		Match().Service("sensor_temp").ServiceAddress("1_1"),
+		Match().Evt().Adapter("zigbee"),
+	}
+	topics := []string{
+		"pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1",
+		"pt:j1c1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1",
+		"pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1",
+		"pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1_1",
+		"pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1/ad:2",
+		"pt:j1/mt:evt/rt:ad/rn:zigbee/ad:1",
+		"pt:j1/mt:cmd/rt:app/rn:vinculum/ad:1",
+		"pt:j1/mt:evt/rt:discovery",
+	}
+	for _, p := range patterns {
+		for _, topic := range topics {
+			addr, err := NewAddressFromString(topic)
+			require.NoError(t, err)
+			// matcher must accept exactly the same addresses as topic filter
+			assert.Equal(t, utils.RouteIncludesTopic(p.Topic(), topic), p.Matches(addr), "%s %s", p, topic)
+		}
+	}
+
+	p := Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp")
+	addr, _ := NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1")
+	assert.True(t, p.Matches(addr))
+	addr, _ = NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_presence/ad:1_1")
+	assert.False(t, p.Matches(addr))
+	assert.False(t, p.Matches(nil))
+}
+
+func TestAddressPattern_InvalidValue(t *testing.T) {
+	for _, p := range []*AddressPattern{
+		Match().Evt().Service("a/b"),
+		Match().Adapter("z+"),
+		Match().Dev().ResourceAddress("1#").Service("sensor_temp"),
+	} {
+		assert.True(t, IsInvalidAddress(p.Err()), p.Topic())
+		assert.Empty(t, p.Topic())
+		assert.False(t, p.Matches(&Address{PayloadType: DefaultPayload, MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "z+", ServiceName: "a/b"}))
+	}
+	assert.NoError(t, Match().Evt().Adapter("+").Service("#").Err(), "wildcard makes the field not set")
+
+	mh := NewMqttTransportFromConnection(&recordingClient{}, 1, 1)
+	assert.True(t, IsInvalidAddress(mh.SubscribePattern(Match().Adapter("z+"))))
+	assert.True(t, IsInvalidAddress(mh.UnsubscribePattern(Match().Adapter("z+"))))
+
+	ch := make(MessageCh, 1)
+	mh.RegisterChannelWithPattern("test", ch, Match().Service("a/b"))
+	addr, err := NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:a/ad:b")
+	require.NoError(t, err)
+	assert.False(t, mh.isChannelInterested("test", addr.Serialize(), addr, nil), "invalid pattern must not match any message")
+}
+
+func TestMqttTransport_PatternFilter(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{})
+	defer mh.stopWorkers()
+
+	ch := make(MessageCh, 10)
+	mh.RegisterChannelWithPattern("test", ch, Match().Evt().Dev().Adapter("zigbee"))
+
+	for _, m := range []struct {
+		topic string
+		msg   *FimpMessage
+	}{
+		{"pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1", NewFloatMessage("evt.sensor.report", "sensor_temp", 20, nil, nil, nil)},
+		{"pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1", NewNullMessage("cmd.sensor.get_report", "sensor_temp", nil, nil, nil)},
+		{"pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1", NewFloatMessage("evt.sensor.report", "sensor_temp", 22, nil, nil, nil)},
+	} {
+		payload, err := m.msg.SerializeToJson()
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: m.topic, payload: payload})
+	}
+
+	select {
+	case msg := <-ch:
+		assert.Equal(t, 22.0, msg.Payload.Value)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message %v", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func BenchmarkAddressPattern_Matches(b *testing.B) {
+	p := Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp")
+	topic := "pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1"
+	addr, _ := NewAddressFromString(topic)
+	filter := p.Topic()
+
+	b.Run("pattern", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			p.Matches(addr)
+		}
+	})
+	b.Run("route", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			utils.RouteIncludesTopic(filter, topic)
+		}
+	})
+}
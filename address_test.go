@@ -1,6 +1,15 @@
 package fimpgo
 
-import "testing"
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 func TestNewAddressFromStringDevice(t *testing.T) {
 	addrString := "pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_presence/ad:16"
@@ -99,3 +108,286 @@ func TestAddress_SerializeWithGlobalPrefix(t *testing.T) {
 	}
 	t.Log(adrStr)
 }
+
+func TestNewAddressFromString_Grammar(t *testing.T) {
+	tests := []struct {
+		topic string
+		addr  Address
+	}{
+		{
+			topic: "pt:j1/mt:evt/rt:loc/rn:vinculum/ad:1",
+			addr:  Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeLocation, ResourceName: "vinculum", ResourceAddress: "1"},
+		},
+		{
+			topic: "pt:j1/mt:cmd/rt:cloud/rn:backend-service/ad:remote-client",
+			addr:  Address{PayloadType: "j1", MsgType: MsgTypeCmd, ResourceType: ResourceTypeCloud, ResourceName: "backend-service", ResourceAddress: "remote-client"},
+		},
+		{
+			topic: "pt:j1/mt:evt/rt:custom/rn:test/ad:1/sv:out_bin_switch/ad:1",
+			addr:  Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: "custom", ResourceName: "test", ResourceAddress: "1", ServiceName: "out_bin_switch", ServiceAddress: "1"},
+		},
+		{
+			topic: "pt:j1/mt:evt/rt:discovery",
+			addr:  Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeDiscovery},
+		},
+		{
+			topic: "pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1/ad:2/ad:3",
+			addr: Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "zigbee", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1_1",
+				Extra: "ad:2/ad:3"},
+		},
+		{
+			topic: "pt:j1/mt:evt/rt:dev/rn:zw/ad:00:1a:22/sv:meter_elec/ad:1:2/ch:3",
+			addr: Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "00:1a:22", ServiceName: "meter_elec", ServiceAddress: "1:2",
+				Extra: "ch:3"},
+		},
+		{
+			topic: "+/pt:j1/mt:evt/rt:dev/+/+/sv:sensor_temp/+",
+			addr:  Address{GlobalPrefix: "+", PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "+", ResourceAddress: "+", ServiceName: "sensor_temp", ServiceAddress: "+"},
+		},
+		{
+			topic: "+/mt:evt/rt:ad/rn:zigbee/#",
+			addr:  Address{PayloadType: "+", MsgType: MsgTypeEvt, ResourceType: ResourceTypeAdapter, ResourceName: "zigbee", ResourceAddress: "#"},
+		},
+		{
+			topic: "pt:j1/mt:evt/#",
+			addr:  Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: "#"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			addr, err := NewAddressFromString(tt.topic)
+			require.NoError(t, err)
+			assert.Equal(t, tt.addr, *addr)
+			assert.Equal(t, tt.topic, addr.Serialize())
+		})
+	}
+}
+
+func TestNewAddressFromString_Lenient(t *testing.T) {
+	// unknown keys in place of optional parts are kept as extra segments
+	addr, err := NewAddressFromString("pt:j1/mt:evt/rt:app/rn:test/ad:1/ver:2")
+	require.NoError(t, err)
+	assert.Equal(t, "", addr.ServiceName)
+	assert.Equal(t, "ver:2", addr.Extra)
+	assert.Equal(t, []AddressSegment{{Key: "ver", Value: "2"}}, addr.ExtraSegments())
+	assert.Equal(t, "pt:j1/mt:evt/rt:app/rn:test/ad:1/ver:2", addr.Serialize())
+
+	// missing optional parts stay missing , unless fields are set after parsing
+	for _, topic := range []string{
+		"pt:j1/mt:evt/rt:discovery/ver:2",
+		"pt:j1/mt:evt/rt:dev",
+		"pt:j1/mt:evt/rt:dev/rn:zw",
+		"pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp",
+		"pt:j1/mt:evt/rt:discovery/rn:/ad:",
+	} {
+		addr, err = NewAddressFromString(topic)
+		require.NoError(t, err)
+		assert.Equal(t, topic, addr.Serialize())
+	}
+	addr, err = NewAddressFromString("pt:j1/mt:evt/rt:app/rn:test/ad:1/ver:2")
+	require.NoError(t, err)
+	addr.ServiceName = "out_bin_switch"
+	assert.Equal(t, "pt:j1/mt:evt/rt:app/rn:test/ad:1/sv:out_bin_switch/ad:/ver:2", addr.Serialize())
+	addr, err = NewAddressFromString("pt:j1/mt:evt/rt:dev")
+	require.NoError(t, err)
+	addr.ResourceName = "zw"
+	assert.Equal(t, "pt:j1/mt:evt/rt:dev/rn:zw", addr.Serialize())
+}
+
+func TestNewAddressFromString_MissingResourceName(t *testing.T) {
+	// ad and sv are recognized by key , as by parser of previous versions
+	addr, err := NewAddressFromString("pt:j1/mt:evt/rt:dev/ad:1")
+	require.NoError(t, err)
+	assert.Equal(t, "", addr.ResourceName)
+	assert.Equal(t, "1", addr.ResourceAddress)
+	assert.Equal(t, "", addr.Extra)
+	assert.Equal(t, "pt:j1/mt:evt/rt:dev/ad:1", addr.Serialize())
+
+	addr, err = NewAddressFromString("pt:j1/mt:evt/rt:dev/sv:sensor_temp/ad:1")
+	require.NoError(t, err)
+	assert.Equal(t, "", addr.ResourceAddress)
+	assert.Equal(t, "sensor_temp", addr.ServiceName)
+	assert.Equal(t, "1", addr.ServiceAddress)
+	assert.Equal(t, "", addr.Extra)
+	assert.Equal(t, "pt:j1/mt:evt/rt:dev/sv:sensor_temp/ad:1", addr.Serialize())
+}
+
+func TestAddress_Comparable(t *testing.T) {
+	parsed, err := NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1/ch:2")
+	require.NoError(t, err)
+	addr := Address{PayloadType: "j1", MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1", Extra: "ch:2"}
+	assert.True(t, addr == *parsed)
+
+	seen := map[Address]int{addr: 1}
+	assert.Equal(t, 1, seen[*parsed])
+}
+
+func TestNewAddressFromString_Invalid(t *testing.T) {
+	for _, topic := range []string{
+		"",
+		"pt:j1",
+		"mt:evt/pt:j1/rt:dev",
+		"pt:j1/mt:evt/rt:dev/rn:zw/sv:sensor_temp",
+		"pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/rn:1",
+		"pt:j1/mt:evt/#/rn:zw",
+		"pt:j1/mt:evt/rt:dev/rn:z+w/ad:1",
+		"pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1/invalid",
+		"prefix/other/pt:j1/mt:evt/rt:dev",
+		"pt:j1/:evt/rt:dev",
+	} {
+		_, err := NewAddressFromString(topic)
+		assert.True(t, IsInvalidAddress(err), topic)
+	}
+}
+
+func TestAddress_Validate(t *testing.T) {
+	valid := Address{MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1"}
+	assert.NoError(t, valid.Validate())
+
+	for name, modify := range map[string]func(a *Address){
+		"missing msg type":      func(a *Address) { a.MsgType = "" },
+		"missing resource type": func(a *Address) { a.ResourceType = "" },
+		"slash in value":        func(a *Address) { a.ResourceName = "z/w" },
+		"partial wildcard":      func(a *Address) { a.ServiceName = "sensor_#" },
+		"segment after #":       func(a *Address) { a.ResourceName = "#" },
+		"extra after #":         func(a *Address) { a.ServiceAddress = "#"; a.Extra = "ad:2" },
+		"prefix with colon":     func(a *Address) { a.GlobalPrefix = "pt:j1" },
+		"prefix and pt wildcard": func(a *Address) {
+			a.GlobalPrefix = "+"
+			a.PayloadType = "+"
+		},
+		"extra without key":     func(a *Address) { a.Extra = ":2" },
+		"extra without colon":   func(a *Address) { a.Extra = "ad:2/3" },
+		"extra wildcard key":    func(a *Address) { a.Extra = "ad:+" },
+		"empty extra segment":   func(a *Address) { a.Extra = "ad:2//ch:3" },
+		"extra # in the middle": func(a *Address) { a.Extra = "#/ch:3" },
+	} {
+		addr := valid
+		modify(&addr)
+		assert.True(t, IsInvalidAddress(addr.Validate()), name)
+	}
+}
+
+// validAddress generates random valid addresses for property based tests.
+type validAddress Address
+
+func (validAddress) Generate(r *rand.Rand, _ int) reflect.Value {
+	value := func(wildcards bool) string {
+		values := []string{"", "1", "zigbee", "1:2", "00:1a:22", "sensor_temp", "a b", "ü"}
+		if wildcards {
+			values = append(values, WildcardSingleLevel)
+		}
+		return values[r.Intn(len(values))]
+	}
+	required := func(values ...string) string {
+		if r.Intn(5) == 0 {
+			return WildcardSingleLevel
+		}
+		return values[r.Intn(len(values))]
+	}
+
+	for {
+		addr := Address{
+			PayloadType:     required(DefaultPayload, CompressedJsonPayload, CBORPayload),
+			MsgType:         required(MsgTypeCmd, MsgTypeEvt, MsgTypeRsp),
+			ResourceType:    required(ResourceTypeDevice, ResourceTypeApp, ResourceTypeAdapter, ResourceTypeCloud, ResourceTypeDiscovery, ResourceTypeLocation, "custom"),
+			ResourceName:    value(true),
+			ResourceAddress: value(true),
+			ServiceName:     value(true),
+			ServiceAddress:  value(true),
+		}
+		if r.Intn(3) == 0 {
+			addr.GlobalPrefix = []string{"+", "BDNF123", "hub-1"}[r.Intn(3)]
+		}
+		var extra []string
+		for i := r.Intn(4); i > 0; i-- {
+			if r.Intn(4) == 0 {
+				extra = append(extra, WildcardSingleLevel)
+			} else {
+				extra = append(extra, []string{"ad", "ch", "x"}[r.Intn(3)]+":"+value(false))
+			}
+		}
+		addr.Extra = strings.Join(extra, "/")
+		// terminate address with multi-level wildcard at random segment
+		if r.Intn(4) == 0 {
+			fields := []*string{&addr.ResourceName, &addr.ResourceAddress, &addr.ServiceName, &addr.ServiceAddress}
+			i := r.Intn(len(fields))
+			*fields[i] = WildcardMultiLevel
+			for _, f := range fields[i+1:] {
+				*f = ""
+			}
+			addr.Extra = ""
+		}
+		if addr.Validate() == nil {
+			return reflect.ValueOf(validAddress(addr))
+		}
+	}
+}
+
+func TestAddress_RoundTrip(t *testing.T) {
+	roundTrip := func(generated validAddress) bool {
+		addr := Address(generated)
+		parsed, err := NewAddressFromString(addr.Serialize())
+		if err != nil {
+			t.Log(addr.Serialize(), err)
+			return false
+		}
+		return reflect.DeepEqual(addr, *parsed)
+	}
+	require.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 5000}))
+}
+
+// fimpTopic generates random topics , most of them following FIMP grammar , for property based tests.
+type fimpTopic string
+
+func (fimpTopic) Generate(r *rand.Rand, _ int) reflect.Value {
+	keys := []string{keyPayloadType, keyMsgType, keyResourceType, keyResourceName, keyResourceAddress, keyServiceName, keyServiceAddress}
+	values := []string{"", "j1", "evt", "dev", ResourceTypeDiscovery, "zw", "1", "1:2", "a b"}
+	token := func(i int) string {
+		switch r.Intn(8) {
+		case 0:
+			return WildcardSingleLevel
+		case 1:
+			return WildcardMultiLevel
+		case 2:
+			return []string{"ad", "ch", "x", ""}[r.Intn(4)] + ":" + values[r.Intn(len(values))]
+		}
+		if i >= len(keys) {
+			i = r.Intn(len(keys))
+		}
+		return keys[i] + ":" + values[r.Intn(len(values))]
+	}
+
+	var tokens []string
+	if r.Intn(4) == 0 {
+		tokens = append(tokens, []string{"+", "#", "hub-1"}[r.Intn(3)])
+	}
+	pos := 0
+	for n := r.Intn(10); n > 0; n-- {
+		// skipping optional parts
+		if (pos == 3 || pos == 5) && r.Intn(3) == 0 {
+			pos += 2
+		}
+		tokens = append(tokens, token(pos))
+		pos++
+	}
+	return reflect.ValueOf(fimpTopic(strings.Join(tokens, "/")))
+}
+
+func TestAddress_TopicRoundTrip(t *testing.T) {
+	accepted := 0
+	roundTrip := func(topic fimpTopic) bool {
+		addr, err := NewAddressFromString(string(topic))
+		if err != nil {
+			return true
+		}
+		accepted++
+		if addr.Serialize() != string(topic) {
+			t.Log(topic, " -> ", addr.Serialize())
+			return false
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(roundTrip, &quick.Config{MaxCount: 20000}))
+	assert.Greater(t, accepted, 1000)
+}
@@ -2,62 +2,291 @@ package fimpgo
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"sync"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
 	log "github.com/sirupsen/logrus"
 )
 
+// Compression algorithms supported by MsgCompressor.
+const (
+	CompressionGzip  = "gzip"
+	CompressionZstd  = "zstd"
+	CompressionFlate = "flate"
+	CompressionLz4   = "lz4"
+)
+
+// Compression levels , numeric levels of the algorithm are accepted as well.
+const (
+	CompressionLevelFastest = "fastest"
+	CompressionLevelDefault = "default"
+	CompressionLevelBest    = "best"
+)
+
+// Payload types of compressed JSON , algorithm is negotiated by the topic.
+const (
+	ZstdJsonPayload  = "j1c2"
+	FlateJsonPayload = "j1c3"
+	Lz4JsonPayload   = "j1c4"
+)
+
+var errUnsupportedDictionary = errors.New("dictionary is not supported")
+
+// CompressionPayloadType returns payload type of JSON compressed with the algorithm.
+func CompressionPayloadType(alg string) string {
+	switch alg {
+	case CompressionGzip, "":
+		return CompressedJsonPayload
+	case CompressionZstd:
+		return ZstdJsonPayload
+	case CompressionFlate:
+		return FlateJsonPayload
+	case CompressionLz4:
+		return Lz4JsonPayload
+	}
+	return ""
+}
+
+type (
+	// CompressorOption configures MsgCompressor.
+	CompressorOption interface {
+		apply(*MsgCompressor)
+	}
+
+	dictionaryOption []byte
+)
+
+func (o dictionaryOption) apply(c *MsgCompressor) {
+	c.dictionary = o
+}
+
+// WithDictionary sets shared dictionary , which improves compression of small messages. It's supported by zstd and flate.
+// Both sides must use the same dictionary , so it's recommended to use dedicated payload type for it.
+func WithDictionary(dict []byte) CompressorOption {
+	return dictionaryOption(dict)
+}
+
+// streamWriter is a compressing writer , which can be reused.
+type streamWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
 type MsgCompressor struct {
-	compressor         *gzip.Writer
-	decompressor       *gzip.Reader
-	compressionBuffer  bytes.Buffer
-	decompressorBuffer bytes.Buffer
-	mux                sync.Mutex
+	alg        string
+	dictionary []byte
+	err        error
+
+	// stream algorithms use single writer and pool of readers
+	compressor        streamWriter
+	compressionBuffer bytes.Buffer
+	decompressors     sync.Pool
+	mux               sync.Mutex
+
+	// zstd encoder and decoder are safe for concurrent use
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
 }
 
-func NewMsgCompressor(alg, compLevel string) *MsgCompressor {
-	var err error
-	comp := &MsgCompressor{}
-	comp.compressor, err = gzip.NewWriterLevel(&comp.compressionBuffer, gzip.BestCompression)
-	if err != nil {
-		log.Error("Compressor can't be initiated .Err:", err)
+// NewMsgCompressor creates compressor. alg is one of gzip (default) , zstd , flate or lz4.
+// compLevel is either fastest , default , best or numeric level of the algorithm , empty level means best compression for gzip and flate and default level for others.
+// If configuration is invalid , the error is logged and returned by every call.
+func NewMsgCompressor(alg, compLevel string, options ...CompressorOption) *MsgCompressor {
+	comp := &MsgCompressor{alg: alg}
+	if comp.alg == "" {
+		comp.alg = CompressionGzip
+	}
+	for _, o := range options {
+		o.apply(comp)
 	}
 
+	if comp.err = comp.init(compLevel); comp.err != nil {
+		log.Error("Compressor can't be initiated .Err:", comp.err)
+	}
 	return comp
 }
 
+func (c *MsgCompressor) init(compLevel string) error {
+	if len(c.dictionary) > 0 && (c.alg == CompressionGzip || c.alg == CompressionLz4) {
+		return fmt.Errorf("%w by %s", errUnsupportedDictionary, c.alg)
+	}
+
+	switch c.alg {
+	case CompressionGzip:
+		level, err := parseCompressionLevel(compLevel, gzip.BestCompression, gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression)
+		if err != nil {
+			return err
+		}
+		w, err := gzip.NewWriterLevel(&c.compressionBuffer, level)
+		if err != nil {
+			return err
+		}
+		c.compressor = w
+		c.decompressors.New = func() interface{} { return new(gzip.Reader) }
+	case CompressionFlate:
+		level, err := parseCompressionLevel(compLevel, flate.BestCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression)
+		if err != nil {
+			return err
+		}
+		w, err := flate.NewWriterDict(&c.compressionBuffer, level, c.dictionary)
+		if err != nil {
+			return err
+		}
+		c.compressor = w
+		c.decompressors.New = func() interface{} { return flate.NewReaderDict(nil, c.dictionary) }
+	case CompressionZstd:
+		level, err := zstdLevel(compLevel)
+		if err != nil {
+			return err
+		}
+		encOpts := []zstd.EOption{zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1)}
+		decOpts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+		if len(c.dictionary) > 0 {
+			encOpts = append(encOpts, zstd.WithEncoderDictRaw(0, c.dictionary))
+			decOpts = append(decOpts, zstd.WithDecoderDictRaw(0, c.dictionary))
+		}
+		if c.zstdEncoder, err = zstd.NewWriter(nil, encOpts...); err != nil {
+			return err
+		}
+		if c.zstdDecoder, err = zstd.NewReader(nil, decOpts...); err != nil {
+			return err
+		}
+	case CompressionLz4:
+		level, err := parseCompressionLevel(compLevel, 0, 0, 0, 9)
+		if err != nil {
+			return err
+		}
+		lz4Level := lz4.Fast
+		if level > 0 {
+			lz4Level = lz4.CompressionLevel(1 << (8 + level))
+		}
+		w := lz4.NewWriter(&c.compressionBuffer)
+		if err := w.Apply(lz4.CompressionLevelOption(lz4Level), lz4.BlockSizeOption(lz4.Block64Kb), lz4.ConcurrencyOption(1)); err != nil {
+			return err
+		}
+		c.compressor = w
+		c.decompressors.New = func() interface{} { return lz4.NewReader(nil) }
+	default:
+		return fmt.Errorf("unsupported compression algorithm %s", c.alg)
+	}
+	return nil
+}
+
+// parseCompressionLevel parses named or numeric level. Numeric level is validated against range of the algorithm.
+func parseCompressionLevel(compLevel string, empty, fastest, def, best int) (int, error) {
+	switch strings.ToLower(compLevel) {
+	case "":
+		return empty, nil
+	case CompressionLevelFastest:
+		return fastest, nil
+	case CompressionLevelDefault:
+		return def, nil
+	case CompressionLevelBest:
+		return best, nil
+	}
+	level, err := strconv.Atoi(compLevel)
+	if err != nil || level < fastest || level > best {
+		return 0, fmt.Errorf("invalid compression level %s , expected %d-%d", compLevel, fastest, best)
+	}
+	return level, nil
+}
+
+// zstdLevel maps named level to encoder level , numeric level is zstd level 1-22.
+func zstdLevel(compLevel string) (zstd.EncoderLevel, error) {
+	level, err := parseCompressionLevel(compLevel, int(zstd.SpeedDefault), 1, 3, 22)
+	if err != nil {
+		return 0, err
+	}
+	switch strings.ToLower(compLevel) {
+	case "", CompressionLevelDefault:
+		return zstd.SpeedDefault, nil
+	case CompressionLevelFastest:
+		return zstd.SpeedFastest, nil
+	case CompressionLevelBest:
+		return zstd.SpeedBestCompression, nil
+	}
+	return zstd.EncoderLevelFromZstd(level), nil
+}
+
+// Algorithm returns compression algorithm.
+func (c *MsgCompressor) Algorithm() string {
+	return c.alg
+}
+
+// PayloadType returns payload type of JSON compressed by the compressor.
+func (c *MsgCompressor) PayloadType() string {
+	return CompressionPayloadType(c.alg)
+}
+
 //CompressBinMsg - compresses binary message and return compressed byte array.
 func (c *MsgCompressor) CompressBinMsg(msg []byte) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.zstdEncoder != nil {
+		return c.zstdEncoder.EncodeAll(msg, nil), nil
+	}
+
 	c.mux.Lock()
 	defer c.mux.Unlock()
+	c.compressionBuffer.Reset()
 	c.compressor.Reset(&c.compressionBuffer)
 	_, err := c.compressor.Write(msg)
 	if err != nil {
 		log.Error("Compression error :", err.Error())
 		return nil, err
 	}
-	c.compressor.Flush()
-	c.compressor.Close()
-	cp := c.compressionBuffer.Bytes()
+	if err = c.compressor.Close(); err != nil {
+		return nil, err
+	}
+	// buffer is reused by the next call , so result must be copied
+	cp := append([]byte(nil), c.compressionBuffer.Bytes()...)
 	c.compressionBuffer.Reset()
 	return cp, nil
 }
 
 func (c *MsgCompressor) DecompressBinMsg(binMsg []byte) ([]byte, error) {
-	var err error
-	var decompressorBuffer bytes.Buffer
-	decompressorBuffer.Write(binMsg)
-	decompressor, err := gzip.NewReader(&decompressorBuffer)
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.zstdDecoder != nil {
+		return c.zstdDecoder.DecodeAll(binMsg, nil)
+	}
+
+	decompressor := c.decompressors.Get()
+	defer c.decompressors.Put(decompressor)
+	src := bytes.NewReader(binMsg)
+	var r io.Reader
+	switch d := decompressor.(type) {
+	case *gzip.Reader:
+		if err := d.Reset(src); err != nil {
+			log.Error("Decompression error 1 .Err:", err)
+			return nil, err
+		}
+		r = d
+	case flate.Resetter:
+		if err := d.Reset(src, c.dictionary); err != nil {
+			return nil, err
+		}
+		r = d.(io.Reader)
+	case *lz4.Reader:
+		d.Reset(src)
+		r = d
+	}
+	var response bytes.Buffer
+	_, err := response.ReadFrom(r)
 	if err != nil {
-		log.Error("Decompression error 1 .Err:", err)
 		return nil, err
 	}
-	response, err := ioutil.ReadAll(decompressor)
-	decompressor.Close()
-	decompressorBuffer.Reset()
-	return response, err
+	return response.Bytes(), nil
 }
 
 func (c *MsgCompressor) CompressFimpMsg(msg *FimpMessage) ([]byte, error) {
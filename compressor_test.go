@@ -3,6 +3,9 @@ package fimpgo
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/futurehomeno/fimpgo/fimptype"
 )
 
@@ -82,3 +85,113 @@ func TestNewMsgCompressor2(t *testing.T) {
 		}
 	}
 }
+
+func TestMsgCompressor_Algorithms(t *testing.T) {
+	msg := NewStrMapMessage("evt.state.report", "dev_sys", map[string]string{"state": "connected", "firmware": "1.2.3"}, Props{"unit": "C"}, Tags{"tag"}, nil)
+	for _, alg := range []string{CompressionGzip, CompressionZstd, CompressionFlate, CompressionLz4} {
+		for _, level := range []string{"", CompressionLevelFastest, CompressionLevelDefault, CompressionLevelBest, "1"} {
+			comp := NewMsgCompressor(alg, level)
+			decomp := NewMsgCompressor(alg, "")
+			for i := 0; i < 3; i++ {
+				compMsg, err := comp.CompressFimpMsg(msg)
+				require.NoError(t, err, "%s %s", alg, level)
+				fimpMsg, err := decomp.DecompressFimpMsg(compMsg)
+				require.NoError(t, err, "%s %s", alg, level)
+				assert.Equal(t, msg.Value, fimpMsg.Value)
+			}
+		}
+	}
+}
+
+func TestMsgCompressor_InvalidConfig(t *testing.T) {
+	for _, comp := range []*MsgCompressor{
+		NewMsgCompressor("brotli", ""),
+		NewMsgCompressor(CompressionGzip, "10"),
+		NewMsgCompressor(CompressionZstd, "fast"),
+		NewMsgCompressor(CompressionGzip, "", WithDictionary([]byte("dict"))),
+	} {
+		_, err := comp.CompressBinMsg([]byte("{}"))
+		assert.Error(t, err)
+		_, err = comp.DecompressBinMsg([]byte("{}"))
+		assert.Error(t, err)
+	}
+}
+
+func TestMsgCompressor_Dictionary(t *testing.T) {
+	var dict []byte
+	for _, sample := range []*FimpMessage{
+		NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, Props{"unit": "C"}, nil, nil),
+		NewBoolMessage("evt.binary.report", "out_bin_switch", true, nil, nil, nil),
+	} {
+		data, err := sample.SerializeToJson()
+		require.NoError(t, err)
+		dict = append(dict, data...)
+	}
+
+	msg := NewFloatMessage("evt.sensor.report", "sensor_temp", 22.5, Props{"unit": "C"}, nil, nil)
+	data, err := msg.SerializeToJson()
+	require.NoError(t, err)
+
+	gzipped, err := NewMsgCompressor(CompressionGzip, "").CompressBinMsg(data)
+	require.NoError(t, err)
+	assert.Greater(t, len(gzipped), len(data)/2)
+
+	for _, alg := range []string{CompressionZstd, CompressionFlate} {
+		comp := NewMsgCompressor(alg, "", WithDictionary(dict))
+		compressed, err := comp.CompressBinMsg(data)
+		require.NoError(t, err)
+		assert.Less(t, len(compressed), len(data)/2, alg)
+
+		decompressed, err := comp.DecompressBinMsg(compressed)
+		require.NoError(t, err)
+		assert.Equal(t, data, decompressed)
+
+		// peer without dictionary can't decode the message
+		_, err = NewMsgCompressor(alg, "").DecompressBinMsg(compressed)
+		assert.Error(t, err, alg)
+	}
+}
+
+func TestMsgCompressor_PayloadTypes(t *testing.T) {
+	msg := NewIntMessage("evt.lvl.report", "out_lvl_switch", 50, nil, nil, nil)
+	for _, alg := range []string{CompressionGzip, CompressionZstd, CompressionFlate, CompressionLz4} {
+		comp := NewMsgCompressor(alg, "")
+		payload, err := comp.CompressFimpMsg(msg)
+		require.NoError(t, err)
+
+		decoded, err := DecodePayload(comp.PayloadType(), payload)
+		require.NoError(t, err, alg)
+		assert.Equal(t, int64(50), decoded.Value)
+	}
+	assert.Equal(t, "j1c2", CompressionPayloadType(CompressionZstd))
+	assert.Empty(t, CompressionPayloadType("brotli"))
+}
+
+func BenchmarkMsgCompressor(b *testing.B) {
+	msg := NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, Props{"unit": "C"}, nil, nil)
+	data, err := msg.SerializeToJson()
+	require.NoError(b, err)
+
+	for _, alg := range []string{CompressionGzip, CompressionZstd, CompressionFlate, CompressionLz4} {
+		comp := NewMsgCompressor(alg, "")
+		compressed, err := comp.CompressBinMsg(data)
+		require.NoError(b, err)
+
+		b.Run(alg+"/compress", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := comp.CompressBinMsg(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(alg+"/decompress", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := comp.DecompressBinMsg(compressed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
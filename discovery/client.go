@@ -7,7 +7,7 @@ import (
 )
 
 // DiscoverResources discovers resources around , timeout is set in seconds
-func DiscoverResources(mqt *fimpgo.MqttTransport,timeout int) ([]Resource,error) {
+func DiscoverResources(mqt fimpgo.Transport,timeout int) ([]Resource,error) {
 	msg := fimpgo.NewNullMessage("cmd.discovery.request", "system", nil, nil, nil)
 	adr := fimpgo.Address{MsgType: fimpgo.MsgTypeCmd, ResourceType: fimpgo.ResourceTypeDiscovery}
 	resCh := make(fimpgo.MessageCh)
@@ -46,7 +46,7 @@ type AdapterInfo struct {
 }
 
 type ServiceDiscoveryResponder struct {
-	mqt                   *fimpgo.MqttTransport
+	mqt                   fimpgo.Transport
 	resource              Resource
 	discoveryRequestTopic string
 	responderTopic        string
@@ -54,7 +54,7 @@ type ServiceDiscoveryResponder struct {
 	stopSignal            chan bool
 }
 
-func NewServiceDiscoveryResponder(mqt *fimpgo.MqttTransport) *ServiceDiscoveryResponder {
+func NewServiceDiscoveryResponder(mqt fimpgo.Transport) *ServiceDiscoveryResponder {
 	inst := &ServiceDiscoveryResponder{mqt: mqt, discoveryRequestTopic: "pt:j1/mt:cmd/rt:discovery", responderTopic: "pt:j1/mt:evt/rt:discovery"}
 	inst.stopSignal = make(chan bool)
 	inst.requestsCh = make(fimpgo.MessageCh)
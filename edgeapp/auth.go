@@ -43,6 +43,7 @@ type FhOAuth2Client struct {
 	appName            string
 	partnerName        string
 	mqt                *fimpgo.MqttTransport
+	transport          fimpgo.Transport // externally provided transport , it's used instead of dedicated mqt connection
 	mqttServerURI      string
 	mqttClientID       string
 	refreshTokenApiUrl string
@@ -121,8 +122,17 @@ func (oac *FhOAuth2Client) SetParameters(mqttServerUri, authCodeApiUrl, refreshT
 	}
 }
 
+// SetFimpTransport configures transport , which is used to obtain Hub token from cloud bridge instead of dedicated MQTT connection.
+func (oac *FhOAuth2Client) SetFimpTransport(transport fimpgo.Transport) {
+	oac.transport = transport
+}
+
 // ConfigureFimpSyncClient configures fimp sync client , which is used to obtain Hub token from cloud bridge.
 func (oac *FhOAuth2Client) ConfigureFimpSyncClient() error {
+	if oac.transport != nil {
+		oac.syncClient = fimpgo.NewSyncClient(oac.transport)
+		return nil
+	}
 	if oac.mqt == nil {
 		oac.mqt = fimpgo.NewMqttTransport(oac.mqttServerURI, oac.mqttClientID, "", "", true, 1, 1)
 		err := oac.mqt.Start()
@@ -140,7 +150,7 @@ func (oac *FhOAuth2Client) ConfigureFimpSyncClient() error {
 
 // LoadHubTokenFromCB - requests hub token from CloudBridge
 func (oac *FhOAuth2Client) LoadHubTokenFromCB() error {
-	if oac.mqt == nil || oac.syncClient == nil {
+	if (oac.mqt == nil && oac.transport == nil) || oac.syncClient == nil {
 		if err := oac.ConfigureFimpSyncClient(); err != nil {
 			log.Error(err)
 		}
@@ -161,7 +171,9 @@ func (oac *FhOAuth2Client) LoadHubTokenFromCB() error {
 	}
 
 	oac.syncClient.Stop()
-	oac.mqt.Stop()
+	if oac.mqt != nil {
+		oac.mqt.Stop()
+	}
 	if err != nil {
 		return err
 	}
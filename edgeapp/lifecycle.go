@@ -1,6 +1,7 @@
 package edgeapp
 
 import (
+	"github.com/futurehomeno/fimpgo"
 	log "github.com/sirupsen/logrus"
 	"sync"
 )
@@ -145,6 +146,27 @@ func (al *Lifecycle) SetConnectionState(connectivityState State) {
 	}
 }
 
+// ReportConnectionState updates connection state from MQTT transport connection events. It should be set as transport connection state handler :
+//
+//	fimpgo.NewMqttTransportFromConfigs(configs, fimpgo.WithConnectionStateHandler(lifecycle.ReportConnectionState))
+func (al *Lifecycle) ReportConnectionState(event fimpgo.ConnectionEvent) {
+	var state State
+	switch event.State {
+	case fimpgo.ConnectionStateConnecting, fimpgo.ConnectionStateReconnecting:
+		state = ConnStateConnecting
+	case fimpgo.ConnectionStateConnected, fimpgo.ConnectionStateResubscribed:
+		state = ConnStateConnected
+	default:
+		state = ConnStateDisconnected
+	}
+	if event.Err != nil {
+		al.SetLastError(event.Err.Error())
+	}
+	if al.ConnectionState() != state {
+		al.SetConnectionState(state)
+	}
+}
+
 func (al *Lifecycle) AppState() State {
 	return al.appState
 }
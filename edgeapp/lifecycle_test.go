@@ -1,8 +1,11 @@
 package edgeapp
 
 import (
+	"errors"
 	"testing"
 	"time"
+
+	"github.com/futurehomeno/fimpgo"
 )
 
 func TestLifecycle_WaitForState(t *testing.T) {
@@ -34,3 +37,31 @@ func TestLifecycle_WaitForState(t *testing.T) {
 	t.Log("OK")
 
 }
+
+func TestLifecycle_ReportConnectionState(t *testing.T) {
+	lf := NewAppLifecycle()
+	events := lf.Subscribe("test-conn", 10)
+
+	lf.ReportConnectionState(fimpgo.ConnectionEvent{State: fimpgo.ConnectionStateConnecting})
+	lf.ReportConnectionState(fimpgo.ConnectionEvent{State: fimpgo.ConnectionStateConnected})
+	lf.ReportConnectionState(fimpgo.ConnectionEvent{State: fimpgo.ConnectionStateResubscribed})
+	lf.ReportConnectionState(fimpgo.ConnectionEvent{State: fimpgo.ConnectionStateLost, Err: errors.New("broker is gone")})
+
+	if lf.ConnectionState() != ConnStateDisconnected {
+		t.Errorf("expected %s , got %s", ConnStateDisconnected, lf.ConnectionState())
+	}
+	if lf.LastError() != "broker is gone" {
+		t.Errorf("unexpected last error %s", lf.LastError())
+	}
+	// repeated state is not published
+	expected := []State{ConnStateConnecting, ConnStateConnected, ConnStateDisconnected}
+	for _, state := range expected {
+		evt := <-events
+		if evt.Type != SystemEventTypeConnectionState || evt.State != state {
+			t.Errorf("expected %s , got %s", state, evt.State)
+		}
+	}
+	if len(events) != 0 {
+		t.Error("unexpected connection state event")
+	}
+}
@@ -1,15 +1,79 @@
 package fimpgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 )
 
 var (
 	errTimeout   = errors.New("request timed out")
+	errCanceled  = errors.New("request canceled")
 	errSubscribe = errors.New("subscription failed")
 	errPublish   = errors.New("publishing failed")
+	errValueType = errors.New("wrong value type")
+
+	errSchemaViolation = errors.New("schema violation")
+	errInvalidMessage  = errors.New("invalid message")
+
+	errUnsupportedPayload = errors.New("unsupported payload type")
+	errUnauthenticated    = errors.New("message not authenticated")
+	errInvalidAddress     = errors.New("invalid address")
 )
 
 func IsTimeout(err error) bool {
 	return errors.Is(err, errTimeout)
 }
+
+// IsCanceled returns true if request was aborted because its context was canceled.
+func IsCanceled(err error) bool {
+	return errors.Is(err, errCanceled)
+}
+
+// IsSubscribeFailed returns true if request failed because response topic subscription failed.
+func IsSubscribeFailed(err error) bool {
+	return errors.Is(err, errSubscribe)
+}
+
+// IsPublishFailed returns true if request failed because request message could not be published.
+func IsPublishFailed(err error) bool {
+	return errors.Is(err, errPublish)
+}
+
+// IsWrongValueType returns true if message value or properties can't be decoded into requested type.
+func IsWrongValueType(err error) bool {
+	return errors.Is(err, errValueType)
+}
+
+// IsSchemaViolation returns true if message doesn't match interfaces declared by its service.
+func IsSchemaViolation(err error) bool {
+	return errors.Is(err, errSchemaViolation)
+}
+
+// IsInvalidMessage returns true if message was rejected by strict decoder. Details are available in *DecodeError.
+func IsInvalidMessage(err error) bool {
+	return errors.Is(err, errInvalidMessage)
+}
+
+// IsUnsupportedPayload returns true if no codec is registered for payload type of the message.
+func IsUnsupportedPayload(err error) bool {
+	return errors.Is(err, errUnsupportedPayload)
+}
+
+// IsInvalidAddress returns true if topic can't be parsed into FIMP address or address can't be serialized into valid topic.
+func IsInvalidAddress(err error) bool {
+	return errors.Is(err, errInvalidAddress)
+}
+
+// IsUnauthenticated returns true if signed message failed verification or unsigned message was rejected by signature policy.
+func IsUnauthenticated(err error) bool {
+	return errors.Is(err, errUnauthenticated)
+}
+
+// contextError maps context error into request error.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", errTimeout, ctx.Err())
+	}
+	return fmt.Errorf("%w: %w", errCanceled, ctx.Err())
+}
@@ -1,7 +1,9 @@
 package fimpgo
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -14,3 +16,26 @@ func TestErrorTypes(t *testing.T) {
 	assert.True(t, IsTimeout(timeoutErr))
 	assert.False(t, IsTimeout(randomErr))
 }
+
+func TestContextErrorTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	canceledErr := contextError(ctx)
+	assert.True(t, IsCanceled(canceledErr))
+	assert.False(t, IsTimeout(canceledErr))
+	assert.True(t, errors.Is(canceledErr, context.Canceled))
+
+	ctx, cancel = context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	timeoutErr := contextError(ctx)
+	assert.True(t, IsTimeout(timeoutErr))
+	assert.False(t, IsCanceled(timeoutErr))
+	assert.True(t, errors.Is(timeoutErr, context.DeadlineExceeded))
+
+	publishErr := fmt.Errorf("%w: %w", errPublish, errors.New("not connected"))
+	assert.True(t, IsPublishFailed(publishErr))
+	assert.False(t, IsSubscribeFailed(publishErr))
+}
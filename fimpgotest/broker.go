@@ -0,0 +1,112 @@
+// Package fimpgotest provides in-memory FIMP broker and transport , which can be used to test FIMP applications without MQTT broker.
+package fimpgotest
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/utils"
+)
+
+// Broker is an in-process message broker. It routes messages between transports created by the broker using MQTT wildcard rules
+// and invokes scripted responders.
+type Broker struct {
+	mux        sync.RWMutex
+	transports map[*Transport]struct{}
+	responders []*Responder
+	published  []*fimpgo.Message
+}
+
+// NewBroker creates new in-memory broker.
+func NewBroker() *Broker {
+	return &Broker{transports: make(map[*Transport]struct{})}
+}
+
+// NewTransport creates new transport connected to the broker. The transport is started and ready to use.
+func (b *Broker) NewTransport(clientID string) *Transport {
+	t := newTransport(b, clientID)
+	b.mux.Lock()
+	b.transports[t] = struct{}{}
+	b.mux.Unlock()
+	return t
+}
+
+// When registers responder , which is invoked for every message published to topic matching topicFilter with matching service and message type.
+// Empty string or "*" matches any service or message type.
+func (b *Broker) When(topicFilter, service, msgType string) *Responder {
+	r := &Responder{broker: b, topicFilter: topicFilter, service: service, msgType: msgType}
+	b.mux.Lock()
+	b.responders = append(b.responders, r)
+	b.mux.Unlock()
+	return r
+}
+
+// Published returns all messages published through the broker since it was created or reset.
+// Topics are returned without global prefix.
+func (b *Broker) Published() []*fimpgo.Message {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	result := make([]*fimpgo.Message, len(b.published))
+	copy(result, b.published)
+	return result
+}
+
+// Reset removes all responders and recorded messages.
+func (b *Broker) Reset() {
+	b.mux.Lock()
+	b.responders = nil
+	b.published = nil
+	b.mux.Unlock()
+}
+
+func (b *Broker) removeTransport(t *Transport) {
+	b.mux.Lock()
+	delete(b.transports, t)
+	b.mux.Unlock()
+}
+
+// publish routes raw payload to all interested transports and responders. Topic must include global prefix if it's used.
+func (b *Broker) publish(topic string, payload []byte) {
+	b.mux.Lock()
+	var transports []*Transport
+	for t := range b.transports {
+		if t.isSubscribed(topic) {
+			transports = append(transports, t)
+		}
+	}
+	responders := make([]*Responder, len(b.responders))
+	copy(responders, b.responders)
+
+	globalPrefix, localTopic := splitTopic(topic)
+	msg, err := decodeMessage(localTopic, payload)
+	if err == nil {
+		b.published = append(b.published, msg)
+	}
+	b.mux.Unlock()
+
+	for _, t := range transports {
+		t.enqueue(topic, payload)
+	}
+	if err != nil {
+		return
+	}
+	for _, r := range responders {
+		if r.matches(localTopic, msg.Payload) {
+			go r.respond(globalPrefix, msg)
+		}
+	}
+}
+
+// splitTopic splits topic into global prefix and FIMP topic.
+func splitTopic(topic string) (string, string) {
+	if strings.HasPrefix(topic, "pt:") || !strings.Contains(topic, "pt:") {
+		return "", topic
+	}
+	return fimpgo.DetachGlobalPrefixFromTopic(topic)
+}
+
+// routeIncludesTopic is a thin wrapper around MQTT wildcard matching used by MqttTransport.
+func routeIncludesTopic(route, topic string) bool {
+	return utils.RouteIncludesTopic(route, topic)
+}
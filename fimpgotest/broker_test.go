@@ -0,0 +1,296 @@
+package fimpgotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch fimpgo.MessageCh) *fimpgo.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message was not received")
+		return nil
+	}
+}
+
+func TestBroker_Routing(t *testing.T) {
+	broker := NewBroker()
+	pub := broker.NewTransport("pub")
+	defer pub.Stop()
+	sub := broker.NewTransport("sub")
+	defer sub.Stop()
+
+	require.NoError(t, sub.Subscribe("+/mt:evt/rt:dev/rn:zigbee/ad:1/#"))
+	allCh := make(fimpgo.MessageCh, 10)
+	sub.RegisterChannel("all", allCh)
+	filteredCh := make(fimpgo.MessageCh, 10)
+	sub.RegisterChannelWithFilter("filtered", filteredCh, fimpgo.FimpFilter{Topic: "+/mt:evt/+/+/+/sv:meter_elec/+", Service: "*", Interface: "evt.meter.report"})
+
+	addr := &fimpgo.Address{MsgType: fimpgo.MsgTypeEvt, ResourceType: fimpgo.ResourceTypeDevice, ResourceName: "zigbee", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1_1"}
+	require.NoError(t, pub.Publish(addr, fimpgo.NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil)))
+	require.NoError(t, pub.PublishToTopic("pt:j1c1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:meter_elec/ad:1_1", fimpgo.NewFloatMessage("evt.meter.report", "meter_elec", 100, nil, nil, nil)))
+	require.NoError(t, pub.PublishToTopic("pt:j1/mt:evt/rt:dev/rn:zwave-ad/ad:1/sv:meter_elec/ad:1_1", fimpgo.NewFloatMessage("evt.meter.report", "meter_elec", 200, nil, nil, nil)))
+
+	msg := receive(t, allCh)
+	assert.Equal(t, "pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1", msg.Topic)
+	assert.Equal(t, "sensor_temp", msg.Addr.ServiceName)
+	msg = receive(t, allCh)
+	assert.Equal(t, "meter_elec", msg.Payload.Service)
+
+	msg = receive(t, filteredCh)
+	val, err := msg.Payload.GetFloatValue()
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, val)
+
+	assert.Len(t, broker.Published(), 3)
+	select {
+	case msg := <-filteredCh:
+		t.Fatalf("unexpected message on topic %s", msg.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBroker_SubscribePattern(t *testing.T) {
+	broker := NewBroker()
+	pub := broker.NewTransport("pub")
+	defer pub.Stop()
+	var sub fimpgo.Transport = broker.NewTransport("sub")
+	defer sub.(*Transport).Stop()
+
+	pattern := fimpgo.Match().Evt().Dev().Adapter("zigbee").Service("sensor_temp")
+	require.NoError(t, sub.SubscribePattern(pattern))
+	assert.True(t, fimpgo.IsInvalidAddress(sub.SubscribePattern(fimpgo.Match().Adapter("z+"))))
+	ch := make(fimpgo.MessageCh, 10)
+	sub.RegisterChannel("test", ch)
+
+	require.NoError(t, pub.PublishToTopic("pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1", fimpgo.NewFloatMessage("evt.sensor.report", "sensor_temp", 20, nil, nil, nil)))
+	require.NoError(t, pub.PublishToTopic("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1", fimpgo.NewFloatMessage("evt.sensor.report", "sensor_temp", 21, nil, nil, nil)))
+	msg := receive(t, ch)
+	assert.Equal(t, "zigbee", msg.Addr.ResourceName)
+
+	require.NoError(t, sub.UnsubscribePattern(pattern))
+	require.NoError(t, pub.PublishToTopic("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1", fimpgo.NewFloatMessage("evt.sensor.report", "sensor_temp", 22, nil, nil, nil)))
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message on topic %s", msg.Topic)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTransport_SubscriptionRefCount(t *testing.T) {
+	broker := NewBroker()
+	tr := broker.NewTransport("sub")
+	defer tr.Stop()
+	topic := "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+
+	sub1, err := tr.NewSubscription(topic)
+	require.NoError(t, err)
+	sub2, err := tr.NewSubscription(topic)
+	require.NoError(t, err)
+	require.NoError(t, tr.Subscribe(topic))
+	assert.Equal(t, []string{topic}, tr.Subscriptions())
+
+	require.NoError(t, tr.Unsubscribe(topic))
+	require.NoError(t, sub1.Close())
+	require.NoError(t, sub1.Close())
+	assert.Equal(t, []string{topic}, tr.Subscriptions(), "topic is still used by the second handle")
+	assert.True(t, tr.isSubscribed(topic))
+
+	require.NoError(t, sub2.Close())
+	assert.Empty(t, tr.Subscriptions())
+}
+
+func TestBroker_SyncClientConcurrentAutoSubscribe(t *testing.T) {
+	broker := NewBroker()
+	responseTopic := "pt:j1/mt:rsp/rt:app/rn:client/ad:1"
+	broker.When("pt:j1/mt:cmd/rt:app/rn:fast/ad:1", "*", "*").
+		RespondWith(fimpgo.NewStringMessage("evt.test.report", "test", "fast", nil, nil, nil)).ToTopic(responseTopic)
+	broker.When("pt:j1/mt:cmd/rt:app/rn:slow/ad:1", "*", "*").
+		RespondWith(fimpgo.NewStringMessage("evt.test.report", "test", "slow", nil, nil, nil)).ToTopic(responseTopic).WithDelay(200 * time.Millisecond)
+
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	client := fimpgo.NewSyncClient(tr)
+	defer client.Stop()
+
+	send := func(topic string) (*fimpgo.FimpMessage, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return client.SendFimpContext(ctx, topic, fimpgo.NewNullMessage("cmd.test.get_report", "test", nil, nil, nil),
+			fimpgo.WithResponseTopic(responseTopic), fimpgo.WithAutoSubscribe())
+	}
+	slowDone := make(chan error, 1)
+	go func() {
+		_, err := send("pt:j1/mt:cmd/rt:app/rn:slow/ad:1")
+		slowDone <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, err := send("pt:j1/mt:cmd/rt:app/rn:fast/ad:1")
+	require.NoError(t, err)
+
+	// completed request must not unsubscribe response topic of the pending one
+	require.NoError(t, <-slowDone)
+	assert.Empty(t, tr.Subscriptions())
+}
+
+func TestBroker_UnknownPayload(t *testing.T) {
+	broker := NewBroker()
+	tr := broker.NewTransport("sub")
+	defer tr.Stop()
+	topic := "pt:raw1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1"
+	require.NoError(t, tr.Subscribe("+/mt:evt/rt:dev/#"))
+	ch := make(fimpgo.MessageCh, 10)
+	tr.RegisterChannelWithFilter("test", ch, fimpgo.FimpFilter{Topic: "+/mt:evt/rt:dev/#", Service: "sensor_temp", Interface: "*"})
+	handled := make(chan []byte, 10)
+	tr.SetMessageHandler(func(_ string, _ *fimpgo.Address, msg *fimpgo.FimpMessage, raw []byte) {
+		assert.Nil(t, msg)
+		handled <- raw
+	})
+
+	tr.PublishRaw(topic, []byte{1, 2, 3})
+	msg := receive(t, ch)
+	assert.Nil(t, msg.Payload)
+	assert.Equal(t, []byte{1, 2, 3}, msg.RawPayload)
+	assert.Equal(t, []byte{1, 2, 3}, <-handled)
+}
+
+func TestBroker_GlobalPrefix(t *testing.T) {
+	broker := NewBroker()
+	cloud := broker.NewTransport("cloud")
+	defer cloud.Stop()
+	cloud.SetGlobalTopicPrefix("hub1")
+	other := broker.NewTransport("other")
+	defer other.Stop()
+	other.SetGlobalTopicPrefix("hub2")
+
+	topic := "pt:j1/mt:evt/rt:app/rn:test/ad:1"
+	for _, tr := range []*Transport{cloud, other} {
+		require.NoError(t, tr.Subscribe(topic))
+	}
+	cloudCh := make(fimpgo.MessageCh, 10)
+	cloud.RegisterChannel("test", cloudCh)
+	otherCh := make(fimpgo.MessageCh, 10)
+	other.RegisterChannel("test", otherCh)
+
+	pub := broker.NewTransport("pub")
+	defer pub.Stop()
+	pub.SetGlobalTopicPrefix("hub1")
+	require.NoError(t, pub.PublishToTopic(topic, fimpgo.NewStringMessage("evt.test.report", "test", "hello", nil, nil, nil)))
+
+	msg := receive(t, cloudCh)
+	assert.Equal(t, topic, msg.Topic)
+	assert.Equal(t, []string{"hub1/" + topic}, cloud.Subscriptions())
+	select {
+	case <-otherCh:
+		t.Fatal("message with another global prefix must not be delivered")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestBroker_SyncClient(t *testing.T) {
+	broker := NewBroker()
+	responder := broker.When("pt:j1/mt:cmd/rt:app/rn:test/ad:1", "test", "cmd.test.get_report").
+		Respond(func(req *fimpgo.Message) []*fimpgo.FimpMessage {
+			return []*fimpgo.FimpMessage{fimpgo.NewStringMessage("evt.test.report", "test", "pong", nil, nil, req.Payload)}
+		})
+
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	client := fimpgo.NewSyncClient(tr)
+	defer client.Stop()
+
+	req := fimpgo.NewNullMessage("cmd.test.get_report", "test", nil, nil, nil)
+	resp, err := client.SendReqRespFimp("pt:j1/mt:cmd/rt:app/rn:test/ad:1", "pt:j1/mt:evt/rt:app/rn:test/ad:1", req, 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, req.UID, resp.CorrelationID)
+	val, err := resp.GetStringValue()
+	require.NoError(t, err)
+	assert.Equal(t, "pong", val)
+	require.Len(t, responder.Requests(), 1)
+	assert.Equal(t, req.UID, responder.Requests()[0].Payload.UID)
+}
+
+func TestBroker_SyncClientCollect(t *testing.T) {
+	broker := NewBroker()
+	broker.When("pt:j1/mt:cmd/rt:app/rn:test/ad:1", "test", "cmd.test.list").RespondWith(
+		fimpgo.NewStringMessage("evt.test.item", "test", "a", nil, nil, nil),
+		fimpgo.NewStringMessage("evt.test.item", "test", "b", nil, nil, nil),
+		fimpgo.NewStringMessage("evt.test.item", "test", "c", nil, nil, nil),
+	).ToTopic("pt:j1/mt:rsp/rt:app/rn:client/ad:1")
+
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	tr.SetGlobalTopicPrefix("hub1")
+	client := fimpgo.NewSyncClient(tr)
+	defer client.Stop()
+
+	req := fimpgo.NewNullMessage("cmd.test.list", "test", nil, nil, nil)
+	req.ResponseToTopic = "pt:j1/mt:rsp/rt:app/rn:client/ad:1"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results, err := client.SendFimpCollect(ctx, "pt:j1/mt:cmd/rt:app/rn:test/ad:1", req,
+		fimpgo.WithResponseTopic(req.ResponseToTopic), fimpgo.WithAutoSubscribe(), fimpgo.WithMaxResponses(3))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	for i, expected := range []string{"a", "b", "c"} {
+		val, err := results[i].GetStringValue()
+		require.NoError(t, err)
+		assert.Equal(t, expected, val)
+	}
+	assert.Equal(t, 0, client.PendingRequests())
+}
+
+func TestBroker_SyncClientCanceled(t *testing.T) {
+	broker := NewBroker()
+	broker.When("pt:j1/mt:cmd/rt:app/rn:test/ad:1", "*", "*").
+		RespondWith(fimpgo.NewNullMessage("evt.test.report", "test", nil, nil, nil)).
+		WithDelay(time.Second)
+
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	client := fimpgo.NewSyncClient(tr)
+	defer client.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.SendFimpContext(ctx, "pt:j1/mt:cmd/rt:app/rn:test/ad:1", fimpgo.NewNullMessage("cmd.test.get_report", "test", nil, nil, nil),
+		fimpgo.WithResponseTopic("pt:j1/mt:evt/rt:app/rn:test/ad:1"))
+	require.Error(t, err)
+	assert.True(t, fimpgo.IsTimeout(err))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestBroker_Router(t *testing.T) {
+	broker := NewBroker()
+	adapter := broker.NewTransport("adapter")
+	defer adapter.Stop()
+
+	router := fimpgo.NewRouter(adapter)
+	router.Use(fimpgo.RecoveryMiddleware())
+	router.HandleFunc("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/#", "out_bin_switch", "cmd.binary.set", func(msg *fimpgo.Message) *fimpgo.FimpMessage {
+		val, _ := msg.Payload.GetBoolValue()
+		return fimpgo.NewBoolMessage("evt.binary.report", "out_bin_switch", val, nil, nil, msg.Payload)
+	})
+	require.NoError(t, router.Start())
+	defer router.Stop()
+
+	app := broker.NewTransport("app")
+	defer app.Stop()
+	client := fimpgo.NewSyncClient(app)
+	defer client.Stop()
+
+	req := fimpgo.NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)
+	resp, err := client.SendReqRespFimp("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:3_0", "pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:3_0", req, 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, "evt.binary.report", resp.Type)
+}
@@ -0,0 +1,28 @@
+package fimpgotest
+
+import (
+	"github.com/futurehomeno/fimpgo"
+)
+
+// encodeMessage serializes message the same way as MqttTransport does for given payload type.
+func encodeMessage(payloadType string, msg *fimpgo.FimpMessage) ([]byte, error) {
+	return fimpgo.EncodePayload(payloadType, msg)
+}
+
+// decodeMessage deserializes message published to topic. Topic must not include global prefix.
+// Payload of unknown type is returned as raw payload.
+func decodeMessage(topic string, payload []byte) (*fimpgo.Message, error) {
+	addr, err := fimpgo.NewAddressFromString(topic)
+	if err != nil {
+		return nil, err
+	}
+	codec, ok := fimpgo.GetPayloadCodec(addr.PayloadType)
+	if !ok {
+		return &fimpgo.Message{Topic: topic, Addr: addr, RawPayload: payload}, nil
+	}
+	fimpMsg, err := codec.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &fimpgo.Message{Topic: topic, Addr: addr, Payload: fimpMsg}, nil
+}
@@ -0,0 +1,140 @@
+package fimpgotest
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/futurehomeno/fimpgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// ResponseFunc builds responses for the request. Returning no messages means the request is left unanswered.
+type ResponseFunc func(req *fimpgo.Message) []*fimpgo.FimpMessage
+
+// Responder is a scripted service registered with Broker.When. It answers matching requests with configured responses.
+// Responses are used as templates , every response gets correlation id set to request uid and are published to the request response topic ,
+// topic configured with ToTopic or to the request topic mirrored as evt.
+type Responder struct {
+	broker      *Broker
+	topicFilter string
+	service     string
+	msgType     string
+
+	mux        sync.Mutex
+	responseFn ResponseFunc
+	topic      string
+	delay      time.Duration
+	requests   []*fimpgo.Message
+}
+
+// Respond sets function , which builds responses.
+func (r *Responder) Respond(fn ResponseFunc) *Responder {
+	r.mux.Lock()
+	r.responseFn = fn
+	r.mux.Unlock()
+	return r
+}
+
+// RespondWith answers every request with given messages in the same order.
+func (r *Responder) RespondWith(msgs ...*fimpgo.FimpMessage) *Responder {
+	return r.Respond(func(*fimpgo.Message) []*fimpgo.FimpMessage {
+		return msgs
+	})
+}
+
+// RespondWithFile answers every request with FIMP message loaded from JSON file. It panics if the file can't be loaded.
+func (r *Responder) RespondWithFile(path string) *Responder {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic(fmt.Sprintf("fimpgotest: can't read response file %s: %v", path, err))
+	}
+	msg, err := fimpgo.NewMessageFromBytes(data)
+	if err != nil {
+		panic(fmt.Sprintf("fimpgotest: can't parse response file %s: %v", path, err))
+	}
+	return r.RespondWith(msg)
+}
+
+// ToTopic overrides topic responses are published to.
+func (r *Responder) ToTopic(topic string) *Responder {
+	r.mux.Lock()
+	r.topic = topic
+	r.mux.Unlock()
+	return r
+}
+
+// WithDelay delays every response by d.
+func (r *Responder) WithDelay(d time.Duration) *Responder {
+	r.mux.Lock()
+	r.delay = d
+	r.mux.Unlock()
+	return r
+}
+
+// Requests returns all requests the responder has received.
+func (r *Responder) Requests() []*fimpgo.Message {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	result := make([]*fimpgo.Message, len(r.requests))
+	copy(result, r.requests)
+	return result
+}
+
+func (r *Responder) matches(topic string, msg *fimpgo.FimpMessage) bool {
+	if msg == nil || !routeIncludesTopic(r.topicFilter, topic) {
+		return false
+	}
+	return (r.service == "" || r.service == "*" || r.service == msg.Service) &&
+		(r.msgType == "" || r.msgType == "*" || r.msgType == msg.Type)
+}
+
+func (r *Responder) respond(globalPrefix string, req *fimpgo.Message) {
+	r.mux.Lock()
+	r.requests = append(r.requests, req)
+	fn, topic, delay := r.responseFn, r.topic, r.delay
+	r.mux.Unlock()
+
+	if fn == nil {
+		return
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if topic == "" {
+		topic = responseTopic(req)
+	}
+	addr, err := fimpgo.NewAddressFromString(topic)
+	if err != nil {
+		log.Errorf("<FimpTest> Invalid response topic %s: %v", topic, err)
+		return
+	}
+	if globalPrefix != "" {
+		topic = fimpgo.AddGlobalPrefixToTopic(globalPrefix, topic)
+	}
+
+	for _, tmpl := range fn(req) {
+		if tmpl == nil {
+			continue
+		}
+		resp := *tmpl
+		resp.CorrelationID = req.Payload.UID
+		payload, err := encodeMessage(addr.PayloadType, &resp)
+		if err != nil {
+			log.Errorf("<FimpTest> Response can't be encoded: %v", err)
+			continue
+		}
+		r.broker.publish(topic, payload)
+	}
+}
+
+// responseTopic returns response topic set in the request or the request topic mirrored as event.
+func responseTopic(req *fimpgo.Message) string {
+	if req.Payload.ResponseToTopic != "" {
+		return req.Payload.ResponseToTopic
+	}
+	addr := *req.Addr
+	addr.MsgType = fimpgo.MsgTypeEvt
+	return addr.Serialize()
+}
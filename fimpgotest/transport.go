@@ -0,0 +1,414 @@
+package fimpgotest
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/futurehomeno/fimpgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultReceiveChTimeout = 5 * time.Second
+
+type inboundMessage struct {
+	topic   string
+	payload []byte
+}
+
+// Transport is an in-memory implementation of fimpgo.Transport connected to Broker.
+// Inbound messages are dispatched to registered channels and message handler in the same way as MqttTransport does it.
+type Transport struct {
+	broker   *Broker
+	clientID string
+
+	mux            sync.RWMutex
+	subs           map[string]struct{}                   // topics subscribed using Subscribe
+	handles        map[string]map[*Subscription]struct{} // open subscription handles per topic
+	subChannels    map[string]fimpgo.MessageCh
+	subFilters     map[string]fimpgo.FimpFilter
+	subFilterFuncs map[string]fimpgo.FilterFunc
+	msgHandler     fimpgo.MessageHandler
+	globalPrefix   string
+	defaultSource  string
+
+	receiveChTimeout time.Duration
+
+	queueMux  sync.Mutex
+	queueCond *sync.Cond
+	queue     []inboundMessage
+	isStopped bool
+	wg        sync.WaitGroup
+}
+
+var (
+	_ fimpgo.Transport        = (*Transport)(nil)
+	_ fimpgo.HandleSubscriber = (*Transport)(nil)
+)
+
+// Subscription is a handle of reference counted topic subscription with the same semantics as MqttTransport.NewSubscription.
+type Subscription struct {
+	transport *Transport
+	topic     string
+	key       string // topic including global prefix
+}
+
+func newTransport(broker *Broker, clientID string) *Transport {
+	t := &Transport{
+		broker:           broker,
+		clientID:         clientID,
+		subs:             make(map[string]struct{}),
+		handles:          make(map[string]map[*Subscription]struct{}),
+		subChannels:      make(map[string]fimpgo.MessageCh),
+		subFilters:       make(map[string]fimpgo.FimpFilter),
+		subFilterFuncs:   make(map[string]fimpgo.FilterFunc),
+		receiveChTimeout: defaultReceiveChTimeout,
+	}
+	t.queueCond = sync.NewCond(&t.queueMux)
+	t.wg.Add(1)
+	go t.handleIncomingMessages()
+	return t
+}
+
+// ClientID returns client id the transport was created with.
+func (t *Transport) ClientID() string {
+	return t.clientID
+}
+
+// Stop disconnects transport from the broker and stops message processing. Queued messages are dropped.
+func (t *Transport) Stop() {
+	t.broker.removeTransport(t)
+	t.queueMux.Lock()
+	if t.isStopped {
+		t.queueMux.Unlock()
+		return
+	}
+	t.isStopped = true
+	t.queue = nil
+	t.queueCond.Broadcast()
+	t.queueMux.Unlock()
+	t.wg.Wait()
+}
+
+// SetReceiveChTimeout sets how long transport waits for registered channel to accept a message.
+func (t *Transport) SetReceiveChTimeout(timeout time.Duration) {
+	t.mux.Lock()
+	t.receiveChTimeout = timeout
+	t.mux.Unlock()
+}
+
+// SetMessageHandler sets callback invoked for every inbound message.
+func (t *Transport) SetMessageHandler(msgHandler fimpgo.MessageHandler) {
+	t.mux.Lock()
+	t.msgHandler = msgHandler
+	t.mux.Unlock()
+}
+
+// SetDefaultSource sets source for all outgoing messages , which don't have it set explicitly.
+func (t *Transport) SetDefaultSource(source string) {
+	t.mux.Lock()
+	t.defaultSource = source
+	t.mux.Unlock()
+}
+
+func (t *Transport) SetGlobalTopicPrefix(prefix string) {
+	t.mux.Lock()
+	t.globalPrefix = prefix
+	t.mux.Unlock()
+}
+
+func (t *Transport) RegisterChannel(channelId string, messageCh fimpgo.MessageCh) {
+	t.mux.Lock()
+	t.subChannels[channelId] = messageCh
+	t.mux.Unlock()
+}
+
+func (t *Transport) RegisterChannelWithFilter(channelId string, messageCh fimpgo.MessageCh, filter fimpgo.FimpFilter) {
+	t.mux.Lock()
+	t.subChannels[channelId] = messageCh
+	t.subFilters[channelId] = filter
+	t.mux.Unlock()
+}
+
+func (t *Transport) RegisterChannelWithPattern(channelId string, messageCh fimpgo.MessageCh, pattern *fimpgo.AddressPattern) {
+	t.RegisterChannelWithFilterFunc(channelId, messageCh, func(topic string, addr *fimpgo.Address, iotMsg *fimpgo.FimpMessage) bool {
+		return pattern.Matches(addr)
+	})
+}
+
+func (t *Transport) RegisterChannelWithFilterFunc(channelId string, messageCh fimpgo.MessageCh, filterFunc fimpgo.FilterFunc) {
+	t.mux.Lock()
+	t.subChannels[channelId] = messageCh
+	t.subFilterFuncs[channelId] = filterFunc
+	t.mux.Unlock()
+}
+
+func (t *Transport) UnregisterChannel(channelId string) {
+	t.mux.Lock()
+	delete(t.subChannels, channelId)
+	delete(t.subFilters, channelId)
+	delete(t.subFilterFuncs, channelId)
+	t.mux.Unlock()
+}
+
+func (t *Transport) Subscribe(topic string) error {
+	if strings.TrimSpace(topic) == "" {
+		return nil
+	}
+	t.mux.Lock()
+	t.subs[t.addGlobalPrefix(topic)] = struct{}{}
+	t.mux.Unlock()
+	return nil
+}
+
+// Unsubscribe unsubscribes from topic. Topic used by open subscription handles stays subscribed.
+func (t *Transport) Unsubscribe(topic string) error {
+	t.mux.Lock()
+	delete(t.subs, t.addGlobalPrefix(topic))
+	t.mux.Unlock()
+	return nil
+}
+
+// NewSubscription subscribes for topic and returns subscription handle , which must be closed once the topic is not needed.
+func (t *Transport) NewSubscription(topic string) (*Subscription, error) {
+	if strings.TrimSpace(topic) == "" {
+		return nil, errors.New("empty topic")
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	sub := &Subscription{transport: t, topic: topic, key: t.addGlobalPrefix(topic)}
+	if t.handles[sub.key] == nil {
+		t.handles[sub.key] = make(map[*Subscription]struct{})
+	}
+	t.handles[sub.key][sub] = struct{}{}
+	return sub, nil
+}
+
+func (t *Transport) SubscribeHandle(topic string) (fimpgo.SubscriptionHandle, error) {
+	sub, err := t.NewSubscription(topic)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Topic returns topic filter of the subscription.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// Close releases the subscription. The topic is unsubscribed once it isn't used by other handles or Subscribe.
+func (s *Subscription) Close() error {
+	t := s.transport
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	handles := t.handles[s.key]
+	delete(handles, s)
+	if len(handles) == 0 {
+		delete(t.handles, s.key)
+	}
+	return nil
+}
+
+func (t *Transport) SubscribePattern(pattern *fimpgo.AddressPattern) error {
+	if err := pattern.Err(); err != nil {
+		return err
+	}
+	return t.Subscribe(pattern.Topic())
+}
+
+func (t *Transport) UnsubscribePattern(pattern *fimpgo.AddressPattern) error {
+	if err := pattern.Err(); err != nil {
+		return err
+	}
+	return t.Unsubscribe(pattern.Topic())
+}
+
+// Subscriptions returns all active subscriptions including global prefix.
+func (t *Transport) Subscriptions() []string {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	var result []string
+	for topic := range t.subs {
+		result = append(result, topic)
+	}
+	for topic := range t.handles {
+		if _, ok := t.subs[topic]; !ok {
+			result = append(result, topic)
+		}
+	}
+	return result
+}
+
+func (t *Transport) Publish(addr *fimpgo.Address, fimpMsg *fimpgo.FimpMessage) error {
+	if addr.PayloadType == "" {
+		addr.PayloadType = fimpgo.DefaultPayload
+	}
+	return t.publish(addr.Serialize(), addr.PayloadType, fimpMsg)
+}
+
+func (t *Transport) PublishToTopic(topic string, fimpMsg *fimpgo.FimpMessage) error {
+	payloadType := fimpgo.DefaultPayload
+	if addr, err := fimpgo.NewAddressFromString(topic); err == nil {
+		payloadType = addr.PayloadType
+	}
+	return t.publish(topic, payloadType, fimpMsg)
+}
+
+func (t *Transport) RespondToRequest(requestMsg *fimpgo.FimpMessage, responseMsg *fimpgo.FimpMessage) error {
+	if requestMsg.ResponseToTopic == "" {
+		return errors.New("empty response topic")
+	}
+	return t.PublishToTopic(requestMsg.ResponseToTopic, responseMsg)
+}
+
+// PublishRaw publishes raw payload to the topic. Global prefix is not added.
+func (t *Transport) PublishRaw(topic string, payload []byte) {
+	t.broker.publish(topic, payload)
+}
+
+func (t *Transport) publish(topic, payloadType string, fimpMsg *fimpgo.FimpMessage) error {
+	t.mux.RLock()
+	if fimpMsg.Source == "" {
+		fimpMsg.Source = t.defaultSource
+	}
+	topic = t.addGlobalPrefix(topic)
+	t.mux.RUnlock()
+
+	payload, err := encodeMessage(payloadType, fimpMsg)
+	if err != nil {
+		return err
+	}
+	t.broker.publish(topic, payload)
+	return nil
+}
+
+// addGlobalPrefix must be called with mux held.
+func (t *Transport) addGlobalPrefix(topic string) string {
+	if strings.TrimSpace(t.globalPrefix) == "" {
+		return topic
+	}
+	return fimpgo.AddGlobalPrefixToTopic(t.globalPrefix, topic)
+}
+
+func (t *Transport) isSubscribed(topic string) bool {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	for route := range t.subs {
+		if routeIncludesTopic(route, topic) {
+			return true
+		}
+	}
+	for route := range t.handles {
+		if routeIncludesTopic(route, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueue adds message to unbounded inbound queue , so the broker never blocks on slow consumers.
+func (t *Transport) enqueue(topic string, payload []byte) {
+	t.queueMux.Lock()
+	defer t.queueMux.Unlock()
+	if t.isStopped {
+		return
+	}
+	t.queue = append(t.queue, inboundMessage{topic: topic, payload: payload})
+	t.queueCond.Signal()
+}
+
+func (t *Transport) handleIncomingMessages() {
+	defer t.wg.Done()
+	for {
+		t.queueMux.Lock()
+		for len(t.queue) == 0 && !t.isStopped {
+			t.queueCond.Wait()
+		}
+		if t.isStopped {
+			t.queueMux.Unlock()
+			return
+		}
+		msg := t.queue[0]
+		t.queue[0] = inboundMessage{}
+		t.queue = t.queue[1:]
+		t.queueMux.Unlock()
+
+		t.handleIncomingMessage(msg)
+	}
+}
+
+func (t *Transport) handleIncomingMessage(msg inboundMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("<FimpTest> Message handler CRASHED with error :", r)
+		}
+	}()
+
+	t.mux.RLock()
+	topic := msg.topic
+	if strings.TrimSpace(t.globalPrefix) != "" {
+		_, topic = fimpgo.DetachGlobalPrefixFromTopic(topic)
+	}
+	msgHandler := t.msgHandler
+	timeout := t.receiveChTimeout
+	t.mux.RUnlock()
+
+	fmsg, err := decodeMessage(topic, msg.payload)
+	if err != nil {
+		log.Errorf("<FimpTest> Processing payload from topic=%s err: %v", topic, err)
+		return
+	}
+	if fmsg.Payload == nil {
+		// same as MqttTransport , unknown payload is delivered with nil Payload and RawPayload set
+		log.Debugf("<FimpTest> Unknown PayloadType=%s topic=%s", fmsg.Addr.PayloadType, topic)
+	}
+
+	if msgHandler != nil {
+		msgHandler(topic, fmsg.Addr, fmsg.Payload, msg.payload)
+	}
+
+	for _, ch := range t.interestedChannels(topic, fmsg.Addr, fmsg.Payload) {
+		// Every channel gets its own copy of the envelope , same as with MqttTransport.
+		chMsg := *fmsg
+		timer := time.NewTimer(timeout)
+		select {
+		case ch <- &chMsg:
+			timer.Stop()
+		case <-timer.C:
+			log.Info("<FimpTest> Channel is not read for ", timeout)
+		}
+	}
+}
+
+func (t *Transport) interestedChannels(topic string, addr *fimpgo.Address, msg *fimpgo.FimpMessage) []fimpgo.MessageCh {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	var result []fimpgo.MessageCh
+	for id, ch := range t.subChannels {
+		if t.isChannelInterested(id, topic, addr, msg) {
+			result = append(result, ch)
+		}
+	}
+	return result
+}
+
+// isChannelInterested applies the same filtering rules as MqttTransport.
+func (t *Transport) isChannelInterested(chanName string, topic string, addr *fimpgo.Address, msg *fimpgo.FimpMessage) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("<FimpTest> Filter CRASHED with error :", r)
+			ok = false
+		}
+	}()
+
+	if filterFunc, ok := t.subFilterFuncs[chanName]; ok {
+		return filterFunc(topic, addr, msg)
+	}
+	filter, ok := t.subFilters[chanName]
+	if !ok {
+		return true
+	}
+	return filter.Matches(topic, addr, msg)
+}
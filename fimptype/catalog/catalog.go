@@ -0,0 +1,95 @@
+// Package catalog describes standard FIMP services , their interfaces , value types and properties.
+// Service definitions are generated from services.json , every service has typed constructors and parsers for its interfaces , for instance
+//
+//	msg := catalog.SensorTemp.Report(21.5, "C")
+//	lvl, err := catalog.OutLvlSwitch.ParseSet(msg)
+package catalog
+
+//go:generate go run ./gen -in services.json -out catalog_gen.go
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/fimptype"
+)
+
+// ServiceDefinition is implemented by all catalog services.
+type ServiceDefinition interface {
+	// Name returns FIMP service name.
+	Name() string
+	// Interfaces returns all interfaces of the service.
+	Interfaces() []fimptype.Interface
+	// Service returns service specification , which can be used in inclusion report.
+	Service(address string) fimptype.Service
+}
+
+// Lookup returns definition of the service with given name.
+func Lookup(name string) (ServiceDefinition, bool) {
+	s, ok := registry[name]
+	return s, ok
+}
+
+// Interfaces returns interfaces of the service with given name or nil if the service is not in the catalog.
+func Interfaces(name string) []fimptype.Interface {
+	if s, ok := registry[name]; ok {
+		return s.Interfaces()
+	}
+	return nil
+}
+
+// Names returns sorted names of all services in the catalog.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildService creates service specification , props are copied so the caller can modify them.
+func buildService(name, address string, props map[string]interface{}, interfaces []fimptype.Interface) fimptype.Service {
+	serviceProps := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		serviceProps[k] = v
+	}
+	return fimptype.Service{
+		Name:       name,
+		Address:    address,
+		Enabled:    true,
+		Props:      serviceProps,
+		Interfaces: interfaces,
+	}
+}
+
+// newProps creates message properties from key/value pairs , empty values are skipped.
+func newProps(keyValues ...string) fimpgo.Props {
+	var props fimpgo.Props
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		if keyValues[i+1] == "" {
+			continue
+		}
+		if props == nil {
+			props = fimpgo.Props{}
+		}
+		props[keyValues[i]] = keyValues[i+1]
+	}
+	return props
+}
+
+// decode checks that message belongs to the interface and decodes its value.
+func decode[T any](msg *fimpgo.FimpMessage, service, interfaceType string) (T, error) {
+	var result T
+	if msg == nil {
+		return result, fmt.Errorf("catalog: expected %s message , got nil", interfaceType)
+	}
+	if msg.Type != interfaceType {
+		return result, fmt.Errorf("catalog: expected %s message , got %s", interfaceType, msg.Type)
+	}
+	if msg.Service != "" && msg.Service != service {
+		return result, fmt.Errorf("catalog: expected %s service , got %s", service, msg.Service)
+	}
+	return fimpgo.Decode[T](msg)
+}
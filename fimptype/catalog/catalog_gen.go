@@ -0,0 +1,1119 @@
+// Code generated by go run ./gen; DO NOT EDIT.
+
+package catalog
+
+import (
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/fimptype"
+)
+
+// Interface types of catalog services.
+const (
+	IntfCmdAlarmGetReport    = "cmd.alarm.get_report"
+	IntfCmdBinaryGetReport   = "cmd.binary.get_report"
+	IntfCmdBinarySet         = "cmd.binary.set"
+	IntfCmdColorGetReport    = "cmd.color.get_report"
+	IntfCmdColorSet          = "cmd.color.set"
+	IntfCmdLockGetReport     = "cmd.lock.get_report"
+	IntfCmdLockSet           = "cmd.lock.set"
+	IntfCmdLvlGetReport      = "cmd.lvl.get_report"
+	IntfCmdLvlSet            = "cmd.lvl.set"
+	IntfCmdLvlStart          = "cmd.lvl.start"
+	IntfCmdLvlStop           = "cmd.lvl.stop"
+	IntfCmdMeterGetReport    = "cmd.meter.get_report"
+	IntfCmdMeterReset        = "cmd.meter.reset"
+	IntfCmdModeGetReport     = "cmd.mode.get_report"
+	IntfCmdModeSet           = "cmd.mode.set"
+	IntfCmdOpenGetReport     = "cmd.open.get_report"
+	IntfCmdPresenceGetReport = "cmd.presence.get_report"
+	IntfCmdSceneGetReport    = "cmd.scene.get_report"
+	IntfCmdSceneSet          = "cmd.scene.set"
+	IntfCmdSensorGetReport   = "cmd.sensor.get_report"
+	IntfCmdSetpointGetReport = "cmd.setpoint.get_report"
+	IntfCmdSetpointSet       = "cmd.setpoint.set"
+	IntfCmdStateGetReport    = "cmd.state.get_report"
+	IntfEvtAlarmReport       = "evt.alarm.report"
+	IntfEvtBinaryReport      = "evt.binary.report"
+	IntfEvtColorReport       = "evt.color.report"
+	IntfEvtLockReport        = "evt.lock.report"
+	IntfEvtLvlReport         = "evt.lvl.report"
+	IntfEvtMeterReport       = "evt.meter.report"
+	IntfEvtModeReport        = "evt.mode.report"
+	IntfEvtOpenReport        = "evt.open.report"
+	IntfEvtPresenceReport    = "evt.presence.report"
+	IntfEvtSceneReport       = "evt.scene.report"
+	IntfEvtSensorReport      = "evt.sensor.report"
+	IntfEvtSetpointReport    = "evt.setpoint.report"
+	IntfEvtStateReport       = "evt.state.report"
+)
+
+// Names of catalog services.
+const (
+	ServiceOutBinSwitch   = "out_bin_switch"
+	ServiceOutLvlSwitch   = "out_lvl_switch"
+	ServiceSensorTemp     = "sensor_temp"
+	ServiceSensorHumid    = "sensor_humid"
+	ServiceSensorLumin    = "sensor_lumin"
+	ServiceSensorCO2      = "sensor_co2"
+	ServiceSensorAtmo     = "sensor_atmo"
+	ServiceSensorPresence = "sensor_presence"
+	ServiceSensorContact  = "sensor_contact"
+	ServiceMeterElec      = "meter_elec"
+	ServiceBattery        = "battery"
+	ServiceAlarmFire      = "alarm_fire"
+	ServiceAlarmWater     = "alarm_water"
+	ServiceThermostat     = "thermostat"
+	ServiceColorCtrl      = "color_ctrl"
+	ServiceDoorLock       = "door_lock"
+	ServiceSceneCtrl      = "scene_ctrl"
+	ServiceBasic          = "basic"
+)
+
+var registry = map[string]ServiceDefinition{
+	ServiceOutBinSwitch:   OutBinSwitch,
+	ServiceOutLvlSwitch:   OutLvlSwitch,
+	ServiceSensorTemp:     SensorTemp,
+	ServiceSensorHumid:    SensorHumid,
+	ServiceSensorLumin:    SensorLumin,
+	ServiceSensorCO2:      SensorCO2,
+	ServiceSensorAtmo:     SensorAtmo,
+	ServiceSensorPresence: SensorPresence,
+	ServiceSensorContact:  SensorContact,
+	ServiceMeterElec:      MeterElec,
+	ServiceBattery:        Battery,
+	ServiceAlarmFire:      AlarmFire,
+	ServiceAlarmWater:     AlarmWater,
+	ServiceThermostat:     Thermostat,
+	ServiceColorCtrl:      ColorCtrl,
+	ServiceDoorLock:       DoorLock,
+	ServiceSceneCtrl:      SceneCtrl,
+	ServiceBasic:          Basic,
+}
+
+// OutBinSwitchService is out_bin_switch service , binary output switch , for instance relay or smart plug.
+type OutBinSwitchService struct{}
+
+// OutBinSwitch describes out_bin_switch service.
+var OutBinSwitch OutBinSwitchService
+
+// Name returns service name.
+func (OutBinSwitchService) Name() string {
+	return ServiceOutBinSwitch
+}
+
+// Interfaces returns interfaces of out_bin_switch service.
+func (OutBinSwitchService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdBinarySet, MsgType: "in", ValueType: fimpgo.VTypeBool, Version: "1"},
+		{Type: IntfCmdBinaryGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtBinaryReport, MsgType: "out", ValueType: fimpgo.VTypeBool, Version: "1"},
+	}
+}
+
+// Service returns out_bin_switch service specification with given address.
+func (s OutBinSwitchService) Service(address string) fimptype.Service {
+	return buildService(ServiceOutBinSwitch, address, nil, s.Interfaces())
+}
+
+// Set creates cmd.binary.set message of out_bin_switch service.
+func (OutBinSwitchService) Set(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfCmdBinarySet, ServiceOutBinSwitch, value, nil, nil, nil)
+}
+
+// ParseSet decodes value of cmd.binary.set message of out_bin_switch service.
+func (OutBinSwitchService) ParseSet(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceOutBinSwitch, IntfCmdBinarySet)
+}
+
+// GetReport creates cmd.binary.get_report message of out_bin_switch service.
+func (OutBinSwitchService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdBinaryGetReport, ServiceOutBinSwitch, nil, nil, nil)
+}
+
+// Report creates evt.binary.report message of out_bin_switch service.
+func (OutBinSwitchService) Report(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfEvtBinaryReport, ServiceOutBinSwitch, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.binary.report message of out_bin_switch service.
+func (OutBinSwitchService) ParseReport(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceOutBinSwitch, IntfEvtBinaryReport)
+}
+
+// OutLvlSwitchService is out_lvl_switch service , level output switch , for instance dimmer or roller shutter.
+type OutLvlSwitchService struct{}
+
+// OutLvlSwitch describes out_lvl_switch service.
+var OutLvlSwitch OutLvlSwitchService
+
+var outLvlSwitchProps = map[string]interface{}{
+	"max_lvl": 100,
+	"min_lvl": 0,
+}
+
+// Name returns service name.
+func (OutLvlSwitchService) Name() string {
+	return ServiceOutLvlSwitch
+}
+
+// Interfaces returns interfaces of out_lvl_switch service.
+func (OutLvlSwitchService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdLvlSet, MsgType: "in", ValueType: fimpgo.VTypeInt, Version: "1"},
+		{Type: IntfCmdLvlGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtLvlReport, MsgType: "out", ValueType: fimpgo.VTypeInt, Version: "1"},
+		{Type: IntfCmdLvlStart, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfCmdLvlStop, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfCmdBinarySet, MsgType: "in", ValueType: fimpgo.VTypeBool, Version: "1"},
+		{Type: IntfEvtBinaryReport, MsgType: "out", ValueType: fimpgo.VTypeBool, Version: "1"},
+	}
+}
+
+// Service returns out_lvl_switch service specification with given address.
+func (s OutLvlSwitchService) Service(address string) fimptype.Service {
+	return buildService(ServiceOutLvlSwitch, address, outLvlSwitchProps, s.Interfaces())
+}
+
+// OutLvlSwitchSet is decoded cmd.lvl.set message of out_lvl_switch service.
+type OutLvlSwitchSet struct {
+	Value    int64  `fimp:"val"`
+	Duration string `fimp:"prop:duration"`
+}
+
+// Set creates cmd.lvl.set message of out_lvl_switch service.
+func (OutLvlSwitchService) Set(value int64, duration string) *fimpgo.FimpMessage {
+	props := newProps("duration", duration)
+	return fimpgo.NewIntMessage(IntfCmdLvlSet, ServiceOutLvlSwitch, value, props, nil, nil)
+}
+
+// ParseSet decodes cmd.lvl.set message of out_lvl_switch service.
+func (OutLvlSwitchService) ParseSet(msg *fimpgo.FimpMessage) (OutLvlSwitchSet, error) {
+	return decode[OutLvlSwitchSet](msg, ServiceOutLvlSwitch, IntfCmdLvlSet)
+}
+
+// GetReport creates cmd.lvl.get_report message of out_lvl_switch service.
+func (OutLvlSwitchService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdLvlGetReport, ServiceOutLvlSwitch, nil, nil, nil)
+}
+
+// Report creates evt.lvl.report message of out_lvl_switch service.
+func (OutLvlSwitchService) Report(value int64) *fimpgo.FimpMessage {
+	return fimpgo.NewIntMessage(IntfEvtLvlReport, ServiceOutLvlSwitch, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.lvl.report message of out_lvl_switch service.
+func (OutLvlSwitchService) ParseReport(msg *fimpgo.FimpMessage) (int64, error) {
+	return decode[int64](msg, ServiceOutLvlSwitch, IntfEvtLvlReport)
+}
+
+// OutLvlSwitchStart is decoded cmd.lvl.start message of out_lvl_switch service.
+type OutLvlSwitchStart struct {
+	Value    string `fimp:"val"`
+	StartLvl string `fimp:"prop:start_lvl"`
+	Duration string `fimp:"prop:duration"`
+}
+
+// Start creates cmd.lvl.start message of out_lvl_switch service.
+func (OutLvlSwitchService) Start(value string, startLvl, duration string) *fimpgo.FimpMessage {
+	props := newProps("start_lvl", startLvl, "duration", duration)
+	return fimpgo.NewStringMessage(IntfCmdLvlStart, ServiceOutLvlSwitch, value, props, nil, nil)
+}
+
+// ParseStart decodes cmd.lvl.start message of out_lvl_switch service.
+func (OutLvlSwitchService) ParseStart(msg *fimpgo.FimpMessage) (OutLvlSwitchStart, error) {
+	return decode[OutLvlSwitchStart](msg, ServiceOutLvlSwitch, IntfCmdLvlStart)
+}
+
+// Stop creates cmd.lvl.stop message of out_lvl_switch service.
+func (OutLvlSwitchService) Stop() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdLvlStop, ServiceOutLvlSwitch, nil, nil, nil)
+}
+
+// SetBinary creates cmd.binary.set message of out_lvl_switch service.
+func (OutLvlSwitchService) SetBinary(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfCmdBinarySet, ServiceOutLvlSwitch, value, nil, nil, nil)
+}
+
+// ParseSetBinary decodes value of cmd.binary.set message of out_lvl_switch service.
+func (OutLvlSwitchService) ParseSetBinary(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceOutLvlSwitch, IntfCmdBinarySet)
+}
+
+// BinaryReport creates evt.binary.report message of out_lvl_switch service.
+func (OutLvlSwitchService) BinaryReport(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfEvtBinaryReport, ServiceOutLvlSwitch, value, nil, nil, nil)
+}
+
+// ParseBinaryReport decodes value of evt.binary.report message of out_lvl_switch service.
+func (OutLvlSwitchService) ParseBinaryReport(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceOutLvlSwitch, IntfEvtBinaryReport)
+}
+
+// SensorTempService is sensor_temp service , temperature sensor.
+type SensorTempService struct{}
+
+// SensorTemp describes sensor_temp service.
+var SensorTemp SensorTempService
+
+var sensorTempProps = map[string]interface{}{
+	"sup_units": []string{"C", "F"},
+}
+
+// Name returns service name.
+func (SensorTempService) Name() string {
+	return ServiceSensorTemp
+}
+
+// Interfaces returns interfaces of sensor_temp service.
+func (SensorTempService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSensorGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSensorReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+	}
+}
+
+// Service returns sensor_temp service specification with given address.
+func (s SensorTempService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorTemp, address, sensorTempProps, s.Interfaces())
+}
+
+// GetReport creates cmd.sensor.get_report message of sensor_temp service.
+func (SensorTempService) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSensorGetReport, ServiceSensorTemp, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.sensor.get_report message of sensor_temp service.
+func (SensorTempService) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSensorTemp, IntfCmdSensorGetReport)
+}
+
+// SensorTempReport is decoded evt.sensor.report message of sensor_temp service.
+type SensorTempReport struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.sensor.report message of sensor_temp service.
+func (SensorTempService) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtSensorReport, ServiceSensorTemp, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.sensor.report message of sensor_temp service.
+func (SensorTempService) ParseReport(msg *fimpgo.FimpMessage) (SensorTempReport, error) {
+	return decode[SensorTempReport](msg, ServiceSensorTemp, IntfEvtSensorReport)
+}
+
+// SensorHumidService is sensor_humid service , relative humidity sensor.
+type SensorHumidService struct{}
+
+// SensorHumid describes sensor_humid service.
+var SensorHumid SensorHumidService
+
+var sensorHumidProps = map[string]interface{}{
+	"sup_units": []string{"%"},
+}
+
+// Name returns service name.
+func (SensorHumidService) Name() string {
+	return ServiceSensorHumid
+}
+
+// Interfaces returns interfaces of sensor_humid service.
+func (SensorHumidService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSensorGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSensorReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+	}
+}
+
+// Service returns sensor_humid service specification with given address.
+func (s SensorHumidService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorHumid, address, sensorHumidProps, s.Interfaces())
+}
+
+// GetReport creates cmd.sensor.get_report message of sensor_humid service.
+func (SensorHumidService) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSensorGetReport, ServiceSensorHumid, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.sensor.get_report message of sensor_humid service.
+func (SensorHumidService) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSensorHumid, IntfCmdSensorGetReport)
+}
+
+// SensorHumidReport is decoded evt.sensor.report message of sensor_humid service.
+type SensorHumidReport struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.sensor.report message of sensor_humid service.
+func (SensorHumidService) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtSensorReport, ServiceSensorHumid, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.sensor.report message of sensor_humid service.
+func (SensorHumidService) ParseReport(msg *fimpgo.FimpMessage) (SensorHumidReport, error) {
+	return decode[SensorHumidReport](msg, ServiceSensorHumid, IntfEvtSensorReport)
+}
+
+// SensorLuminService is sensor_lumin service , luminance sensor.
+type SensorLuminService struct{}
+
+// SensorLumin describes sensor_lumin service.
+var SensorLumin SensorLuminService
+
+var sensorLuminProps = map[string]interface{}{
+	"sup_units": []string{"Lux"},
+}
+
+// Name returns service name.
+func (SensorLuminService) Name() string {
+	return ServiceSensorLumin
+}
+
+// Interfaces returns interfaces of sensor_lumin service.
+func (SensorLuminService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSensorGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSensorReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+	}
+}
+
+// Service returns sensor_lumin service specification with given address.
+func (s SensorLuminService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorLumin, address, sensorLuminProps, s.Interfaces())
+}
+
+// GetReport creates cmd.sensor.get_report message of sensor_lumin service.
+func (SensorLuminService) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSensorGetReport, ServiceSensorLumin, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.sensor.get_report message of sensor_lumin service.
+func (SensorLuminService) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSensorLumin, IntfCmdSensorGetReport)
+}
+
+// SensorLuminReport is decoded evt.sensor.report message of sensor_lumin service.
+type SensorLuminReport struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.sensor.report message of sensor_lumin service.
+func (SensorLuminService) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtSensorReport, ServiceSensorLumin, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.sensor.report message of sensor_lumin service.
+func (SensorLuminService) ParseReport(msg *fimpgo.FimpMessage) (SensorLuminReport, error) {
+	return decode[SensorLuminReport](msg, ServiceSensorLumin, IntfEvtSensorReport)
+}
+
+// SensorCO2Service is sensor_co2 service , carbon dioxide level sensor.
+type SensorCO2Service struct{}
+
+// SensorCO2 describes sensor_co2 service.
+var SensorCO2 SensorCO2Service
+
+var sensorCO2Props = map[string]interface{}{
+	"sup_units": []string{"ppm"},
+}
+
+// Name returns service name.
+func (SensorCO2Service) Name() string {
+	return ServiceSensorCO2
+}
+
+// Interfaces returns interfaces of sensor_co2 service.
+func (SensorCO2Service) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSensorGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSensorReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+	}
+}
+
+// Service returns sensor_co2 service specification with given address.
+func (s SensorCO2Service) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorCO2, address, sensorCO2Props, s.Interfaces())
+}
+
+// GetReport creates cmd.sensor.get_report message of sensor_co2 service.
+func (SensorCO2Service) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSensorGetReport, ServiceSensorCO2, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.sensor.get_report message of sensor_co2 service.
+func (SensorCO2Service) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSensorCO2, IntfCmdSensorGetReport)
+}
+
+// SensorCO2Report is decoded evt.sensor.report message of sensor_co2 service.
+type SensorCO2Report struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.sensor.report message of sensor_co2 service.
+func (SensorCO2Service) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtSensorReport, ServiceSensorCO2, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.sensor.report message of sensor_co2 service.
+func (SensorCO2Service) ParseReport(msg *fimpgo.FimpMessage) (SensorCO2Report, error) {
+	return decode[SensorCO2Report](msg, ServiceSensorCO2, IntfEvtSensorReport)
+}
+
+// SensorAtmoService is sensor_atmo service , atmospheric pressure sensor.
+type SensorAtmoService struct{}
+
+// SensorAtmo describes sensor_atmo service.
+var SensorAtmo SensorAtmoService
+
+var sensorAtmoProps = map[string]interface{}{
+	"sup_units": []string{"kPa", "hPa", "Bar"},
+}
+
+// Name returns service name.
+func (SensorAtmoService) Name() string {
+	return ServiceSensorAtmo
+}
+
+// Interfaces returns interfaces of sensor_atmo service.
+func (SensorAtmoService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSensorGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSensorReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+	}
+}
+
+// Service returns sensor_atmo service specification with given address.
+func (s SensorAtmoService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorAtmo, address, sensorAtmoProps, s.Interfaces())
+}
+
+// GetReport creates cmd.sensor.get_report message of sensor_atmo service.
+func (SensorAtmoService) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSensorGetReport, ServiceSensorAtmo, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.sensor.get_report message of sensor_atmo service.
+func (SensorAtmoService) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSensorAtmo, IntfCmdSensorGetReport)
+}
+
+// SensorAtmoReport is decoded evt.sensor.report message of sensor_atmo service.
+type SensorAtmoReport struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.sensor.report message of sensor_atmo service.
+func (SensorAtmoService) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtSensorReport, ServiceSensorAtmo, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.sensor.report message of sensor_atmo service.
+func (SensorAtmoService) ParseReport(msg *fimpgo.FimpMessage) (SensorAtmoReport, error) {
+	return decode[SensorAtmoReport](msg, ServiceSensorAtmo, IntfEvtSensorReport)
+}
+
+// SensorPresenceService is sensor_presence service , presence or motion sensor.
+type SensorPresenceService struct{}
+
+// SensorPresence describes sensor_presence service.
+var SensorPresence SensorPresenceService
+
+// Name returns service name.
+func (SensorPresenceService) Name() string {
+	return ServiceSensorPresence
+}
+
+// Interfaces returns interfaces of sensor_presence service.
+func (SensorPresenceService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdPresenceGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtPresenceReport, MsgType: "out", ValueType: fimpgo.VTypeBool, Version: "1"},
+	}
+}
+
+// Service returns sensor_presence service specification with given address.
+func (s SensorPresenceService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorPresence, address, nil, s.Interfaces())
+}
+
+// GetReport creates cmd.presence.get_report message of sensor_presence service.
+func (SensorPresenceService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdPresenceGetReport, ServiceSensorPresence, nil, nil, nil)
+}
+
+// Report creates evt.presence.report message of sensor_presence service.
+func (SensorPresenceService) Report(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfEvtPresenceReport, ServiceSensorPresence, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.presence.report message of sensor_presence service.
+func (SensorPresenceService) ParseReport(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceSensorPresence, IntfEvtPresenceReport)
+}
+
+// SensorContactService is sensor_contact service , door or window contact sensor.
+type SensorContactService struct{}
+
+// SensorContact describes sensor_contact service.
+var SensorContact SensorContactService
+
+// Name returns service name.
+func (SensorContactService) Name() string {
+	return ServiceSensorContact
+}
+
+// Interfaces returns interfaces of sensor_contact service.
+func (SensorContactService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdOpenGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtOpenReport, MsgType: "out", ValueType: fimpgo.VTypeBool, Version: "1"},
+	}
+}
+
+// Service returns sensor_contact service specification with given address.
+func (s SensorContactService) Service(address string) fimptype.Service {
+	return buildService(ServiceSensorContact, address, nil, s.Interfaces())
+}
+
+// GetReport creates cmd.open.get_report message of sensor_contact service.
+func (SensorContactService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdOpenGetReport, ServiceSensorContact, nil, nil, nil)
+}
+
+// Report creates evt.open.report message of sensor_contact service.
+func (SensorContactService) Report(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfEvtOpenReport, ServiceSensorContact, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.open.report message of sensor_contact service.
+func (SensorContactService) ParseReport(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceSensorContact, IntfEvtOpenReport)
+}
+
+// MeterElecService is meter_elec service , electricity meter.
+type MeterElecService struct{}
+
+// MeterElec describes meter_elec service.
+var MeterElec MeterElecService
+
+var meterElecProps = map[string]interface{}{
+	"sup_units": []string{"kWh", "W", "V", "A"},
+}
+
+// Name returns service name.
+func (MeterElecService) Name() string {
+	return ServiceMeterElec
+}
+
+// Interfaces returns interfaces of meter_elec service.
+func (MeterElecService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdMeterGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtMeterReport, MsgType: "out", ValueType: fimpgo.VTypeFloat, Version: "1"},
+		{Type: IntfCmdMeterReset, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+	}
+}
+
+// Service returns meter_elec service specification with given address.
+func (s MeterElecService) Service(address string) fimptype.Service {
+	return buildService(ServiceMeterElec, address, meterElecProps, s.Interfaces())
+}
+
+// GetReport creates cmd.meter.get_report message of meter_elec service.
+func (MeterElecService) GetReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdMeterGetReport, ServiceMeterElec, value, nil, nil, nil)
+}
+
+// ParseGetReport decodes value of cmd.meter.get_report message of meter_elec service.
+func (MeterElecService) ParseGetReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceMeterElec, IntfCmdMeterGetReport)
+}
+
+// MeterElecReport is decoded evt.meter.report message of meter_elec service.
+type MeterElecReport struct {
+	Value float64 `fimp:"val"`
+	Unit  string  `fimp:"prop:unit"`
+}
+
+// Report creates evt.meter.report message of meter_elec service.
+func (MeterElecService) Report(value float64, unit string) *fimpgo.FimpMessage {
+	props := newProps("unit", unit)
+	return fimpgo.NewFloatMessage(IntfEvtMeterReport, ServiceMeterElec, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.meter.report message of meter_elec service.
+func (MeterElecService) ParseReport(msg *fimpgo.FimpMessage) (MeterElecReport, error) {
+	return decode[MeterElecReport](msg, ServiceMeterElec, IntfEvtMeterReport)
+}
+
+// Reset creates cmd.meter.reset message of meter_elec service.
+func (MeterElecService) Reset() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdMeterReset, ServiceMeterElec, nil, nil, nil)
+}
+
+// BatteryService is battery service , battery level of battery powered device.
+type BatteryService struct{}
+
+// Battery describes battery service.
+var Battery BatteryService
+
+// Name returns service name.
+func (BatteryService) Name() string {
+	return ServiceBattery
+}
+
+// Interfaces returns interfaces of battery service.
+func (BatteryService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdLvlGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtLvlReport, MsgType: "out", ValueType: fimpgo.VTypeInt, Version: "1"},
+		{Type: IntfEvtAlarmReport, MsgType: "out", ValueType: fimpgo.VTypeStrMap, Version: "1"},
+	}
+}
+
+// Service returns battery service specification with given address.
+func (s BatteryService) Service(address string) fimptype.Service {
+	return buildService(ServiceBattery, address, nil, s.Interfaces())
+}
+
+// GetReport creates cmd.lvl.get_report message of battery service.
+func (BatteryService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdLvlGetReport, ServiceBattery, nil, nil, nil)
+}
+
+// BatteryReport is decoded evt.lvl.report message of battery service.
+type BatteryReport struct {
+	Value int64  `fimp:"val"`
+	State string `fimp:"prop:state"`
+}
+
+// Report creates evt.lvl.report message of battery service.
+func (BatteryService) Report(value int64, state string) *fimpgo.FimpMessage {
+	props := newProps("state", state)
+	return fimpgo.NewIntMessage(IntfEvtLvlReport, ServiceBattery, value, props, nil, nil)
+}
+
+// ParseReport decodes evt.lvl.report message of battery service.
+func (BatteryService) ParseReport(msg *fimpgo.FimpMessage) (BatteryReport, error) {
+	return decode[BatteryReport](msg, ServiceBattery, IntfEvtLvlReport)
+}
+
+// AlarmReport creates evt.alarm.report message of battery service.
+func (BatteryService) AlarmReport(value map[string]string) *fimpgo.FimpMessage {
+	return fimpgo.NewStrMapMessage(IntfEvtAlarmReport, ServiceBattery, value, nil, nil, nil)
+}
+
+// ParseAlarmReport decodes value of evt.alarm.report message of battery service.
+func (BatteryService) ParseAlarmReport(msg *fimpgo.FimpMessage) (map[string]string, error) {
+	return decode[map[string]string](msg, ServiceBattery, IntfEvtAlarmReport)
+}
+
+// AlarmFireService is alarm_fire service , fire or smoke alarm.
+type AlarmFireService struct{}
+
+// AlarmFire describes alarm_fire service.
+var AlarmFire AlarmFireService
+
+// Name returns service name.
+func (AlarmFireService) Name() string {
+	return ServiceAlarmFire
+}
+
+// Interfaces returns interfaces of alarm_fire service.
+func (AlarmFireService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdAlarmGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtAlarmReport, MsgType: "out", ValueType: fimpgo.VTypeStrMap, Version: "1"},
+	}
+}
+
+// Service returns alarm_fire service specification with given address.
+func (s AlarmFireService) Service(address string) fimptype.Service {
+	return buildService(ServiceAlarmFire, address, nil, s.Interfaces())
+}
+
+// GetReport creates cmd.alarm.get_report message of alarm_fire service.
+func (AlarmFireService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdAlarmGetReport, ServiceAlarmFire, nil, nil, nil)
+}
+
+// Report creates evt.alarm.report message of alarm_fire service.
+func (AlarmFireService) Report(value map[string]string) *fimpgo.FimpMessage {
+	return fimpgo.NewStrMapMessage(IntfEvtAlarmReport, ServiceAlarmFire, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.alarm.report message of alarm_fire service.
+func (AlarmFireService) ParseReport(msg *fimpgo.FimpMessage) (map[string]string, error) {
+	return decode[map[string]string](msg, ServiceAlarmFire, IntfEvtAlarmReport)
+}
+
+// AlarmWaterService is alarm_water service , water leak alarm.
+type AlarmWaterService struct{}
+
+// AlarmWater describes alarm_water service.
+var AlarmWater AlarmWaterService
+
+// Name returns service name.
+func (AlarmWaterService) Name() string {
+	return ServiceAlarmWater
+}
+
+// Interfaces returns interfaces of alarm_water service.
+func (AlarmWaterService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdAlarmGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtAlarmReport, MsgType: "out", ValueType: fimpgo.VTypeStrMap, Version: "1"},
+	}
+}
+
+// Service returns alarm_water service specification with given address.
+func (s AlarmWaterService) Service(address string) fimptype.Service {
+	return buildService(ServiceAlarmWater, address, nil, s.Interfaces())
+}
+
+// GetReport creates cmd.alarm.get_report message of alarm_water service.
+func (AlarmWaterService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdAlarmGetReport, ServiceAlarmWater, nil, nil, nil)
+}
+
+// Report creates evt.alarm.report message of alarm_water service.
+func (AlarmWaterService) Report(value map[string]string) *fimpgo.FimpMessage {
+	return fimpgo.NewStrMapMessage(IntfEvtAlarmReport, ServiceAlarmWater, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.alarm.report message of alarm_water service.
+func (AlarmWaterService) ParseReport(msg *fimpgo.FimpMessage) (map[string]string, error) {
+	return decode[map[string]string](msg, ServiceAlarmWater, IntfEvtAlarmReport)
+}
+
+// ThermostatService is thermostat service , thermostat with operating modes and setpoints.
+type ThermostatService struct{}
+
+// Thermostat describes thermostat service.
+var Thermostat ThermostatService
+
+var thermostatProps = map[string]interface{}{
+	"sup_modes":     []string{"off", "heat", "cool", "auto"},
+	"sup_setpoints": []string{"heat", "cool"},
+}
+
+// Name returns service name.
+func (ThermostatService) Name() string {
+	return ServiceThermostat
+}
+
+// Interfaces returns interfaces of thermostat service.
+func (ThermostatService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdModeSet, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfCmdModeGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtModeReport, MsgType: "out", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfCmdSetpointSet, MsgType: "in", ValueType: fimpgo.VTypeStrMap, Version: "1"},
+		{Type: IntfCmdSetpointGetReport, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfEvtSetpointReport, MsgType: "out", ValueType: fimpgo.VTypeStrMap, Version: "1"},
+		{Type: IntfCmdStateGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtStateReport, MsgType: "out", ValueType: fimpgo.VTypeString, Version: "1"},
+	}
+}
+
+// Service returns thermostat service specification with given address.
+func (s ThermostatService) Service(address string) fimptype.Service {
+	return buildService(ServiceThermostat, address, thermostatProps, s.Interfaces())
+}
+
+// SetMode creates cmd.mode.set message of thermostat service.
+func (ThermostatService) SetMode(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdModeSet, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseSetMode decodes value of cmd.mode.set message of thermostat service.
+func (ThermostatService) ParseSetMode(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceThermostat, IntfCmdModeSet)
+}
+
+// GetModeReport creates cmd.mode.get_report message of thermostat service.
+func (ThermostatService) GetModeReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdModeGetReport, ServiceThermostat, nil, nil, nil)
+}
+
+// ModeReport creates evt.mode.report message of thermostat service.
+func (ThermostatService) ModeReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfEvtModeReport, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseModeReport decodes value of evt.mode.report message of thermostat service.
+func (ThermostatService) ParseModeReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceThermostat, IntfEvtModeReport)
+}
+
+// SetSetpoint creates cmd.setpoint.set message of thermostat service.
+func (ThermostatService) SetSetpoint(value map[string]string) *fimpgo.FimpMessage {
+	return fimpgo.NewStrMapMessage(IntfCmdSetpointSet, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseSetSetpoint decodes value of cmd.setpoint.set message of thermostat service.
+func (ThermostatService) ParseSetSetpoint(msg *fimpgo.FimpMessage) (map[string]string, error) {
+	return decode[map[string]string](msg, ServiceThermostat, IntfCmdSetpointSet)
+}
+
+// GetSetpointReport creates cmd.setpoint.get_report message of thermostat service.
+func (ThermostatService) GetSetpointReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSetpointGetReport, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseGetSetpointReport decodes value of cmd.setpoint.get_report message of thermostat service.
+func (ThermostatService) ParseGetSetpointReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceThermostat, IntfCmdSetpointGetReport)
+}
+
+// SetpointReport creates evt.setpoint.report message of thermostat service.
+func (ThermostatService) SetpointReport(value map[string]string) *fimpgo.FimpMessage {
+	return fimpgo.NewStrMapMessage(IntfEvtSetpointReport, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseSetpointReport decodes value of evt.setpoint.report message of thermostat service.
+func (ThermostatService) ParseSetpointReport(msg *fimpgo.FimpMessage) (map[string]string, error) {
+	return decode[map[string]string](msg, ServiceThermostat, IntfEvtSetpointReport)
+}
+
+// GetStateReport creates cmd.state.get_report message of thermostat service.
+func (ThermostatService) GetStateReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdStateGetReport, ServiceThermostat, nil, nil, nil)
+}
+
+// StateReport creates evt.state.report message of thermostat service.
+func (ThermostatService) StateReport(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfEvtStateReport, ServiceThermostat, value, nil, nil, nil)
+}
+
+// ParseStateReport decodes value of evt.state.report message of thermostat service.
+func (ThermostatService) ParseStateReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceThermostat, IntfEvtStateReport)
+}
+
+// ColorCtrlService is color_ctrl service , color control of lights.
+type ColorCtrlService struct{}
+
+// ColorCtrl describes color_ctrl service.
+var ColorCtrl ColorCtrlService
+
+var colorCtrlProps = map[string]interface{}{
+	"sup_components": []string{"red", "green", "blue", "warm_w", "cold_w", "temp"},
+}
+
+// Name returns service name.
+func (ColorCtrlService) Name() string {
+	return ServiceColorCtrl
+}
+
+// Interfaces returns interfaces of color_ctrl service.
+func (ColorCtrlService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdColorSet, MsgType: "in", ValueType: fimpgo.VTypeIntMap, Version: "1"},
+		{Type: IntfCmdColorGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtColorReport, MsgType: "out", ValueType: fimpgo.VTypeIntMap, Version: "1"},
+	}
+}
+
+// Service returns color_ctrl service specification with given address.
+func (s ColorCtrlService) Service(address string) fimptype.Service {
+	return buildService(ServiceColorCtrl, address, colorCtrlProps, s.Interfaces())
+}
+
+// ColorCtrlSet is decoded cmd.color.set message of color_ctrl service.
+type ColorCtrlSet struct {
+	Value    map[string]int64 `fimp:"val"`
+	Duration string           `fimp:"prop:duration"`
+}
+
+// Set creates cmd.color.set message of color_ctrl service.
+func (ColorCtrlService) Set(value map[string]int64, duration string) *fimpgo.FimpMessage {
+	props := newProps("duration", duration)
+	return fimpgo.NewIntMapMessage(IntfCmdColorSet, ServiceColorCtrl, value, props, nil, nil)
+}
+
+// ParseSet decodes cmd.color.set message of color_ctrl service.
+func (ColorCtrlService) ParseSet(msg *fimpgo.FimpMessage) (ColorCtrlSet, error) {
+	return decode[ColorCtrlSet](msg, ServiceColorCtrl, IntfCmdColorSet)
+}
+
+// GetReport creates cmd.color.get_report message of color_ctrl service.
+func (ColorCtrlService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdColorGetReport, ServiceColorCtrl, nil, nil, nil)
+}
+
+// Report creates evt.color.report message of color_ctrl service.
+func (ColorCtrlService) Report(value map[string]int64) *fimpgo.FimpMessage {
+	return fimpgo.NewIntMapMessage(IntfEvtColorReport, ServiceColorCtrl, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.color.report message of color_ctrl service.
+func (ColorCtrlService) ParseReport(msg *fimpgo.FimpMessage) (map[string]int64, error) {
+	return decode[map[string]int64](msg, ServiceColorCtrl, IntfEvtColorReport)
+}
+
+// DoorLockService is door_lock service , door lock.
+type DoorLockService struct{}
+
+// DoorLock describes door_lock service.
+var DoorLock DoorLockService
+
+// Name returns service name.
+func (DoorLockService) Name() string {
+	return ServiceDoorLock
+}
+
+// Interfaces returns interfaces of door_lock service.
+func (DoorLockService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdLockSet, MsgType: "in", ValueType: fimpgo.VTypeBool, Version: "1"},
+		{Type: IntfCmdLockGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtLockReport, MsgType: "out", ValueType: fimpgo.VTypeBoolMap, Version: "1"},
+	}
+}
+
+// Service returns door_lock service specification with given address.
+func (s DoorLockService) Service(address string) fimptype.Service {
+	return buildService(ServiceDoorLock, address, nil, s.Interfaces())
+}
+
+// Set creates cmd.lock.set message of door_lock service.
+func (DoorLockService) Set(value bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMessage(IntfCmdLockSet, ServiceDoorLock, value, nil, nil, nil)
+}
+
+// ParseSet decodes value of cmd.lock.set message of door_lock service.
+func (DoorLockService) ParseSet(msg *fimpgo.FimpMessage) (bool, error) {
+	return decode[bool](msg, ServiceDoorLock, IntfCmdLockSet)
+}
+
+// GetReport creates cmd.lock.get_report message of door_lock service.
+func (DoorLockService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdLockGetReport, ServiceDoorLock, nil, nil, nil)
+}
+
+// Report creates evt.lock.report message of door_lock service.
+func (DoorLockService) Report(value map[string]bool) *fimpgo.FimpMessage {
+	return fimpgo.NewBoolMapMessage(IntfEvtLockReport, ServiceDoorLock, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.lock.report message of door_lock service.
+func (DoorLockService) ParseReport(msg *fimpgo.FimpMessage) (map[string]bool, error) {
+	return decode[map[string]bool](msg, ServiceDoorLock, IntfEvtLockReport)
+}
+
+// SceneCtrlService is scene_ctrl service , scene controller , for instance wall remote.
+type SceneCtrlService struct{}
+
+// SceneCtrl describes scene_ctrl service.
+var SceneCtrl SceneCtrlService
+
+var sceneCtrlProps = map[string]interface{}{
+	"sup_scenes": []string{"1", "2", "3", "4"},
+}
+
+// Name returns service name.
+func (SceneCtrlService) Name() string {
+	return ServiceSceneCtrl
+}
+
+// Interfaces returns interfaces of scene_ctrl service.
+func (SceneCtrlService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdSceneSet, MsgType: "in", ValueType: fimpgo.VTypeString, Version: "1"},
+		{Type: IntfCmdSceneGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtSceneReport, MsgType: "out", ValueType: fimpgo.VTypeString, Version: "1"},
+	}
+}
+
+// Service returns scene_ctrl service specification with given address.
+func (s SceneCtrlService) Service(address string) fimptype.Service {
+	return buildService(ServiceSceneCtrl, address, sceneCtrlProps, s.Interfaces())
+}
+
+// Set creates cmd.scene.set message of scene_ctrl service.
+func (SceneCtrlService) Set(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfCmdSceneSet, ServiceSceneCtrl, value, nil, nil, nil)
+}
+
+// ParseSet decodes value of cmd.scene.set message of scene_ctrl service.
+func (SceneCtrlService) ParseSet(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSceneCtrl, IntfCmdSceneSet)
+}
+
+// GetReport creates cmd.scene.get_report message of scene_ctrl service.
+func (SceneCtrlService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdSceneGetReport, ServiceSceneCtrl, nil, nil, nil)
+}
+
+// Report creates evt.scene.report message of scene_ctrl service.
+func (SceneCtrlService) Report(value string) *fimpgo.FimpMessage {
+	return fimpgo.NewStringMessage(IntfEvtSceneReport, ServiceSceneCtrl, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.scene.report message of scene_ctrl service.
+func (SceneCtrlService) ParseReport(msg *fimpgo.FimpMessage) (string, error) {
+	return decode[string](msg, ServiceSceneCtrl, IntfEvtSceneReport)
+}
+
+// BasicService is basic service , generic level control of devices without specific service.
+type BasicService struct{}
+
+// Basic describes basic service.
+var Basic BasicService
+
+// Name returns service name.
+func (BasicService) Name() string {
+	return ServiceBasic
+}
+
+// Interfaces returns interfaces of basic service.
+func (BasicService) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+		{Type: IntfCmdLvlSet, MsgType: "in", ValueType: fimpgo.VTypeInt, Version: "1"},
+		{Type: IntfCmdLvlGetReport, MsgType: "in", ValueType: fimpgo.VTypeNull, Version: "1"},
+		{Type: IntfEvtLvlReport, MsgType: "out", ValueType: fimpgo.VTypeInt, Version: "1"},
+	}
+}
+
+// Service returns basic service specification with given address.
+func (s BasicService) Service(address string) fimptype.Service {
+	return buildService(ServiceBasic, address, nil, s.Interfaces())
+}
+
+// Set creates cmd.lvl.set message of basic service.
+func (BasicService) Set(value int64) *fimpgo.FimpMessage {
+	return fimpgo.NewIntMessage(IntfCmdLvlSet, ServiceBasic, value, nil, nil, nil)
+}
+
+// ParseSet decodes value of cmd.lvl.set message of basic service.
+func (BasicService) ParseSet(msg *fimpgo.FimpMessage) (int64, error) {
+	return decode[int64](msg, ServiceBasic, IntfCmdLvlSet)
+}
+
+// GetReport creates cmd.lvl.get_report message of basic service.
+func (BasicService) GetReport() *fimpgo.FimpMessage {
+	return fimpgo.NewNullMessage(IntfCmdLvlGetReport, ServiceBasic, nil, nil, nil)
+}
+
+// Report creates evt.lvl.report message of basic service.
+func (BasicService) Report(value int64) *fimpgo.FimpMessage {
+	return fimpgo.NewIntMessage(IntfEvtLvlReport, ServiceBasic, value, nil, nil, nil)
+}
+
+// ParseReport decodes value of evt.lvl.report message of basic service.
+func (BasicService) ParseReport(msg *fimpgo.FimpMessage) (int64, error) {
+	return decode[int64](msg, ServiceBasic, IntfEvtLvlReport)
+}
@@ -0,0 +1,73 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+)
+
+func TestSensorTemp_Report(t *testing.T) {
+	msg := SensorTemp.Report(21.5, "C")
+	assert.Equal(t, "evt.sensor.report", msg.Type)
+	assert.Equal(t, "sensor_temp", msg.Service)
+	assert.Equal(t, fimpgo.VTypeFloat, msg.ValueType)
+	assert.Equal(t, "C", msg.Properties["unit"])
+
+	data, err := msg.SerializeToJson()
+	require.NoError(t, err)
+	parsed, err := fimpgo.NewMessageFromBytes(data)
+	require.NoError(t, err)
+
+	report, err := SensorTemp.ParseReport(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, SensorTempReport{Value: 21.5, Unit: "C"}, report)
+}
+
+func TestOutLvlSwitch_ParseSet(t *testing.T) {
+	msg := OutLvlSwitch.Set(50, "")
+	assert.Nil(t, msg.Properties)
+
+	set, err := OutLvlSwitch.ParseSet(fimpgo.NewIntMessage("cmd.lvl.set", "out_lvl_switch", 70, fimpgo.Props{"duration": "5"}, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, OutLvlSwitchSet{Value: 70, Duration: "5"}, set)
+
+	_, err = OutLvlSwitch.ParseSet(fimpgo.NewIntMessage("evt.lvl.report", "out_lvl_switch", 70, nil, nil, nil))
+	assert.Error(t, err)
+
+	_, err = OutLvlSwitch.ParseSet(fimpgo.NewIntMessage("cmd.lvl.set", "basic", 70, nil, nil, nil))
+	assert.Error(t, err)
+
+	_, err = OutLvlSwitch.ParseSet(fimpgo.NewStringMessage("cmd.lvl.set", "out_lvl_switch", "70", nil, nil, nil))
+	assert.True(t, fimpgo.IsWrongValueType(err))
+}
+
+func TestInterfaces(t *testing.T) {
+	interfaces := Interfaces("out_bin_switch")
+	require.Len(t, interfaces, 3)
+	assert.Equal(t, "cmd.binary.set", interfaces[0].Type)
+	assert.Equal(t, "in", interfaces[0].MsgType)
+	assert.Equal(t, "bool", interfaces[0].ValueType)
+	assert.Nil(t, Interfaces("unknown"))
+
+	s, ok := Lookup("sensor_temp")
+	require.True(t, ok)
+	service := s.Service("/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1")
+	assert.Equal(t, "sensor_temp", service.Name)
+	assert.Equal(t, []string{"C", "F"}, service.PropertyStrings("sup_units"))
+	assert.Contains(t, Names(), "thermostat")
+}
+
+func TestCatalog_SchemaValidation(t *testing.T) {
+	v := fimpgo.NewSchemaValidator(fimpgo.ValidationStrict)
+	for _, name := range Names() {
+		v.SetServiceInterfaces(name, Interfaces(name))
+	}
+	addr, err := fimpgo.NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1")
+	require.NoError(t, err)
+
+	assert.NoError(t, v.Validate(addr, SensorTemp.Report(21.5, "C")))
+	assert.True(t, fimpgo.IsSchemaViolation(v.Validate(addr, fimpgo.NewStringMessage("evt.sensor.report", "sensor_temp", "21.5", nil, nil, nil))))
+}
@@ -0,0 +1,298 @@
+// Command gen generates catalog service definitions from services.json.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+type (
+	serviceSpec struct {
+		Name       string                 `json:"name"`
+		Type       string                 `json:"type"`
+		Doc        string                 `json:"doc"`
+		Props      map[string]interface{} `json:"props"`
+		Interfaces []interfaceSpec        `json:"interfaces"`
+	}
+
+	interfaceSpec struct {
+		Type      string   `json:"intf_t"`
+		MsgType   string   `json:"msg_t"`
+		ValueType string   `json:"val_t"`
+		Version   string   `json:"ver"`
+		Method    string   `json:"method"`
+		Props     []string `json:"props"`
+	}
+
+	valueType struct {
+		GoType      string
+		Constructor string
+	}
+)
+
+var valueTypes = map[string]valueType{
+	"string":      {GoType: "string", Constructor: "NewStringMessage"},
+	"int":         {GoType: "int64", Constructor: "NewIntMessage"},
+	"float":       {GoType: "float64", Constructor: "NewFloatMessage"},
+	"bool":        {GoType: "bool", Constructor: "NewBoolMessage"},
+	"str_map":     {GoType: "map[string]string", Constructor: "NewStrMapMessage"},
+	"int_map":     {GoType: "map[string]int64", Constructor: "NewIntMapMessage"},
+	"float_map":   {GoType: "map[string]float64", Constructor: "NewFloatMapMessage"},
+	"bool_map":    {GoType: "map[string]bool", Constructor: "NewBoolMapMessage"},
+	"str_array":   {GoType: "[]string", Constructor: "NewStrArrayMessage"},
+	"int_array":   {GoType: "[]int64", Constructor: "NewIntArrayMessage"},
+	"float_array": {GoType: "[]float64", Constructor: "NewFloatArrayMessage"},
+	"bool_array":  {GoType: "[]bool", Constructor: "NewBoolArrayMessage"},
+	"null":        {},
+}
+
+var vTypeConsts = map[string]string{
+	"string":      "VTypeString",
+	"int":         "VTypeInt",
+	"float":       "VTypeFloat",
+	"bool":        "VTypeBool",
+	"str_map":     "VTypeStrMap",
+	"int_map":     "VTypeIntMap",
+	"float_map":   "VTypeFloatMap",
+	"bool_map":    "VTypeBoolMap",
+	"str_array":   "VTypeStrArray",
+	"int_array":   "VTypeIntArray",
+	"float_array": "VTypeFloatArray",
+	"bool_array":  "VTypeBoolArray",
+	"null":        "VTypeNull",
+}
+
+func main() {
+	in := flag.String("in", "services.json", "service specification file")
+	out := flag.String("out", "catalog_gen.go", "generated file")
+	flag.Parse()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var services []serviceSpec
+	if err = json.Unmarshal(data, &services); err != nil {
+		log.Fatal(err)
+	}
+	src, err := generate(services)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = os.WriteFile(*out, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func generate(services []serviceSpec) ([]byte, error) {
+	intfTypes := map[string]struct{}{}
+	for i := range services {
+		s := &services[i]
+		for j := range s.Interfaces {
+			intf := &s.Interfaces[j]
+			if _, ok := valueTypes[intf.ValueType]; !ok {
+				return nil, fmt.Errorf("service %s interface %s: unsupported val_t %s", s.Name, intf.Type, intf.ValueType)
+			}
+			if intf.Version == "" {
+				intf.Version = "1"
+			}
+			if intf.Method == "" {
+				parts := strings.Split(intf.Type, ".")
+				intf.Method = camel(parts[len(parts)-1])
+			}
+			intfTypes[intf.Type] = struct{}{}
+		}
+	}
+	var intfList []string
+	for t := range intfTypes {
+		intfList = append(intfList, t)
+	}
+	sort.Strings(intfList)
+
+	var buf bytes.Buffer
+	err := tmpl.Execute(&buf, map[string]interface{}{
+		"Services":   services,
+		"Interfaces": intfList,
+	})
+	if err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code is invalid: %w\n%s", err, buf.String())
+	}
+	return src, nil
+}
+
+// camel converts snake_case or dot.separated name into CamelCase.
+func camel(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func lowerCamel(name string) string {
+	c := camel(name)
+	return strings.ToLower(c[:1]) + c[1:]
+}
+
+// literal formats service property value as Go literal.
+func literal(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", val), nil
+	case bool:
+		return fmt.Sprintf("%t", val), nil
+	case float64:
+		if val == math.Trunc(val) {
+			return fmt.Sprintf("%d", int64(val)), nil
+		}
+		return fmt.Sprintf("%v", val), nil
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("only string arrays are supported , got %v", val)
+			}
+			items = append(items, fmt.Sprintf("%q", s))
+		}
+		return "[]string{" + strings.Join(items, ", ") + "}", nil
+	default:
+		return "", fmt.Errorf("unsupported property value %v", v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var tmpl = template.Must(template.New("catalog").Funcs(template.FuncMap{
+	"camel":      camel,
+	"lowerCamel": lowerCamel,
+	"literal":    literal,
+	"sortedKeys": sortedKeys,
+	"goType":     func(vt string) string { return valueTypes[vt].GoType },
+	"ctor":       func(vt string) string { return valueTypes[vt].Constructor },
+	"vtConst":    func(vt string) string { return vTypeConsts[vt] },
+	"intfConst":  func(t string) string { return "Intf" + camel(t) },
+}).Parse(`// Code generated by go run ./gen; DO NOT EDIT.
+
+package catalog
+
+import (
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/fimptype"
+)
+
+// Interface types of catalog services.
+const (
+{{- range .Interfaces}}
+	{{intfConst .}} = "{{.}}"
+{{- end}}
+)
+
+// Names of catalog services.
+const (
+{{- range .Services}}
+	Service{{.Type}} = "{{.Name}}"
+{{- end}}
+)
+
+var registry = map[string]ServiceDefinition{
+{{- range .Services}}
+	Service{{.Type}}: {{.Type}},
+{{- end}}
+}
+{{range $s := .Services}}
+// {{$s.Type}}Service is {{$s.Name}} service , {{$s.Doc}}.
+type {{$s.Type}}Service struct{}
+
+// {{$s.Type}} describes {{$s.Name}} service.
+var {{$s.Type}} {{$s.Type}}Service
+{{- if $s.Props}}
+
+var {{lowerCamel $s.Type}}Props = map[string]interface{}{
+{{- range $k := sortedKeys $s.Props}}
+	"{{$k}}": {{literal (index $s.Props $k)}},
+{{- end}}
+}
+{{- end}}
+
+// Name returns service name.
+func ({{$s.Type}}Service) Name() string {
+	return Service{{$s.Type}}
+}
+
+// Interfaces returns interfaces of {{$s.Name}} service.
+func ({{$s.Type}}Service) Interfaces() []fimptype.Interface {
+	return []fimptype.Interface{
+{{- range $s.Interfaces}}
+		{Type: {{intfConst .Type}}, MsgType: "{{.MsgType}}", ValueType: fimpgo.{{vtConst .ValueType}}, Version: "{{.Version}}"},
+{{- end}}
+	}
+}
+
+// Service returns {{$s.Name}} service specification with given address.
+func (s {{$s.Type}}Service) Service(address string) fimptype.Service {
+	return buildService(Service{{$s.Type}}, address, {{if $s.Props}}{{lowerCamel $s.Type}}Props{{else}}nil{{end}}, s.Interfaces())
+}
+{{range $i := $s.Interfaces}}
+{{- if $i.Props}}
+// {{$s.Type}}{{$i.Method}} is decoded {{$i.Type}} message of {{$s.Name}} service.
+type {{$s.Type}}{{$i.Method}} struct {
+{{- if ne $i.ValueType "null"}}
+	Value {{goType $i.ValueType}} ` + "`fimp:\"val\"`" + `
+{{- end}}
+{{- range $i.Props}}
+	{{camel .}} string ` + "`fimp:\"prop:{{.}}\"`" + `
+{{- end}}
+}
+{{end}}
+// {{$i.Method}} creates {{$i.Type}} message of {{$s.Name}} service.
+func ({{$s.Type}}Service) {{$i.Method}}(
+{{- if ne $i.ValueType "null"}}value {{goType $i.ValueType}}{{if $i.Props}}, {{end}}{{end}}
+{{- range $n, $p := $i.Props}}{{if $n}}, {{end}}{{lowerCamel $p}}{{end}}{{if $i.Props}} string{{end -}}
+) *fimpgo.FimpMessage {
+{{- $props := "nil"}}
+{{- if $i.Props}}
+	props := newProps({{range $n, $p := $i.Props}}{{if $n}}, {{end}}"{{$p}}", {{lowerCamel $p}}{{end}})
+{{- $props = "props"}}
+{{- end}}
+{{- if eq $i.ValueType "null"}}
+	return fimpgo.NewNullMessage({{intfConst $i.Type}}, Service{{$s.Type}}, {{$props}}, nil, nil)
+{{- else}}
+	return fimpgo.{{ctor $i.ValueType}}({{intfConst $i.Type}}, Service{{$s.Type}}, value, {{$props}}, nil, nil)
+{{- end}}
+}
+{{- if $i.Props}}
+
+// Parse{{$i.Method}} decodes {{$i.Type}} message of {{$s.Name}} service.
+func ({{$s.Type}}Service) Parse{{$i.Method}}(msg *fimpgo.FimpMessage) ({{$s.Type}}{{$i.Method}}, error) {
+	return decode[{{$s.Type}}{{$i.Method}}](msg, Service{{$s.Type}}, {{intfConst $i.Type}})
+}
+{{- else if ne $i.ValueType "null"}}
+
+// Parse{{$i.Method}} decodes value of {{$i.Type}} message of {{$s.Name}} service.
+func ({{$s.Type}}Service) Parse{{$i.Method}}(msg *fimpgo.FimpMessage) ({{goType $i.ValueType}}, error) {
+	return decode[{{goType $i.ValueType}}](msg, Service{{$s.Type}}, {{intfConst $i.Type}})
+}
+{{- end}}
+{{end}}
+{{- end}}`))
@@ -20,7 +20,7 @@ type NotifyFilter struct {
 
 type ApiClient struct {
 	clientID              string
-	mqttTransport         *fimpgo.MqttTransport
+	mqttTransport         fimpgo.Transport
 	sClient               *fimpgo.SyncClient
 	siteCache             Site
 	isCacheEnabled        bool
@@ -39,7 +39,7 @@ type ApiClient struct {
 
 // NewApiClient Creates a new Vinculum API client. If isCacheEnabled it set to true , it will try to sync entire site on startup.
 // If the library is used for backend services , new client instance must be created for each smarthub.
-func NewApiClient(clientID string, mqttTransport *fimpgo.MqttTransport, loadSiteIntoCache bool, options ...Option) *ApiClient {
+func NewApiClient(clientID string, mqttTransport fimpgo.Transport, loadSiteIntoCache bool, options ...Option) *ApiClient {
 	api := &ApiClient{clientID: clientID, mqttTransport: mqttTransport}
 	api.notifySubChannels = make(map[string]chan Notify)
 	api.responsePayloadType = "j1"
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/fimpgotest"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 )
@@ -380,3 +381,25 @@ func TestPrimefimp_LoadStateFromFile(t *testing.T) {
 		t.Fatal(fmt.Sprintf("meter_elec devices count does not match. expected %d, got %d", meterAttributes, len(filteredDevices)))
 	}
 }
+
+func TestPrimeFimp_GetSiteFromTestBroker(t *testing.T) {
+	broker := fimpgotest.NewBroker()
+	broker.When("pt:j1/mt:cmd/rt:app/rn:vinculum/ad:1", "vinculum", "cmd.pd7.request").RespondWithFile("testdata/site-info-response.json")
+
+	transport := broker.NewTransport(clientId())
+	defer transport.Stop()
+
+	client := NewApiClient("test-1", transport, false)
+	defer client.Stop()
+
+	site, err := client.GetSite(false)
+	if err != nil {
+		t.Fatal("Error", err)
+	}
+	if len(site.Devices) != 49 {
+		t.Errorf("Site should have 49 devices , got %d", len(site.Devices))
+	}
+	if len(site.Rooms) == 0 {
+		t.Error("Site should have rooms")
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/buger/jsonparser"
 	"github.com/google/uuid"
 )
 
@@ -255,15 +254,14 @@ func (msg *FimpMessage) GetObjectValue(objectBindVar interface{}) error {
 }
 
 func (msg *FimpMessage) SerializeToJson() ([]byte, error) {
-	jsonBA, err := json.Marshal(msg)
-	if msg.ValueType == VTypeObject {
-		if msg.Value == nil && msg.ValueObj != nil {
-			// This is for object pass though.
-			jsonBA, err = jsonparser.Set(jsonBA, msg.ValueObj, "val")
-		}
+	buf := bufferPool.Get().(*[]byte)
+	defer bufferPool.Put(buf)
+	data, err := msg.AppendJson((*buf)[:0])
+	if err != nil {
+		return nil, err
 	}
-	return jsonBA, err
-
+	*buf = data
+	return append(make([]byte, 0, len(data)), data...), nil
 }
 
 // GetCreationTime returns parsed creation time of the message.
@@ -377,147 +375,15 @@ func NewBinaryMessage(type_, service string, value []byte, props Props, tags Tag
 	return NewMessage(type_, service, VTypeBinary, valEnc, props, tags, requestMessage)
 }
 
+// NewMessageFromBytes decodes message. It's lenient , items of arrays and maps , which can't be parsed , are decoded as zero values.
+// Use NewMessageFromBytesStrict to validate the message.
 func NewMessageFromBytes(msg []byte) (*FimpMessage, error) {
-	fimpmsg := FimpMessage{}
-	var err error
-	fimpmsg.Type, err = jsonparser.GetString(msg, "type")
-	fimpmsg.Service, err = jsonparser.GetString(msg, "serv")
-	fimpmsg.ValueType, err = jsonparser.GetString(msg, "val_t")
-	fimpmsg.UID, _ = jsonparser.GetString(msg, "uid")
-	fimpmsg.CorrelationID, _ = jsonparser.GetString(msg, "corid")
-	fimpmsg.CreationTime, _ = jsonparser.GetString(msg, "ctime")
-	fimpmsg.ResponseToTopic, _ = jsonparser.GetString(msg, "resp_to")
-	fimpmsg.Source, _ = jsonparser.GetString(msg, "src")
-	fimpmsg.Topic, _ = jsonparser.GetString(msg, "topic")
-	fimpmsg.Version, _ = jsonparser.GetString(msg, "ver")
-
-	switch fimpmsg.ValueType {
-	case VTypeString:
-		fimpmsg.Value, err = jsonparser.GetString(msg, "val")
-	case VTypeBool:
-		fimpmsg.Value, err = jsonparser.GetBoolean(msg, "val")
-	case VTypeInt:
-		fimpmsg.Value, err = jsonparser.GetInt(msg, "val")
-	case VTypeFloat:
-		fimpmsg.Value, err = jsonparser.GetFloat(msg, "val")
-	case VTypeBoolArray:
-		val := make([]bool, 0)
-		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-			item, _ := jsonparser.ParseBoolean(value)
-			val = append(val, item)
-		}, "val"); err != nil {
-			return nil, err
-		}
-
-		fimpmsg.Value = val
-	case VTypeStrArray:
-		val := make([]string, 0)
-		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-			item, _ := jsonparser.ParseString(value)
-			val = append(val, item)
-		}, "val"); err != nil {
-			return nil, err
-		}
-
-		fimpmsg.Value = val
-	case VTypeIntArray:
-		val := make([]int64, 0)
-		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-			item, _ := jsonparser.ParseInt(value)
-			val = append(val, item)
-
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-	case VTypeFloatArray:
-		val := make([]float64, 0)
-		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
-			item, _ := jsonparser.ParseFloat(value)
-			val = append(val, item)
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-
-	case VTypeStrMap:
-		val := make(map[string]string)
-		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
-			val[string(key)], err = jsonparser.ParseString(value)
-			return nil
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-
-	case VTypeIntMap:
-		val := make(map[string]int64)
-		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
-			val[string(key)], err = jsonparser.ParseInt(value)
-			return nil
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-
-	case VTypeFloatMap:
-		val := make(map[string]float64)
-		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
-			val[string(key)], err = jsonparser.ParseFloat(value)
-			return nil
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-
-	case VTypeBoolMap:
-		val := make(map[string]bool)
-		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
-			val[string(key)], err = jsonparser.ParseBoolean(value)
-			return nil
-		}, "val"); err != nil {
-			return nil, err
-		}
-		fimpmsg.Value = val
-
-	case VTypeBinary:
-		fimpmsg.Value, err = jsonparser.GetString(msg, "val")
-		//base64val, err := jsonparser.GetString(msg, "val")
-		//if err != nil {
-		//	return nil,err
-		//}
-		//fimpmsg.Value ,err = base64.StdEncoding.DecodeString(base64val)
-		//if err != nil {
-		//	return nil,err
-		//}
-
-	case VTypeObject:
-		fimpmsg.ValueObj, _, _, err = jsonparser.Get(msg, "val")
-
-	}
-
-	if properties, dt, _, err := jsonparser.Get(msg, "props"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
-		err := json.Unmarshal(properties, &fimpmsg.Properties)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if storage, dt, _, err := jsonparser.Get(msg, "storage"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
-		err := json.Unmarshal(storage, &fimpmsg.Storage)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	if tags, dt, _, err := jsonparser.Get(msg, "tags"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
-		err := json.Unmarshal(tags, &fimpmsg.Tags)
-		if err != nil {
-			return nil, err
-		}
+	fimpmsg := &FimpMessage{}
+	valueErr, err := fimpmsg.decode(msg)
+	if err != nil {
+		return nil, err
 	}
-
-	return &fimpmsg, err
+	return fimpmsg, valueErr
 }
 
 // ParseTime is a helper function to parse a timestamp from a string from various variations of RFC3339.
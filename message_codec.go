@@ -0,0 +1,705 @@
+package fimpgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/buger/jsonparser"
+)
+
+var (
+	messagePool = sync.Pool{New: func() interface{} { return new(FimpMessage) }}
+	bufferPool  = sync.Pool{New: func() interface{} { b := make([]byte, 0, 512); return &b }}
+)
+
+// AcquireMessage returns empty message from the pool. The message should be returned with ReleaseMessage when it's not used anymore.
+func AcquireMessage() *FimpMessage {
+	return messagePool.Get().(*FimpMessage)
+}
+
+// ReleaseMessage resets message and returns it to the pool. The message and its properties must not be used after the call.
+func ReleaseMessage(msg *FimpMessage) {
+	if msg == nil {
+		return
+	}
+	msg.Reset()
+	messagePool.Put(msg)
+}
+
+// Reset clears all fields of the message. Properties map and tags slice are kept empty , so they can be reused by DecodeMessage.
+func (msg *FimpMessage) Reset() {
+	props := msg.Properties
+	for k := range props {
+		delete(props, k)
+	}
+	tags := msg.Tags
+	if tags != nil {
+		tags = tags[:0]
+	}
+	*msg = FimpMessage{Properties: props, Tags: tags}
+}
+
+// DecodeMessage decodes message into msg , which is normally acquired with AcquireMessage. Message is reset before decoding.
+// The payload is scanned only once , otherwise the result is the same as of NewMessageFromBytes.
+// Object values (ValueObj) refer to data , so data must not be modified while the message is in use.
+func DecodeMessage(data []byte, msg *FimpMessage) error {
+	msg.Reset()
+	valueErr, err := msg.decode(data)
+	if err != nil {
+		return err
+	}
+	return valueErr
+}
+
+// Message fields , used to detect duplicated keys.
+const (
+	fieldType = 1 << iota
+	fieldServ
+	fieldValT
+	fieldVal
+	fieldTags
+	fieldProps
+	fieldStorage
+	fieldVer
+	fieldCorid
+	fieldRespTo
+	fieldSrc
+	fieldCtime
+	fieldUID
+	fieldTopic
+)
+
+// decode decodes message in a single pass. valueErr is returned if message was decoded , but value is invalid ,
+// err is returned if message can't be decoded.
+func (msg *FimpMessage) decode(data []byte) (valueErr error, err error) {
+	var (
+		seen       int
+		val        []byte
+		valType    = jsonparser.NotExist
+		props      []byte
+		propsType  = jsonparser.NotExist
+		tags       []byte
+		tagsType   = jsonparser.NotExist
+		storage    []byte
+		valTypeErr error = jsonparser.KeyPathNotFoundError
+	)
+
+	err = jsonparser.ObjectEach(data, func(key []byte, value []byte, dt jsonparser.ValueType, _ int) error {
+		var field int
+		var target *string
+		switch string(key) {
+		case "type":
+			field, target = fieldType, &msg.Type
+		case "serv":
+			field, target = fieldServ, &msg.Service
+		case "val_t":
+			field, target = fieldValT, &msg.ValueType
+		case "uid":
+			field, target = fieldUID, &msg.UID
+		case "corid":
+			field, target = fieldCorid, &msg.CorrelationID
+		case "ctime":
+			field, target = fieldCtime, &msg.CreationTime
+		case "resp_to":
+			field, target = fieldRespTo, &msg.ResponseToTopic
+		case "src":
+			field, target = fieldSrc, &msg.Source
+		case "topic":
+			field, target = fieldTopic, &msg.Topic
+		case "ver":
+			field, target = fieldVer, &msg.Version
+		case "val":
+			field = fieldVal
+		case "props":
+			field = fieldProps
+		case "tags":
+			field = fieldTags
+		case "storage":
+			field = fieldStorage
+		default:
+			return nil
+		}
+		// the first occurrence of the key wins
+		if seen&field != 0 {
+			return nil
+		}
+		seen |= field
+
+		switch field {
+		case fieldVal:
+			val, valType = value, dt
+		case fieldProps:
+			props, propsType = value, dt
+		case fieldTags:
+			tags, tagsType = value, dt
+		case fieldStorage:
+			if dt != jsonparser.Null {
+				storage = value
+			}
+		default:
+			s, err := parseStringField(value, dt)
+			*target = s
+			if field == fieldValT {
+				valTypeErr = err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	valueErr = valTypeErr
+	switch msg.ValueType {
+	case VTypeString, VTypeBinary, VTypeBase64:
+		msg.Value, valueErr = parseStringValue(val, valType)
+	case VTypeBool:
+		msg.Value, valueErr = parseBoolValue(val, valType)
+	case VTypeInt:
+		msg.Value, valueErr = parseIntValue(val, valType)
+	case VTypeFloat:
+		msg.Value, valueErr = parseFloatValue(val, valType)
+	case VTypeObject:
+		if valType == jsonparser.NotExist {
+			valueErr = jsonparser.KeyPathNotFoundError
+		} else {
+			msg.ValueObj, valueErr = val, nil
+		}
+	case VTypeBoolArray, VTypeStrArray, VTypeIntArray, VTypeFloatArray:
+		if msg.Value, err = decodeArrayValue(msg.ValueType, val, valType); err != nil {
+			return nil, err
+		}
+		valueErr = nil
+	case VTypeStrMap, VTypeIntMap, VTypeFloatMap, VTypeBoolMap:
+		msg.Value, valueErr = decodeMapValue(msg.ValueType, val, valType)
+		if errors.Is(valueErr, errNotObject) {
+			return nil, valueErr
+		}
+	}
+
+	if propsType != jsonparser.NotExist && propsType != jsonparser.Null {
+		if err = msg.decodeProps(props, propsType); err != nil {
+			return nil, err
+		}
+	} else {
+		msg.Properties = nil
+	}
+
+	if tagsType != jsonparser.NotExist && tagsType != jsonparser.Null {
+		if err = msg.decodeTags(tags, tagsType); err != nil {
+			return nil, err
+		}
+	} else {
+		msg.Tags = nil
+	}
+
+	if storage != nil {
+		if err = json.Unmarshal(storage, &msg.Storage); err != nil {
+			return nil, err
+		}
+	}
+	return valueErr, nil
+}
+
+var (
+	errNotString  = errors.New("value is not a string")
+	errNotBoolean = errors.New("value is not a boolean")
+	errNotNumber  = errors.New("value is not a number")
+	errNotArray   = errors.New("value is not an array")
+	errNotObject  = errors.New("value is not an object")
+)
+
+func parseStringField(value []byte, dt jsonparser.ValueType) (string, error) {
+	if dt != jsonparser.String {
+		return "", errNotString
+	}
+	return parseJSONString(value)
+}
+
+// parseJSONString unescapes string , strings without escape sequences are converted without intermediate copy.
+func parseJSONString(value []byte) (string, error) {
+	for _, c := range value {
+		if c == '\\' {
+			return jsonparser.ParseString(value)
+		}
+	}
+	return string(value), nil
+}
+
+func parseStringValue(value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	if dt == jsonparser.NotExist {
+		return "", jsonparser.KeyPathNotFoundError
+	}
+	s, err := parseStringField(value, dt)
+	return s, err
+}
+
+func parseBoolValue(value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	switch dt {
+	case jsonparser.NotExist:
+		return false, jsonparser.KeyPathNotFoundError
+	case jsonparser.Boolean:
+		return jsonparser.ParseBoolean(value)
+	default:
+		return false, errNotBoolean
+	}
+}
+
+func parseIntValue(value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	switch dt {
+	case jsonparser.NotExist:
+		return int64(0), jsonparser.KeyPathNotFoundError
+	case jsonparser.Number:
+		return jsonparser.ParseInt(value)
+	default:
+		return int64(0), errNotNumber
+	}
+}
+
+func parseFloatValue(value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	switch dt {
+	case jsonparser.NotExist:
+		return float64(0), jsonparser.KeyPathNotFoundError
+	case jsonparser.Number:
+		return jsonparser.ParseFloat(value)
+	default:
+		return float64(0), errNotNumber
+	}
+}
+
+// decodeArrayValue decodes array value. Items , which can't be parsed , are decoded as zero values.
+func decodeArrayValue(valueType string, value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	if dt == jsonparser.NotExist {
+		return nil, jsonparser.KeyPathNotFoundError
+	}
+	if dt != jsonparser.Array {
+		return nil, errNotArray
+	}
+
+	var err error
+	switch valueType {
+	case VTypeBoolArray:
+		val := make([]bool, 0)
+		_, err = jsonparser.ArrayEach(value, func(item []byte, _ jsonparser.ValueType, _ int, _ error) {
+			v, _ := jsonparser.ParseBoolean(item)
+			val = append(val, v)
+		})
+		return val, err
+	case VTypeStrArray:
+		val := make([]string, 0)
+		_, err = jsonparser.ArrayEach(value, func(item []byte, _ jsonparser.ValueType, _ int, _ error) {
+			v, _ := jsonparser.ParseString(item)
+			val = append(val, v)
+		})
+		return val, err
+	case VTypeIntArray:
+		val := make([]int64, 0)
+		_, err = jsonparser.ArrayEach(value, func(item []byte, _ jsonparser.ValueType, _ int, _ error) {
+			v, _ := jsonparser.ParseInt(item)
+			val = append(val, v)
+		})
+		return val, err
+	default:
+		val := make([]float64, 0)
+		_, err = jsonparser.ArrayEach(value, func(item []byte, _ jsonparser.ValueType, _ int, _ error) {
+			v, _ := jsonparser.ParseFloat(item)
+			val = append(val, v)
+		})
+		return val, err
+	}
+}
+
+// decodeMapValue decodes map value. Items , which can't be parsed , are decoded as zero values and the last item error is returned.
+func decodeMapValue(valueType string, value []byte, dt jsonparser.ValueType) (interface{}, error) {
+	if dt == jsonparser.NotExist {
+		return nil, fmt.Errorf("%w: %v", errNotObject, jsonparser.KeyPathNotFoundError)
+	}
+	if dt != jsonparser.Object {
+		return nil, errNotObject
+	}
+
+	var itemErr error
+	var err error
+	switch valueType {
+	case VTypeStrMap:
+		val := make(map[string]string)
+		err = jsonparser.ObjectEach(value, func(key []byte, item []byte, _ jsonparser.ValueType, _ int) error {
+			val[string(key)], itemErr = jsonparser.ParseString(item)
+			return nil
+		})
+		if err == nil {
+			return val, itemErr
+		}
+	case VTypeIntMap:
+		val := make(map[string]int64)
+		err = jsonparser.ObjectEach(value, func(key []byte, item []byte, _ jsonparser.ValueType, _ int) error {
+			val[string(key)], itemErr = jsonparser.ParseInt(item)
+			return nil
+		})
+		if err == nil {
+			return val, itemErr
+		}
+	case VTypeFloatMap:
+		val := make(map[string]float64)
+		err = jsonparser.ObjectEach(value, func(key []byte, item []byte, _ jsonparser.ValueType, _ int) error {
+			val[string(key)], itemErr = jsonparser.ParseFloat(item)
+			return nil
+		})
+		if err == nil {
+			return val, itemErr
+		}
+	default:
+		val := make(map[string]bool)
+		err = jsonparser.ObjectEach(value, func(key []byte, item []byte, _ jsonparser.ValueType, _ int) error {
+			val[string(key)], itemErr = jsonparser.ParseBoolean(item)
+			return nil
+		})
+		if err == nil {
+			return val, itemErr
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", errNotObject, err)
+}
+
+// decodeProps decodes properties reusing existing map of the message.
+func (msg *FimpMessage) decodeProps(value []byte, dt jsonparser.ValueType) error {
+	if dt != jsonparser.Object {
+		return fmt.Errorf("props: %w", errNotObject)
+	}
+	if msg.Properties == nil {
+		msg.Properties = make(Props)
+	}
+	return jsonparser.ObjectEach(value, func(key []byte, item []byte, itemType jsonparser.ValueType, _ int) error {
+		k, err := parseJSONString(key)
+		if err != nil {
+			return err
+		}
+		switch itemType {
+		case jsonparser.String:
+			v, err := parseJSONString(item)
+			if err != nil {
+				return err
+			}
+			msg.Properties[k] = v
+		case jsonparser.Null:
+			// json.Unmarshal leaves existing value untouched
+			if _, ok := msg.Properties[k]; !ok {
+				msg.Properties[k] = ""
+			}
+		default:
+			return fmt.Errorf("props.%s: %w", k, errNotString)
+		}
+		return nil
+	})
+}
+
+// decodeTags decodes tags reusing existing slice of the message.
+func (msg *FimpMessage) decodeTags(value []byte, dt jsonparser.ValueType) error {
+	if dt != jsonparser.Array {
+		return fmt.Errorf("tags: %w", errNotArray)
+	}
+	tags := msg.Tags[:0]
+	if tags == nil {
+		tags = Tags{}
+	}
+	var itemErr error
+	_, err := jsonparser.ArrayEach(value, func(item []byte, itemType jsonparser.ValueType, _ int, _ error) {
+		switch itemType {
+		case jsonparser.String:
+			v, err := parseJSONString(item)
+			if err != nil {
+				itemErr = err
+			}
+			tags = append(tags, v)
+		case jsonparser.Null:
+			tags = append(tags, "")
+		default:
+			itemErr = fmt.Errorf("tags: %w", errNotString)
+		}
+	})
+	msg.Tags = tags
+	if err != nil {
+		return err
+	}
+	return itemErr
+}
+
+// AppendJson appends JSON encoded message to dst. The output is identical to SerializeToJson.
+func (msg *FimpMessage) AppendJson(dst []byte) ([]byte, error) {
+	var err error
+	dst = append(dst, `{"type":`...)
+	dst = appendJSONString(dst, msg.Type)
+	dst = append(dst, `,"serv":`...)
+	dst = appendJSONString(dst, msg.Service)
+	dst = append(dst, `,"val_t":`...)
+	dst = appendJSONString(dst, msg.ValueType)
+	dst = append(dst, `,"val":`...)
+	if msg.ValueType == VTypeObject && msg.Value == nil && msg.ValueObj != nil {
+		// object pass through
+		dst = append(dst, msg.ValueObj...)
+	} else if dst, err = appendJSONValue(dst, msg.Value); err != nil {
+		return nil, err
+	}
+
+	dst = append(dst, `,"tags":`...)
+	if msg.Tags == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, tag := range msg.Tags {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, tag)
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, `,"props":`...)
+	dst = appendStringMap(dst, msg.Properties)
+
+	if msg.Storage != nil {
+		dst = append(dst, `,"storage":{`...)
+		comma := false
+		if msg.Storage.Strategy != "" {
+			dst = append(dst, `"strategy":`...)
+			dst = appendJSONString(dst, string(msg.Storage.Strategy))
+			comma = true
+		}
+		if msg.Storage.SubValue != "" {
+			if comma {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `"sub_value":`...)
+			dst = appendJSONString(dst, msg.Storage.SubValue)
+		}
+		dst = append(dst, '}')
+	}
+
+	dst = append(dst, `,"ver":`...)
+	dst = appendJSONString(dst, msg.Version)
+	dst = append(dst, `,"corid":`...)
+	dst = appendJSONString(dst, msg.CorrelationID)
+	if msg.ResponseToTopic != "" {
+		dst = append(dst, `,"resp_to":`...)
+		dst = appendJSONString(dst, msg.ResponseToTopic)
+	}
+	if msg.Source != "" {
+		dst = append(dst, `,"src":`...)
+		dst = appendJSONString(dst, msg.Source)
+	}
+	dst = append(dst, `,"ctime":`...)
+	dst = appendJSONString(dst, msg.CreationTime)
+	dst = append(dst, `,"uid":`...)
+	dst = appendJSONString(dst, msg.UID)
+	if msg.Topic != "" {
+		dst = append(dst, `,"topic":`...)
+		dst = appendJSONString(dst, msg.Topic)
+	}
+	dst = append(dst, '}')
+	return dst, nil
+}
+
+// appendJSONValue encodes value types produced by message constructors and NewMessageFromBytes directly , other values are encoded with encoding/json.
+func appendJSONValue(dst []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case string:
+		return appendJSONString(dst, v), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case int64:
+		return strconv.AppendInt(dst, v, 10), nil
+	case int:
+		return strconv.AppendInt(dst, int64(v), 10), nil
+	case float64:
+		return appendJSONFloat(dst, v)
+	case []string:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, item := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, item)
+		}
+		return append(dst, ']'), nil
+	case []int64:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, item := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = strconv.AppendInt(dst, item, 10)
+		}
+		return append(dst, ']'), nil
+	case []float64:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		var err error
+		dst = append(dst, '[')
+		for i, item := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendJSONFloat(dst, item); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case []bool:
+		if v == nil {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i, item := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = strconv.AppendBool(dst, item)
+		}
+		return append(dst, ']'), nil
+	case map[string]string:
+		return appendStringMap(dst, v), nil
+	case Props:
+		return appendStringMap(dst, v), nil
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, data...), nil
+	}
+}
+
+// appendStringMap encodes map with sorted keys , the same way as encoding/json.
+func appendStringMap(dst []byte, m map[string]string) []byte {
+	if m == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '{')
+	switch len(m) {
+	case 0:
+	case 1:
+		for k, v := range m {
+			dst = appendJSONString(dst, k)
+			dst = append(dst, ':')
+			dst = appendJSONString(dst, v)
+		}
+	default:
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, k)
+			dst = append(dst, ':')
+			dst = appendJSONString(dst, m[k])
+		}
+	}
+	return append(dst, '}')
+}
+
+// appendJSONFloat formats float the same way as encoding/json.
+func appendJSONFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		_, err := json.Marshal(f)
+		return nil, err
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// shortControlEscapes is true if encoding/json escapes \b and \f with short sequences , it depends on Go version.
+var shortControlEscapes = func() bool {
+	data, _ := json.Marshal("\b")
+	return string(data) == `"\b"`
+}()
+
+// invalidUTF8Replacement is what encoding/json writes for invalid UTF-8 bytes , it depends on Go version.
+var invalidUTF8Replacement = func() string {
+	data, _ := json.Marshal("\xff")
+	return string(data[1 : len(data)-1])
+}()
+
+// appendJSONString encodes string the same way as encoding/json with HTML escaping.
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch {
+			case b == '\\' || b == '"':
+				dst = append(dst, '\\', b)
+			case b == '\n':
+				dst = append(dst, '\\', 'n')
+			case b == '\r':
+				dst = append(dst, '\\', 'r')
+			case b == '\t':
+				dst = append(dst, '\\', 't')
+			case b == '\b' && shortControlEscapes:
+				dst = append(dst, '\\', 'b')
+			case b == '\f' && shortControlEscapes:
+				dst = append(dst, '\\', 'f')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, invalidUTF8Replacement...)
+			i += size
+			start = i
+			continue
+		}
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,368 @@
+package fimpgo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// FieldError describes invalid field of decoded message. Path is the field name , array elements and map items are addressed as val[2] and val["key"].
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeError is returned by NewMessageFromBytesStrict , it contains all invalid fields of the message.
+type DecodeError struct {
+	Fields []*FieldError
+}
+
+func (e *DecodeError) Error() string {
+	items := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		items = append(items, f.Error())
+	}
+	return fmt.Sprintf("%v: %s", errInvalidMessage, strings.Join(items, "; "))
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == errInvalidMessage
+}
+
+func (e *DecodeError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		errs = append(errs, f)
+	}
+	return errs
+}
+
+var (
+	errMissing     = errors.New("field is missing")
+	errUnknownType = errors.New("unknown value type")
+)
+
+var knownValueTypes = map[string]struct{}{
+	VTypeString: {}, VTypeInt: {}, VTypeFloat: {}, VTypeBool: {},
+	VTypeStrMap: {}, VTypeIntMap: {}, VTypeFloatMap: {}, VTypeBoolMap: {},
+	VTypeStrArray: {}, VTypeIntArray: {}, VTypeFloatArray: {}, VTypeBoolArray: {},
+	VTypeObject: {}, VTypeBinary: {}, VTypeBase64: {}, VTypeNull: {},
+}
+
+// strictDecoder collects all field errors instead of stopping at the first one.
+type strictDecoder struct {
+	data   []byte
+	errors []*FieldError
+}
+
+func (d *strictDecoder) fail(path string, err error) {
+	d.errors = append(d.errors, &FieldError{Path: path, Err: err})
+}
+
+func (d *strictDecoder) failf(path string, format string, args ...interface{}) {
+	d.fail(path, fmt.Errorf(format, args...))
+}
+
+// NewMessageFromBytesStrict decodes message and validates it. Unlike NewMessageFromBytes it doesn't replace invalid values with zero values ,
+// all invalid fields , array elements and map items are reported in returned *DecodeError. Messages with unknown val_t are rejected.
+// Decoded values have the same Go types as values returned by NewMessageFromBytes.
+func NewMessageFromBytesStrict(msg []byte) (*FimpMessage, error) {
+	d := &strictDecoder{data: msg}
+	if !json.Valid(msg) {
+		d.fail("$", errors.New("malformed JSON"))
+		return nil, &DecodeError{Fields: d.errors}
+	}
+	if _, dt, _, err := jsonparser.Get(msg); err != nil || dt != jsonparser.Object {
+		d.fail("$", errors.New("message must be a JSON object"))
+		return nil, &DecodeError{Fields: d.errors}
+	}
+
+	fimpmsg := FimpMessage{}
+	fimpmsg.Type = d.string("type", true)
+	fimpmsg.Service = d.string("serv", true)
+	fimpmsg.ValueType = d.string("val_t", true)
+	fimpmsg.UID = d.string("uid", false)
+	fimpmsg.CorrelationID = d.string("corid", false)
+	fimpmsg.CreationTime = d.string("ctime", false)
+	fimpmsg.ResponseToTopic = d.string("resp_to", false)
+	fimpmsg.Source = d.string("src", false)
+	fimpmsg.Topic = d.string("topic", false)
+	fimpmsg.Version = d.string("ver", false)
+
+	if _, ok := knownValueTypes[fimpmsg.ValueType]; ok {
+		d.value(&fimpmsg)
+	} else if fimpmsg.ValueType != "" {
+		d.fail("val_t", fmt.Errorf("%w %q", errUnknownType, fimpmsg.ValueType))
+	}
+	fimpmsg.Properties = d.props()
+	fimpmsg.Tags = d.tags()
+	fimpmsg.Storage = d.storage()
+
+	if len(d.errors) > 0 {
+		return nil, &DecodeError{Fields: d.errors}
+	}
+	return &fimpmsg, nil
+}
+
+// field returns field value , ok is false if field is missing or null.
+func (d *strictDecoder) field(key string) ([]byte, jsonparser.ValueType, bool) {
+	value, dt, _, err := jsonparser.Get(d.data, key)
+	if dt == jsonparser.NotExist || dt == jsonparser.Null {
+		return nil, dt, false
+	}
+	if err != nil {
+		d.fail(key, err)
+		return nil, dt, false
+	}
+	return value, dt, true
+}
+
+// string decodes string field , required field must be present and not empty.
+func (d *strictDecoder) string(key string, required bool) string {
+	value, dt, ok := d.field(key)
+	if !ok {
+		if required {
+			d.fail(key, errMissing)
+		}
+		return ""
+	}
+	s := d.parseString(key, value, dt)
+	if required && s == "" && dt == jsonparser.String {
+		d.fail(key, errors.New("must not be empty"))
+	}
+	return s
+}
+
+func (d *strictDecoder) parseString(path string, value []byte, dt jsonparser.ValueType) string {
+	if dt != jsonparser.String {
+		d.failf(path, "expected string , got %s", dt)
+		return ""
+	}
+	s, err := jsonparser.ParseString(value)
+	if err != nil {
+		d.fail(path, err)
+	}
+	return s
+}
+
+func (d *strictDecoder) parseBool(path string, value []byte, dt jsonparser.ValueType) bool {
+	if dt != jsonparser.Boolean {
+		d.failf(path, "expected boolean , got %s", dt)
+		return false
+	}
+	b, err := jsonparser.ParseBoolean(value)
+	if err != nil {
+		d.fail(path, err)
+	}
+	return b
+}
+
+func (d *strictDecoder) parseInt(path string, value []byte, dt jsonparser.ValueType) int64 {
+	if dt != jsonparser.Number {
+		d.failf(path, "expected integer , got %s", dt)
+		return 0
+	}
+	i, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		d.failf(path, "expected integer , got %s", value)
+	}
+	return i
+}
+
+func (d *strictDecoder) parseFloat(path string, value []byte, dt jsonparser.ValueType) float64 {
+	if dt != jsonparser.Number {
+		d.failf(path, "expected number , got %s", dt)
+		return 0
+	}
+	f, err := jsonparser.ParseFloat(value)
+	if err != nil {
+		d.failf(path, "expected number , got %s", value)
+	}
+	return f
+}
+
+func (d *strictDecoder) value(fimpmsg *FimpMessage) {
+	const path = "val"
+	value, dt, ok := d.field(path)
+	if fimpmsg.ValueType == VTypeNull {
+		if ok {
+			d.failf(path, "expected null , got %s", dt)
+		}
+		return
+	}
+	if !ok {
+		d.fail(path, errMissing)
+		return
+	}
+
+	switch fimpmsg.ValueType {
+	case VTypeString:
+		fimpmsg.Value = d.parseString(path, value, dt)
+	case VTypeBool:
+		fimpmsg.Value = d.parseBool(path, value, dt)
+	case VTypeInt:
+		fimpmsg.Value = d.parseInt(path, value, dt)
+	case VTypeFloat:
+		fimpmsg.Value = d.parseFloat(path, value, dt)
+	case VTypeBinary, VTypeBase64:
+		if dt != jsonparser.String {
+			d.failf(path, "expected base64 string , got %s", dt)
+			return
+		}
+		s := d.parseString(path, value, dt)
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			d.failf(path, "invalid base64 value: %v", err)
+		}
+		fimpmsg.Value = s
+	case VTypeObject:
+		if dt != jsonparser.Object && dt != jsonparser.Array {
+			d.failf(path, "expected object or array , got %s", dt)
+			return
+		}
+		fimpmsg.ValueObj = value
+	case VTypeStrArray:
+		val := make([]string, 0)
+		d.array(path, value, dt, func(itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val = append(val, d.parseString(itemPath, item, itemType))
+		})
+		fimpmsg.Value = val
+	case VTypeBoolArray:
+		val := make([]bool, 0)
+		d.array(path, value, dt, func(itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val = append(val, d.parseBool(itemPath, item, itemType))
+		})
+		fimpmsg.Value = val
+	case VTypeIntArray:
+		val := make([]int64, 0)
+		d.array(path, value, dt, func(itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val = append(val, d.parseInt(itemPath, item, itemType))
+		})
+		fimpmsg.Value = val
+	case VTypeFloatArray:
+		val := make([]float64, 0)
+		d.array(path, value, dt, func(itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val = append(val, d.parseFloat(itemPath, item, itemType))
+		})
+		fimpmsg.Value = val
+	case VTypeStrMap:
+		val := make(map[string]string)
+		d.object(path, value, dt, func(key, itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val[key] = d.parseString(itemPath, item, itemType)
+		})
+		fimpmsg.Value = val
+	case VTypeBoolMap:
+		val := make(map[string]bool)
+		d.object(path, value, dt, func(key, itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val[key] = d.parseBool(itemPath, item, itemType)
+		})
+		fimpmsg.Value = val
+	case VTypeIntMap:
+		val := make(map[string]int64)
+		d.object(path, value, dt, func(key, itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val[key] = d.parseInt(itemPath, item, itemType)
+		})
+		fimpmsg.Value = val
+	case VTypeFloatMap:
+		val := make(map[string]float64)
+		d.object(path, value, dt, func(key, itemPath string, item []byte, itemType jsonparser.ValueType) {
+			val[key] = d.parseFloat(itemPath, item, itemType)
+		})
+		fimpmsg.Value = val
+	}
+}
+
+func (d *strictDecoder) array(path string, value []byte, dt jsonparser.ValueType, item func(itemPath string, item []byte, itemType jsonparser.ValueType)) {
+	if dt != jsonparser.Array {
+		d.failf(path, "expected array , got %s", dt)
+		return
+	}
+	i := 0
+	_, err := jsonparser.ArrayEach(value, func(v []byte, itemType jsonparser.ValueType, _ int, err error) {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+		i++
+		if err != nil {
+			d.fail(itemPath, err)
+			return
+		}
+		item(itemPath, v, itemType)
+	})
+	if err != nil {
+		d.fail(path, err)
+	}
+}
+
+func (d *strictDecoder) object(path string, value []byte, dt jsonparser.ValueType, item func(key, itemPath string, item []byte, itemType jsonparser.ValueType)) {
+	if dt != jsonparser.Object {
+		d.failf(path, "expected object , got %s", dt)
+		return
+	}
+	err := jsonparser.ObjectEach(value, func(rawKey []byte, v []byte, itemType jsonparser.ValueType, _ int) error {
+		key, err := jsonparser.ParseString(rawKey)
+		if err != nil {
+			d.fail(fmt.Sprintf("%s[%q]", path, rawKey), err)
+			return nil
+		}
+		item(key, fmt.Sprintf("%s[%q]", path, key), v, itemType)
+		return nil
+	})
+	if err != nil {
+		d.fail(path, err)
+	}
+}
+
+func (d *strictDecoder) props() Props {
+	const path = "props"
+	value, dt, ok := d.field(path)
+	if !ok {
+		return nil
+	}
+	props := Props{}
+	d.object(path, value, dt, func(key, itemPath string, item []byte, itemType jsonparser.ValueType) {
+		props[key] = d.parseString(itemPath, item, itemType)
+	})
+	return props
+}
+
+func (d *strictDecoder) tags() Tags {
+	const path = "tags"
+	value, dt, ok := d.field(path)
+	if !ok {
+		return nil
+	}
+	tags := Tags{}
+	d.array(path, value, dt, func(itemPath string, item []byte, itemType jsonparser.ValueType) {
+		tags = append(tags, d.parseString(itemPath, item, itemType))
+	})
+	return tags
+}
+
+func (d *strictDecoder) storage() *Storage {
+	const path = "storage"
+	value, dt, ok := d.field(path)
+	if !ok {
+		return nil
+	}
+	if dt != jsonparser.Object {
+		d.failf(path, "expected object , got %s", dt)
+		return nil
+	}
+	var storage Storage
+	if err := json.Unmarshal(value, &storage); err != nil {
+		d.fail(path, err)
+		return nil
+	}
+	return &storage
+}
@@ -0,0 +1,198 @@
+package fimpgo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMessageFromBytesStrict(t *testing.T) {
+	tcs := []struct {
+		name     string
+		payload  string
+		expected *FimpMessage
+		paths    []string
+	}{
+		{
+			name:     "int array",
+			payload:  `{"type":"evt.test.report","serv":"test","val_t":"int_array","val":[1,2,3],"props":{"unit":"C"},"tags":["a"]}`,
+			expected: &FimpMessage{Type: "evt.test.report", Service: "test", ValueType: VTypeIntArray, Value: []int64{1, 2, 3}, Properties: Props{"unit": "C"}, Tags: Tags{"a"}},
+		},
+		{
+			name:     "null",
+			payload:  `{"type":"evt.test.report","serv":"test","val_t":"null","val":null}`,
+			expected: &FimpMessage{Type: "evt.test.report", Service: "test", ValueType: VTypeNull},
+		},
+		{
+			name:     "object",
+			payload:  `{"type":"evt.test.report","serv":"test","val_t":"object","val":{"a":1}}`,
+			expected: &FimpMessage{Type: "evt.test.report", Service: "test", ValueType: VTypeObject, ValueObj: []byte(`{"a":1}`)},
+		},
+		{
+			name:    "float inside int array",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"int_array","val":[1,2.5,"3"]}`,
+			paths:   []string{"val[1]", "val[2]"},
+		},
+		{
+			name:    "wrong map items",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"float_map","val":{"a":1.5,"b":true}}`,
+			paths:   []string{`val["b"]`},
+		},
+		{
+			name:    "unknown value type",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"decimal","val":1}`,
+			paths:   []string{"val_t"},
+		},
+		{
+			name:    "missing fields",
+			payload: `{"val_t":"int"}`,
+			paths:   []string{"type", "serv", "val"},
+		},
+		{
+			name:    "wrong field types",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"bool","val":"true","uid":1,"props":{"a":1},"tags":"a"}`,
+			paths:   []string{"uid", "val", `props["a"]`, "tags"},
+		},
+		{
+			name:    "invalid base64",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"bin","val":"%%%"}`,
+			paths:   []string{"val"},
+		},
+		{
+			name:     "base64",
+			payload:  `{"type":"evt.test.report","serv":"test","val_t":"base64","val":"aGVsbG8="}`,
+			expected: &FimpMessage{Type: "evt.test.report", Service: "test", ValueType: VTypeBase64, Value: "aGVsbG8="},
+		},
+		{
+			name:    "invalid base64 value type",
+			payload: `{"type":"evt.test.report","serv":"test","val_t":"base64","val":1}`,
+			paths:   []string{"val"},
+		},
+		{
+			name:    "malformed json",
+			payload: `{"type":"evt.test.report",`,
+			paths:   []string{"$"},
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := NewMessageFromBytesStrict([]byte(tc.payload))
+			if tc.expected != nil {
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, msg)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, IsInvalidMessage(err))
+			var decodeErr *DecodeError
+			require.True(t, errors.As(err, &decodeErr))
+			var paths []string
+			for _, f := range decodeErr.Fields {
+				paths = append(paths, f.Path)
+			}
+			assert.Equal(t, tc.paths, paths)
+		})
+	}
+}
+
+func TestNewMessageFromBytes_LenientIntArray(t *testing.T) {
+	msg, err := NewMessageFromBytes([]byte(`{"type":"evt.test.report","serv":"test","val_t":"int_array","val":[1,2.5]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 0}, msg.Value)
+}
+
+// messageFuzzSeeds returns JSON files from testdata and messages of every value type.
+func messageFuzzSeeds(f *testing.F) [][]byte {
+	var seeds [][]byte
+	for _, pattern := range []string{"testdata/*/*.json", "fimptype/primefimp/testdata/*.json"} {
+		files, err := filepath.Glob(pattern)
+		require.NoError(f, err)
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			require.NoError(f, err)
+			seeds = append(seeds, data)
+		}
+	}
+
+	msgs := []*FimpMessage{
+		NewStringMessage("evt.test.report", "test", "a\"b", Props{"unit": "C"}, Tags{"tag"}, nil),
+		NewIntMessage("evt.test.report", "test", -5, nil, nil, nil),
+		NewFloatMessage("evt.test.report", "test", 1.5e-3, nil, nil, nil),
+		NewBoolMessage("evt.test.report", "test", true, nil, nil, nil),
+		NewNullMessage("evt.test.report", "test", nil, nil, nil),
+		NewStrArrayMessage("evt.test.report", "test", []string{"a", ""}, nil, nil, nil),
+		NewIntArrayMessage("evt.test.report", "test", []int64{1, 2}, nil, nil, nil),
+		NewFloatArrayMessage("evt.test.report", "test", []float64{1.5}, nil, nil, nil),
+		NewBoolArrayMessage("evt.test.report", "test", []bool{false}, nil, nil, nil),
+		NewStrMapMessage("evt.test.report", "test", map[string]string{"a": "b"}, nil, nil, nil),
+		NewIntMapMessage("evt.test.report", "test", map[string]int64{"a": 1}, nil, nil, nil),
+		NewFloatMapMessage("evt.test.report", "test", map[string]float64{"a": 1}, nil, nil, nil),
+		NewBoolMapMessage("evt.test.report", "test", map[string]bool{"a": true}, nil, nil, nil),
+		NewObjectMessage("evt.test.report", "test", map[string]interface{}{"a": []int{1}}, nil, nil, nil),
+		NewBinaryMessage("evt.test.report", "test", []byte{0, 1, 2}, nil, nil, nil).WithStorageStrategy(StorageStrategySkip, ""),
+	}
+	for _, msg := range msgs {
+		data, err := msg.SerializeToJson()
+		require.NoError(f, err)
+		seeds = append(seeds, data)
+	}
+	return seeds
+}
+
+func FuzzNewMessageFromBytes(f *testing.F) {
+	for _, seed := range messageFuzzSeeds(f) {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := NewMessageFromBytes(data)
+		if err != nil || msg == nil {
+			return
+		}
+		_, _ = msg.SerializeToJson()
+	})
+}
+
+func FuzzNewMessageFromBytesStrict(f *testing.F) {
+	for _, seed := range messageFuzzSeeds(f) {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg, err := NewMessageFromBytesStrict(data)
+		if err != nil {
+			require.True(t, IsInvalidMessage(err))
+			require.Nil(t, msg)
+			return
+		}
+
+		// everything accepted by strict decoder must be accepted by lenient one
+		_, err = NewMessageFromBytes(data)
+		require.NoError(t, err)
+
+		// and must survive encoding
+		encoded, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		decoded, err := NewMessageFromBytesStrict(encoded)
+		require.NoError(t, err, "re-encoded message %s", encoded)
+		assert.Equal(t, msg.ValueType, decoded.ValueType)
+	})
+}
+
+func TestNewMessageFromBytes_Base64(t *testing.T) {
+	payload := []byte(`{"type":"evt.test.report","serv":"test","val_t":"base64","val":"aGVsbG8="}`)
+	strict, err := NewMessageFromBytesStrict(payload)
+	require.NoError(t, err)
+	lenient, err := NewMessageFromBytes(payload)
+	require.NoError(t, err)
+	assert.Equal(t, "aGVsbG8=", lenient.Value)
+	assert.Equal(t, lenient.Value, strict.Value)
+}
@@ -1,11 +1,15 @@
 package fimpgo
 
 import (
+	"encoding/json"
+	"math"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/buger/jsonparser"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestFimpMessage_SerializeToJson(t *testing.T) {
@@ -488,3 +492,342 @@ func TestParseTime(t *testing.T) {
 		})
 	}
 }
+
+// codecTestMessages covers all value types and fields , which need escaping.
+func codecTestMessages() []*FimpMessage {
+	req := NewNullMessage("cmd.test.get_report", "test", nil, nil, nil)
+	req.ResponseToTopic = "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+	return []*FimpMessage{
+		NewStringMessage("evt.test.report", "test", "<a & b> \"quoted\" \\ \n\t\r\b\f\x01 \u2028\u2029 \xff æøå", Props{"b": "2", "a": "<1>"}, Tags{"t1", "t&2"}, req),
+		NewIntMessage("evt.test.report", "test", -9007199254740993, nil, nil, nil),
+		NewFloatMessage("evt.test.report", "test", 1e21, nil, nil, nil),
+		NewFloatMessage("evt.test.report", "test", 1e-7, nil, nil, nil),
+		NewFloatMessage("evt.test.report", "test", -0.000001, nil, nil, nil),
+		NewFloatMessage("evt.test.report", "test", 123456.789, nil, nil, nil),
+		NewBoolMessage("evt.test.report", "test", false, Props{}, Tags{}, nil),
+		NewNullMessage("evt.test.report", "test", nil, nil, nil).WithStorageStrategy(StorageStrategyAggregate, "sub"),
+		NewNullMessage("evt.test.report", "test", nil, nil, nil).WithStorageStrategy("", "sub"),
+		NewStrArrayMessage("evt.test.report", "test", []string{"a", "<b>"}, nil, nil, nil),
+		NewIntArrayMessage("evt.test.report", "test", []int64{1, -2}, nil, nil, nil),
+		NewFloatArrayMessage("evt.test.report", "test", []float64{1.5, 2e30}, nil, nil, nil),
+		NewBoolArrayMessage("evt.test.report", "test", []bool{true, false}, nil, nil, nil),
+		NewStrArrayMessage("evt.test.report", "test", nil, nil, nil, nil),
+		NewStrMapMessage("evt.test.report", "test", map[string]string{"z": "1", "a": "2", "m": "3"}, nil, nil, nil),
+		NewIntMapMessage("evt.test.report", "test", map[string]int64{"z": 1, "a": 2}, nil, nil, nil),
+		NewFloatMapMessage("evt.test.report", "test", map[string]float64{"a": 0.5}, nil, nil, nil),
+		NewBoolMapMessage("evt.test.report", "test", map[string]bool{"a": true}, nil, nil, nil),
+		NewObjectMessage("evt.test.report", "test", map[string]interface{}{"list": []int{1, 2}, "html": "<>"}, nil, nil, nil),
+		{Type: "evt.test.report", Service: "test", ValueType: VTypeObject, ValueObj: []byte(`{"raw": [1, 2]}`), Source: "src", Topic: "topic"},
+		NewBinaryMessage("evt.test.report", "test", []byte{0, 1, 2, 255}, nil, nil, nil),
+	}
+}
+
+func TestFimpMessage_SerializeToJsonCompatibility(t *testing.T) {
+	for _, msg := range codecTestMessages() {
+		expected, err := legacySerializeToJson(msg)
+		require.NoError(t, err)
+		actual, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+
+	_, err := NewFloatMessage("evt.test.report", "test", math.NaN(), nil, nil, nil).SerializeToJson()
+	assert.Error(t, err)
+}
+
+func TestNewMessageFromBytes_Compatibility(t *testing.T) {
+	payloads := []string{
+		`{"type":"evt.test.report","serv":"test","val_t":"int_array","val":[1,2.5,"x"]}`,
+		`{"type":"evt.test.report","serv":"test","val_t":"int","val":"1"}`,
+		`{"type":"evt.test.report","serv":"test","val_t":"str_map","val":{"a":"1","b":2}}`,
+		`{"type":"evt.test.report","serv":"test","val_t":"float","props":{"a":"1"},"tags":null}`,
+		`{"type":"evt.test.report","serv":"test","val_t":"object","val":null}`,
+		`{"type":"evt.test.report","serv":"test","val_t":"unknown","val":1,"extra":{"type":"x"}}`,
+		`{"type":"evt.test.report","type":"evt.other.report","serv":"test","val_t":"bool","val":true}`,
+		`{"type":"evt.test.report","serv":"test","val":true}`,
+		`{"type":"evt.t\u00e6st","serv":"test","val_t":"string","val":"a\"b","props":{"k\u00e6":"v\n"},"tags":["\u00e6"]}`,
+	}
+	for _, msg := range codecTestMessages() {
+		data, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		payloads = append(payloads, string(data))
+	}
+
+	for _, payload := range payloads {
+		expected, expectedErr := legacyNewMessageFromBytes([]byte(payload))
+		actual, err := NewMessageFromBytes([]byte(payload))
+		assert.Equal(t, expectedErr != nil, err != nil, payload)
+		assert.Equal(t, expected, actual, payload)
+
+		pooled := AcquireMessage()
+		err = DecodeMessage([]byte(payload), pooled)
+		assert.Equal(t, expectedErr != nil, err != nil, payload)
+		if expected != nil && err == nil {
+			assert.Equal(t, expected, pooled, payload)
+		}
+		ReleaseMessage(pooled)
+	}
+}
+
+func TestFimpMessage_Reset(t *testing.T) {
+	msg := NewStringMessage("evt.test.report", "test", "a", Props{"a": "b"}, Tags{"t"}, nil)
+	msg.Reset()
+	assert.Empty(t, msg.Type)
+	assert.Nil(t, msg.Value)
+	assert.Empty(t, msg.Properties)
+	assert.Empty(t, msg.Tags)
+
+	require.NoError(t, DecodeMessage([]byte(`{"type":"evt.test.report","serv":"test","val_t":"int","val":1,"props":{"c":"d"}}`), msg))
+	assert.Equal(t, Props{"c": "d"}, msg.Properties)
+	assert.Nil(t, msg.Tags)
+}
+
+var benchmarkPayload = []byte(`{"type":"evt.sensor.report","serv":"sensor_temp","val_t":"float","val":21.5,"tags":null,"props":{"unit":"C"},"ver":"1","corid":"","ctime":"2024-05-01T10:00:00.000+02:00","uid":"5b5ee6d8-0f2a-4f1e-a9d4-3b3c1c7e2a11"}`)
+
+var benchmarkObjectPayload = []byte(`{"type":"evt.pd7.response","serv":"vinculum","val_t":"object","val":{"errors":null,"param":{"device":[{"id":1,"fimp":{"adapter":"zigbee","address":"1"}}]},"success":true},"tags":[],"props":{},"ver":"1","corid":"563c0d50","ctime":"2020-02-20T13:52:20+0100","uid":"d2bc48a5"}`)
+
+func BenchmarkNewMessageFromBytes_Legacy(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := legacyNewMessageFromBytes(benchmarkPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewMessageFromBytes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewMessageFromBytes(benchmarkPayload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeMessage_Pooled(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		msg := AcquireMessage()
+		if err := DecodeMessage(benchmarkPayload, msg); err != nil {
+			b.Fatal(err)
+		}
+		ReleaseMessage(msg)
+	}
+}
+
+func BenchmarkSerializeToJson_Legacy(b *testing.B) {
+	msg, err := NewMessageFromBytes(benchmarkPayload)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := legacySerializeToJson(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeToJson(b *testing.B) {
+	msg, err := NewMessageFromBytes(benchmarkPayload)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.SerializeToJson(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeToJson_ObjectPassThroughLegacy(b *testing.B) {
+	msg, err := NewMessageFromBytes(benchmarkObjectPayload)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := legacySerializeToJson(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSerializeToJson_ObjectPassThrough(b *testing.B) {
+	msg, err := NewMessageFromBytes(benchmarkObjectPayload)
+	require.NoError(b, err)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.SerializeToJson(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendJson(b *testing.B) {
+	msg, err := NewMessageFromBytes(benchmarkPayload)
+	require.NoError(b, err)
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if buf, err = msg.AppendJson(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// legacySerializeToJson is the original encoder , it's kept as the reference of the wire format.
+func legacySerializeToJson(msg *FimpMessage) ([]byte, error) {
+	jsonBA, err := json.Marshal(msg)
+	if msg.ValueType == VTypeObject {
+		if msg.Value == nil && msg.ValueObj != nil {
+			// This is for object pass though.
+			jsonBA, err = jsonparser.Set(jsonBA, msg.ValueObj, "val")
+		}
+	}
+	return jsonBA, err
+}
+
+// legacyNewMessageFromBytes is the original multi-pass decoder , it's kept as the reference of decoding results.
+func legacyNewMessageFromBytes(msg []byte) (*FimpMessage, error) {
+	fimpmsg := FimpMessage{}
+	var err error
+	fimpmsg.Type, err = jsonparser.GetString(msg, "type")
+	fimpmsg.Service, err = jsonparser.GetString(msg, "serv")
+	fimpmsg.ValueType, err = jsonparser.GetString(msg, "val_t")
+	fimpmsg.UID, _ = jsonparser.GetString(msg, "uid")
+	fimpmsg.CorrelationID, _ = jsonparser.GetString(msg, "corid")
+	fimpmsg.CreationTime, _ = jsonparser.GetString(msg, "ctime")
+	fimpmsg.ResponseToTopic, _ = jsonparser.GetString(msg, "resp_to")
+	fimpmsg.Source, _ = jsonparser.GetString(msg, "src")
+	fimpmsg.Topic, _ = jsonparser.GetString(msg, "topic")
+	fimpmsg.Version, _ = jsonparser.GetString(msg, "ver")
+
+	switch fimpmsg.ValueType {
+	case VTypeString:
+		fimpmsg.Value, err = jsonparser.GetString(msg, "val")
+	case VTypeBool:
+		fimpmsg.Value, err = jsonparser.GetBoolean(msg, "val")
+	case VTypeInt:
+		fimpmsg.Value, err = jsonparser.GetInt(msg, "val")
+	case VTypeFloat:
+		fimpmsg.Value, err = jsonparser.GetFloat(msg, "val")
+	case VTypeBoolArray:
+		val := make([]bool, 0)
+		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			item, _ := jsonparser.ParseBoolean(value)
+			val = append(val, item)
+		}, "val"); err != nil {
+			return nil, err
+		}
+
+		fimpmsg.Value = val
+	case VTypeStrArray:
+		val := make([]string, 0)
+		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			item, _ := jsonparser.ParseString(value)
+			val = append(val, item)
+		}, "val"); err != nil {
+			return nil, err
+		}
+
+		fimpmsg.Value = val
+	case VTypeIntArray:
+		val := make([]int64, 0)
+		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			item, _ := jsonparser.ParseInt(value)
+			val = append(val, item)
+
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+	case VTypeFloatArray:
+		val := make([]float64, 0)
+		if _, err := jsonparser.ArrayEach(msg, func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+			item, _ := jsonparser.ParseFloat(value)
+			val = append(val, item)
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+
+	case VTypeStrMap:
+		val := make(map[string]string)
+		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			val[string(key)], err = jsonparser.ParseString(value)
+			return nil
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+
+	case VTypeIntMap:
+		val := make(map[string]int64)
+		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			val[string(key)], err = jsonparser.ParseInt(value)
+			return nil
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+
+	case VTypeFloatMap:
+		val := make(map[string]float64)
+		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			val[string(key)], err = jsonparser.ParseFloat(value)
+			return nil
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+
+	case VTypeBoolMap:
+		val := make(map[string]bool)
+		if err := jsonparser.ObjectEach(msg, func(key []byte, value []byte, dataType jsonparser.ValueType, offset int) error {
+			val[string(key)], err = jsonparser.ParseBoolean(value)
+			return nil
+		}, "val"); err != nil {
+			return nil, err
+		}
+		fimpmsg.Value = val
+
+	case VTypeBinary:
+		fimpmsg.Value, err = jsonparser.GetString(msg, "val")
+		//base64val, err := jsonparser.GetString(msg, "val")
+		//if err != nil {
+		//	return nil,err
+		//}
+		//fimpmsg.Value ,err = base64.StdEncoding.DecodeString(base64val)
+		//if err != nil {
+		//	return nil,err
+		//}
+
+	case VTypeObject:
+		fimpmsg.ValueObj, _, _, err = jsonparser.Get(msg, "val")
+
+	}
+
+	if properties, dt, _, err := jsonparser.Get(msg, "props"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
+		err := json.Unmarshal(properties, &fimpmsg.Properties)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if storage, dt, _, err := jsonparser.Get(msg, "storage"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
+		err := json.Unmarshal(storage, &fimpmsg.Storage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if tags, dt, _, err := jsonparser.Get(msg, "tags"); dt != jsonparser.NotExist && dt != jsonparser.Null && err == nil {
+		err := json.Unmarshal(tags, &fimpmsg.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &fimpmsg, err
+}
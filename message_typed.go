@@ -0,0 +1,595 @@
+package fimpgo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Struct tag used to bind message value and properties to struct fields, for instance:
+//
+//	type TempReport struct {
+//		Temp float64 `fimp:"val"`
+//		Unit string  `fimp:"prop:unit,required"`
+//	}
+//
+// A struct with at least one fimp tag is a binding struct , its "val" field holds message value (null if there is no such field)
+// and "prop:<name>" fields hold message properties. Required property must be present when decoding and non-zero when encoding.
+// Structs without fimp tags are encoded as object values.
+const fimpTag = "fimp"
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	bytesType         = reflect.TypeOf([]byte(nil))
+	fimpMsgType       = reflect.TypeOf(FimpMessage{})
+	jsonUnmarshalType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// numericValueTypes groups value types , which can be decoded into each other
+	numericValueTypes = map[string]string{
+		VTypeInt:        VTypeFloat,
+		VTypeFloat:      VTypeFloat,
+		VTypeIntArray:   VTypeFloatArray,
+		VTypeFloatArray: VTypeFloatArray,
+		VTypeIntMap:     VTypeFloatMap,
+		VTypeFloatMap:   VTypeFloatMap,
+	}
+)
+
+// Decode converts message value into T. Value type of the message is validated against T ,
+// int values can be decoded into floats and integral float values into ints. Null value is decoded into nil pointer , slice or map.
+// If T is a binding struct , properties are decoded into "prop:<name>" fields.
+func Decode[T any](msg *FimpMessage) (T, error) {
+	var result T
+	if msg == nil {
+		return result, fmt.Errorf("%w: message is nil", errValueType)
+	}
+	err := decodeMessageValue(msg, reflect.ValueOf(&result).Elem())
+	return result, err
+}
+
+// NewTypedMessage creates new message , value type is inferred from T. If T is a binding struct , its prop fields are added to props.
+func NewTypedMessage[T any](type_ string, service string, value T, props Props, tags Tags, requestMessage *FimpMessage) (*FimpMessage, error) {
+	rv := reflect.ValueOf(&value).Elem()
+	var bindProps Props
+	if b, ok := bindingOf(rv.Type()); ok {
+		var err error
+		if bindProps, err = b.encodeProps(rv); err != nil {
+			return nil, err
+		}
+		if b.valField < 0 {
+			rv = reflect.Value{}
+		} else {
+			rv = rv.Field(b.valField)
+		}
+	}
+
+	valueType, encoded, err := encodeValue(rv)
+	if err != nil {
+		return nil, err
+	}
+	if len(bindProps) > 0 {
+		if props == nil {
+			props = make(Props, len(bindProps))
+		}
+		for k, v := range bindProps {
+			props[k] = v
+		}
+	}
+	return NewMessage(type_, service, valueType, encoded, props, tags, requestMessage), nil
+}
+
+// ValueTypeOf returns FIMP value type (val_t) inferred from T.
+func ValueTypeOf[T any]() (string, error) {
+	return inferValueType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+func inferValueType(t reflect.Type) (string, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == bytesType {
+		return VTypeBinary, nil
+	}
+	if _, ok := bindingOf(t); ok {
+		return "", fmt.Errorf("%w: binding struct %s has no single value type", errValueType, t)
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return VTypeString, nil
+	case reflect.Bool:
+		return VTypeBool, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return VTypeInt, nil
+	case reflect.Float32, reflect.Float64:
+		return VTypeFloat, nil
+	case reflect.Slice, reflect.Array:
+		if vt, ok := collectionValueType(t.Elem(), VTypeStrArray, VTypeIntArray, VTypeFloatArray, VTypeBoolArray); ok {
+			return vt, nil
+		}
+		return VTypeObject, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return "", fmt.Errorf("%w: map key of %s must be string", errValueType, t)
+		}
+		if vt, ok := collectionValueType(t.Elem(), VTypeStrMap, VTypeIntMap, VTypeFloatMap, VTypeBoolMap); ok {
+			return vt, nil
+		}
+		return VTypeObject, nil
+	case reflect.Struct, reflect.Interface:
+		return VTypeObject, nil
+	default:
+		return "", fmt.Errorf("%w: %s can't be used as message value", errValueType, t)
+	}
+}
+
+func collectionValueType(elem reflect.Type, str, integer, float, boolean string) (string, bool) {
+	switch elem.Kind() {
+	case reflect.String:
+		return str, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integer, true
+	case reflect.Float32, reflect.Float64:
+		return float, true
+	case reflect.Bool:
+		return boolean, true
+	default:
+		return "", false
+	}
+}
+
+// encodeValue returns value type and value normalized to the types produced by NewMessageFromBytes.
+func encodeValue(rv reflect.Value) (string, interface{}, error) {
+	for rv.IsValid() && (rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface) {
+		if rv.IsNil() {
+			return VTypeNull, nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return VTypeNull, nil, nil
+	}
+	valueType, err := inferValueType(rv.Type())
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch valueType {
+	case VTypeString:
+		return valueType, rv.String(), nil
+	case VTypeBool:
+		return valueType, rv.Bool(), nil
+	case VTypeInt:
+		if rv.CanUint() {
+			if rv.Uint() > math.MaxInt64 {
+				return "", nil, fmt.Errorf("%w: %d overflows int64", errValueType, rv.Uint())
+			}
+			return valueType, int64(rv.Uint()), nil
+		}
+		return valueType, rv.Int(), nil
+	case VTypeFloat:
+		return valueType, rv.Float(), nil
+	case VTypeBinary:
+		// the same encoding as NewBinaryMessage
+		return valueType, base64.StdEncoding.EncodeToString(rv.Bytes()), nil
+	case VTypeObject:
+		return valueType, rv.Interface(), nil
+	}
+
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.IsNil() {
+		return VTypeNull, nil, nil
+	}
+	target := reflect.New(valueTypeGoType(valueType)).Elem()
+	if err := assignValue(target, rv.Interface(), valueType); err != nil {
+		return "", nil, err
+	}
+	return valueType, target.Interface(), nil
+}
+
+// valueTypeGoType returns Go type NewMessageFromBytes produces for collection value types.
+func valueTypeGoType(valueType string) reflect.Type {
+	switch valueType {
+	case VTypeStrArray:
+		return reflect.TypeOf([]string(nil))
+	case VTypeIntArray:
+		return reflect.TypeOf([]int64(nil))
+	case VTypeFloatArray:
+		return reflect.TypeOf([]float64(nil))
+	case VTypeBoolArray:
+		return reflect.TypeOf([]bool(nil))
+	case VTypeStrMap:
+		return reflect.TypeOf(map[string]string(nil))
+	case VTypeIntMap:
+		return reflect.TypeOf(map[string]int64(nil))
+	case VTypeFloatMap:
+		return reflect.TypeOf(map[string]float64(nil))
+	default:
+		return reflect.TypeOf(map[string]bool(nil))
+	}
+}
+
+func decodeMessageValue(msg *FimpMessage, target reflect.Value) error {
+	if b, ok := bindingOf(target.Type()); ok {
+		if err := b.decodeProps(msg.Properties, target); err != nil {
+			return err
+		}
+		if b.valField < 0 {
+			return nil
+		}
+		target = target.Field(b.valField)
+	}
+
+	if err := checkValueType(target.Type(), msg.ValueType); err != nil {
+		return err
+	}
+	if msg.ValueType == VTypeObject {
+		return decodeObject(msg, target)
+	}
+	return assignValue(target, msg.Value, msg.ValueType)
+}
+
+// checkValueType rejects message value type , which doesn't match value type inferred from target. Ints and floats are interchangeable ,
+// null is validated when the value is assigned.
+func checkValueType(target reflect.Type, valueType string) error {
+	if valueType == VTypeNull {
+		return nil
+	}
+	for target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+	if target.Kind() == reflect.Interface {
+		return nil
+	}
+	if valueType == VTypeObject && reflect.PointerTo(target).Implements(jsonUnmarshalType) {
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		// collections of interface values accept any elements
+		if target.Elem().Kind() == reflect.Interface {
+			return nil
+		}
+	}
+	expected, err := inferValueType(target)
+	if err != nil {
+		return err
+	}
+	if expected == valueType {
+		return nil
+	}
+	if group, ok := numericValueTypes[expected]; ok && group == numericValueTypes[valueType] {
+		return nil
+	}
+	return fmt.Errorf("%w: %s value can't be decoded into %s", errValueType, valueType, target)
+}
+
+func decodeObject(msg *FimpMessage, target reflect.Value) error {
+	data := msg.ValueObj
+	if data == nil {
+		var err error
+		if data, err = json.Marshal(msg.Value); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, target.Addr().Interface()); err != nil {
+		return fmt.Errorf("%w: object can't be decoded into %s: %w", errValueType, target.Type(), err)
+	}
+	return nil
+}
+
+// assignValue sets target from decoded message value.
+func assignValue(target reflect.Value, src interface{}, valueType string) error {
+	if src == nil || valueType == VTypeNull {
+		switch target.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		return fmt.Errorf("%w: null value can't be decoded into %s", errValueType, target.Type())
+	}
+
+	switch target.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(target.Type().Elem())
+		if err := assignValue(elem.Elem(), src, valueType); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case reflect.Interface:
+		sv := reflect.ValueOf(src)
+		if !sv.Type().AssignableTo(target.Type()) {
+			return wrongValueType(target.Type(), src)
+		}
+		target.Set(sv)
+		return nil
+	}
+
+	if target.Type() == bytesType && valueType == VTypeBinary {
+		switch v := src.(type) {
+		case []byte:
+			target.SetBytes(append([]byte(nil), v...))
+			return nil
+		case string:
+			data, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return fmt.Errorf("%w: invalid base64 value: %w", errValueType, err)
+			}
+			target.SetBytes(data)
+			return nil
+		}
+		return wrongValueType(target.Type(), src)
+	}
+
+	sv := reflect.ValueOf(src)
+	switch target.Kind() {
+	case reflect.String:
+		if sv.Kind() != reflect.String {
+			return wrongValueType(target.Type(), src)
+		}
+		target.SetString(sv.String())
+	case reflect.Bool:
+		if sv.Kind() != reflect.Bool {
+			return wrongValueType(target.Type(), src)
+		}
+		target.SetBool(sv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(sv)
+		if !ok || target.OverflowInt(i) {
+			return wrongValueType(target.Type(), src)
+		}
+		target.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, ok := toInt64(sv)
+		if !ok || i < 0 || target.OverflowUint(uint64(i)) {
+			return wrongValueType(target.Type(), src)
+		}
+		target.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(sv)
+		if !ok || target.OverflowFloat(f) {
+			return wrongValueType(target.Type(), src)
+		}
+		target.SetFloat(f)
+	case reflect.Slice, reflect.Array:
+		if (sv.Kind() != reflect.Slice && sv.Kind() != reflect.Array) || sv.Type() == bytesType {
+			return wrongValueType(target.Type(), src)
+		}
+		var result reflect.Value
+		if target.Kind() == reflect.Array {
+			if sv.Len() != target.Len() {
+				return fmt.Errorf("%w: expected %d elements, got %d", errValueType, target.Len(), sv.Len())
+			}
+			result = reflect.New(target.Type()).Elem()
+		} else {
+			result = reflect.MakeSlice(target.Type(), sv.Len(), sv.Len())
+		}
+		for i := 0; i < sv.Len(); i++ {
+			if err := assignValue(result.Index(i), sv.Index(i).Interface(), ""); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		target.Set(result)
+	case reflect.Map:
+		if sv.Kind() != reflect.Map || target.Type().Key().Kind() != reflect.String || sv.Type().Key().Kind() != reflect.String {
+			return wrongValueType(target.Type(), src)
+		}
+		result := reflect.MakeMapWithSize(target.Type(), sv.Len())
+		iter := sv.MapRange()
+		for iter.Next() {
+			item := reflect.New(target.Type().Elem()).Elem()
+			if err := assignValue(item, iter.Value().Interface(), ""); err != nil {
+				return fmt.Errorf("key %s: %w", iter.Key().String(), err)
+			}
+			result.SetMapIndex(reflect.ValueOf(iter.Key().String()).Convert(target.Type().Key()), item)
+		}
+		target.Set(result)
+	default:
+		return wrongValueType(target.Type(), src)
+	}
+	return nil
+}
+
+func wrongValueType(expected reflect.Type, src interface{}) error {
+	return fmt.Errorf("%w: expected %s, got %T", errValueType, expected, src)
+}
+
+// toInt64 converts integer or integral float value into int64.
+func toInt64(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	default:
+		return 0, false
+	}
+}
+
+// toFloat64 converts any numeric value into float64.
+func toFloat64(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	default:
+		return 0, false
+	}
+}
+
+type (
+	binding struct {
+		valField int
+		props    []propBinding
+	}
+
+	propBinding struct {
+		field    int
+		name     string
+		required bool
+	}
+)
+
+// bindingOf parses fimp tags of struct type. The second result is false if the type is not a binding struct.
+func bindingOf(t reflect.Type) (*binding, bool) {
+	if t.Kind() != reflect.Struct || t == timeType || t == fimpMsgType {
+		return nil, false
+	}
+	b := &binding{valField: -1}
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup(fimpTag)
+		if !ok || !t.Field(i).IsExported() {
+			continue
+		}
+		found = true
+		parts := strings.Split(tag, ",")
+		switch {
+		case parts[0] == Val:
+			b.valField = i
+		case strings.HasPrefix(parts[0], "prop:"):
+			p := propBinding{field: i, name: strings.TrimPrefix(parts[0], "prop:")}
+			for _, opt := range parts[1:] {
+				if opt == "required" {
+					p.required = true
+				}
+			}
+			b.props = append(b.props, p)
+		}
+	}
+	return b, found
+}
+
+func (b *binding) decodeProps(props Props, target reflect.Value) error {
+	for _, p := range b.props {
+		val, ok := props[p.name]
+		if !ok {
+			if p.required {
+				return fmt.Errorf("%w: property %s is missing", errValueType, p.name)
+			}
+			continue
+		}
+		if err := parseProperty(target.Field(p.field), val); err != nil {
+			return fmt.Errorf("property %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+func (b *binding) encodeProps(source reflect.Value) (Props, error) {
+	props := make(Props, len(b.props))
+	for _, p := range b.props {
+		field := source.Field(p.field)
+		if p.required && field.IsZero() {
+			return nil, fmt.Errorf("%w: property %s is missing", errValueType, p.name)
+		}
+		val, ok, err := formatProperty(field)
+		if err != nil {
+			return nil, fmt.Errorf("property %s: %w", p.name, err)
+		}
+		if ok {
+			props[p.name] = val
+		}
+	}
+	return props, nil
+}
+
+func parseProperty(target reflect.Value, val string) error {
+	if target.Kind() == reflect.Ptr {
+		elem := reflect.New(target.Type().Elem())
+		if err := parseProperty(elem.Elem(), val); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	}
+	if target.Type() == timeType {
+		t := ParseTime(val)
+		if t.IsZero() {
+			return fmt.Errorf("%w: expected RFC3339 timestamp, got %s", errValueType, val)
+		}
+		target.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	var err error
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(val)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(val); err == nil {
+			target.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(val, 10, target.Type().Bits()); err == nil {
+			target.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(val, 10, target.Type().Bits()); err == nil {
+			target.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(val, target.Type().Bits()); err == nil {
+			target.SetFloat(f)
+		}
+	default:
+		return fmt.Errorf("%w: property can't be decoded into %s", errValueType, target.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errValueType, err)
+	}
+	return nil
+}
+
+// formatProperty formats struct field as property value. The second result is false for nil pointers.
+func formatProperty(v reflect.Value) (string, bool, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return "", false, nil
+		}
+		return t.Format(TimeFormat), true, nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), true, nil
+	default:
+		return "", false, fmt.Errorf("%w: %s can't be used as property", errValueType, v.Type())
+	}
+}
@@ -0,0 +1,233 @@
+package fimpgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tempReport struct {
+	Temp      float64   `fimp:"val"`
+	Unit      string    `fimp:"prop:unit,required"`
+	Precision *int      `fimp:"prop:precision"`
+	Timestamp time.Time `fimp:"prop:timestamp"`
+	Ignored   string
+}
+
+type levelPayload struct {
+	Level    int    `json:"level"`
+	Duration string `json:"duration,omitempty"`
+}
+
+func TestNewTypedMessage_ValueTypes(t *testing.T) {
+	tcs := []struct {
+		name      string
+		value     interface{}
+		valueType string
+		expected  interface{}
+	}{
+		{name: "string", value: "on", valueType: VTypeString, expected: "on"},
+		{name: "int", value: int32(5), valueType: VTypeInt, expected: int64(5)},
+		{name: "uint", value: uint8(7), valueType: VTypeInt, expected: int64(7)},
+		{name: "float", value: float32(1.5), valueType: VTypeFloat, expected: float64(1.5)},
+		{name: "bool", value: true, valueType: VTypeBool, expected: true},
+		{name: "bin", value: []byte{1, 2}, valueType: VTypeBinary, expected: "AQI="},
+		{name: "int array", value: []int{1, 2}, valueType: VTypeIntArray, expected: []int64{1, 2}},
+		{name: "str map", value: map[string]string{"a": "b"}, valueType: VTypeStrMap, expected: map[string]string{"a": "b"}},
+		{name: "float map", value: map[string]float32{"a": 2}, valueType: VTypeFloatMap, expected: map[string]float64{"a": 2}},
+		{name: "object", value: levelPayload{Level: 3}, valueType: VTypeObject, expected: levelPayload{Level: 3}},
+		{name: "nil pointer", value: (*int)(nil), valueType: VTypeNull, expected: nil},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := NewTypedMessage("evt.test.report", "test", tc.value, nil, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tc.valueType, msg.ValueType)
+			assert.Equal(t, tc.expected, msg.Value)
+		})
+	}
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	msg, err := NewTypedMessage("cmd.lvl.set", "out_lvl_switch", levelPayload{Level: 50, Duration: "5s"}, nil, nil, nil)
+	require.NoError(t, err)
+	data, err := msg.SerializeToJson()
+	require.NoError(t, err)
+	parsed, err := NewMessageFromBytes(data)
+	require.NoError(t, err)
+
+	payload, err := Decode[levelPayload](parsed)
+	require.NoError(t, err)
+	assert.Equal(t, levelPayload{Level: 50, Duration: "5s"}, payload)
+
+	arrMsg, err := NewTypedMessage("evt.test.report", "test", []uint16{1, 2, 3}, nil, nil, nil)
+	require.NoError(t, err)
+	data, err = arrMsg.SerializeToJson()
+	require.NoError(t, err)
+	parsed, err = NewMessageFromBytes(data)
+	require.NoError(t, err)
+	arr, err := Decode[[]uint16](parsed)
+	require.NoError(t, err)
+	assert.Equal(t, []uint16{1, 2, 3}, arr)
+}
+
+func TestDecode_Widening(t *testing.T) {
+	f, err := Decode[float64](NewIntMessage("evt.test.report", "test", 21, nil, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 21.0, f)
+
+	i, err := Decode[int](NewFloatMessage("evt.test.report", "test", 21.0, nil, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, 21, i)
+
+	_, err = Decode[int](NewFloatMessage("evt.test.report", "test", 21.5, nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	_, err = Decode[int8](NewIntMessage("evt.test.report", "test", 300, nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	_, err = Decode[string](NewIntMessage("evt.test.report", "test", 1, nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	floats, err := Decode[[]float64](NewIntArrayMessage("evt.test.report", "test", []int64{1, 2}, nil, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, floats)
+}
+
+func TestDecode_Null(t *testing.T) {
+	msg := NewNullMessage("evt.test.report", "test", nil, nil, nil)
+
+	p, err := Decode[*int](msg)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	s, err := Decode[[]string](msg)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = Decode[int](msg)
+	assert.True(t, IsWrongValueType(err))
+}
+
+func TestDecode_Binary(t *testing.T) {
+	data, err := NewBinaryMessage("evt.test.report", "test", []byte("hello"), nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	msg, err := NewMessageFromBytes(data)
+	require.NoError(t, err)
+
+	b, err := Decode[[]byte](msg)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), b)
+}
+
+func TestNewTypedMessage_BinaryEncoding(t *testing.T) {
+	typed, err := NewTypedMessage("evt.test.report", "test", []byte("hello"), nil, nil, nil)
+	require.NoError(t, err)
+	binary := NewBinaryMessage("evt.test.report", "test", []byte("hello"), nil, nil, nil)
+	typed.UID, typed.CreationTime = binary.UID, binary.CreationTime
+	assert.Equal(t, binary.Value, typed.Value)
+
+	// the same payload must be encoded identically regardless of constructor
+	for _, payloadType := range []string{DefaultPayload, CBORPayload, MsgPackPayload} {
+		typedData, err := EncodePayload(payloadType, typed)
+		require.NoError(t, err)
+		binaryData, err := EncodePayload(payloadType, binary)
+		require.NoError(t, err)
+		assert.Equal(t, binaryData, typedData, payloadType)
+	}
+
+	b, err := Decode[[]byte](typed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), b)
+}
+
+func TestDecode_PropertyBinding(t *testing.T) {
+	precision := 2
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	msg, err := NewTypedMessage("evt.sensor.report", "sensor_temp",
+		tempReport{Temp: 21.5, Unit: "C", Precision: &precision, Timestamp: ts, Ignored: "x"},
+		Props{"src": "test"}, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, VTypeFloat, msg.ValueType)
+	assert.Equal(t, 21.5, msg.Value)
+	assert.Equal(t, Props{"src": "test", "unit": "C", "precision": "2", "timestamp": ts.Format(TimeFormat)}, msg.Properties)
+
+	data, err := msg.SerializeToJson()
+	require.NoError(t, err)
+	parsed, err := NewMessageFromBytes(data)
+	require.NoError(t, err)
+
+	report, err := Decode[tempReport](parsed)
+	require.NoError(t, err)
+	assert.Equal(t, 21.5, report.Temp)
+	assert.Equal(t, "C", report.Unit)
+	require.NotNil(t, report.Precision)
+	assert.Equal(t, 2, *report.Precision)
+	assert.True(t, ts.Equal(report.Timestamp))
+	assert.Empty(t, report.Ignored)
+
+	delete(parsed.Properties, "unit")
+	_, err = Decode[tempReport](parsed)
+	assert.True(t, IsWrongValueType(err))
+
+	parsed.Properties["unit"] = "C"
+	parsed.Properties["precision"] = "high"
+	_, err = Decode[tempReport](parsed)
+	assert.True(t, IsWrongValueType(err))
+
+	_, err = NewTypedMessage("evt.sensor.report", "sensor_temp", tempReport{Temp: 1}, nil, nil, nil)
+	assert.True(t, IsWrongValueType(err))
+}
+
+func TestValueTypeOf(t *testing.T) {
+	vt, err := ValueTypeOf[map[string]bool]()
+	require.NoError(t, err)
+	assert.Equal(t, VTypeBoolMap, vt)
+
+	vt, err = ValueTypeOf[*levelPayload]()
+	require.NoError(t, err)
+	assert.Equal(t, VTypeObject, vt)
+
+	_, err = ValueTypeOf[chan int]()
+	assert.True(t, IsWrongValueType(err))
+}
+
+func TestNewTypedMessage_Array(t *testing.T) {
+	msg, err := NewTypedMessage("evt.test.report", "test", [3]int{1, 2, 3}, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, VTypeIntArray, msg.ValueType)
+	assert.Equal(t, []int64{1, 2, 3}, msg.Value)
+
+	arr, err := Decode[[3]int](msg)
+	require.NoError(t, err)
+	assert.Equal(t, [3]int{1, 2, 3}, arr)
+
+	_, err = Decode[[2]int](msg)
+	assert.True(t, IsWrongValueType(err))
+}
+
+func TestDecode_ValueTypeMismatch(t *testing.T) {
+	// value matches the target , but val_t doesn't
+	_, err := Decode[string](NewMessage("evt.test.report", "test", VTypeBinary, "aGVsbG8=", nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	_, err = Decode[[]string](NewMessage("evt.test.report", "test", VTypeObject, []string{"a"}, nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	_, err = Decode[map[string]int](NewMessage("evt.test.report", "test", VTypeIntArray, map[string]int64{"a": 1}, nil, nil, nil))
+	assert.True(t, IsWrongValueType(err))
+
+	// interface targets accept any value type
+	v, err := Decode[interface{}](NewStringMessage("evt.test.report", "test", "on", nil, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "on", v)
+
+	m, err := Decode[map[string]interface{}](NewStrMapMessage("evt.test.report", "test", map[string]string{"a": "b"}, nil, nil, nil))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": "b"}, m)
+}
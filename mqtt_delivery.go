@@ -0,0 +1,212 @@
+package fimpgo
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const defaultChannelQueueSize = 100
+
+// MessageHandlerID is the id of message handler delivery queue. It can be used to set overflow policy of the handler and to read drop counter.
+const MessageHandlerID = "$handler"
+
+// deliveryWorker owns bounded delivery queue of one subscriber. Messages are delivered in the order they were queued ,
+// so ordering per topic is preserved , while slow subscriber doesn't delay other subscribers.
+// With block policy messages , which don't fit into the queue , wait in the backlog , the backlog is moved into the queue by worker's own feeder ,
+// so only the feeder waits for slow subscriber.
+type deliveryWorker struct {
+	queue MessageCh
+	stop  chan struct{}
+	done  chan struct{}
+
+	backlogMux    sync.Mutex
+	backlog       []*Message
+	backlogLimit  int
+	backlogSignal chan struct{}
+}
+
+func newDeliveryWorker(queueSize int, deliver func(msg *Message, stop <-chan struct{})) *deliveryWorker {
+	if queueSize <= 0 {
+		queueSize = defaultChannelQueueSize
+	}
+	w := &deliveryWorker{
+		queue:         make(MessageCh, queueSize),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+		backlogSignal: make(chan struct{}, 1),
+	}
+	go w.run(deliver)
+	return w
+}
+
+func (w *deliveryWorker) run(deliver func(msg *Message, stop <-chan struct{})) {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case msg := <-w.queue:
+			deliver(msg, w.stop)
+		}
+	}
+}
+
+// startFeeder starts goroutine , which moves backlog into the queue. Every message waits for space in the queue no longer than timeout ,
+// onTimeout is invoked for messages , which didn't get into the queue.
+func (w *deliveryWorker) startFeeder(backlogLimit int, timeout time.Duration, onTimeout func(msg *Message)) {
+	w.backlogLimit = backlogLimit
+	go w.feed(timeout, onTimeout)
+}
+
+// push adds message to the queue or to the backlog if the queue is full. Returns false if the backlog is full too.
+func (w *deliveryWorker) push(msg *Message) bool {
+	w.backlogMux.Lock()
+	defer w.backlogMux.Unlock()
+	if len(w.backlog) == 0 {
+		select {
+		case w.queue <- msg:
+			return true
+		default:
+		}
+	}
+	if len(w.backlog) >= w.backlogLimit {
+		return false
+	}
+	w.backlog = append(w.backlog, msg)
+	select {
+	case w.backlogSignal <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (w *deliveryWorker) feed(timeout time.Duration, onTimeout func(msg *Message)) {
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.backlogSignal:
+		}
+		for {
+			// the message stays in the backlog until it's queued , so new messages can't overtake it
+			w.backlogMux.Lock()
+			if len(w.backlog) == 0 {
+				w.backlog = nil
+				w.backlogMux.Unlock()
+				break
+			}
+			msg := w.backlog[0]
+			w.backlogMux.Unlock()
+
+			timer := time.NewTimer(timeout)
+			select {
+			case w.queue <- msg:
+			case <-timer.C:
+				onTimeout(msg)
+			case <-w.stop:
+				timer.Stop()
+				return
+			}
+			timer.Stop()
+
+			w.backlogMux.Lock()
+			w.backlog[0] = nil
+			w.backlog = w.backlog[1:]
+			w.backlogMux.Unlock()
+		}
+	}
+}
+
+// depth returns number of messages waiting in the queue and in the backlog.
+func (w *deliveryWorker) depth() int {
+	w.backlogMux.Lock()
+	defer w.backlogMux.Unlock()
+	return len(w.queue) + len(w.backlog)
+}
+
+// close stops the worker without waiting for it. Queued messages are dropped.
+func (w *deliveryWorker) close() {
+	close(w.stop)
+}
+
+// channelDelivery returns delivery function , which forwards messages to subscriber channel.
+func channelDelivery(ch MessageCh) func(msg *Message, stop <-chan struct{}) {
+	return func(msg *Message, stop <-chan struct{}) {
+		select {
+		case ch <- msg:
+		case <-stop:
+		}
+	}
+}
+
+// handlerDelivery returns delivery function , which invokes message handler.
+func handlerDelivery(handler MessageHandler) func(msg *Message, stop <-chan struct{}) {
+	return func(msg *Message, _ <-chan struct{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("<MqttAd> Message handler CRASHED with error :", r)
+			}
+		}()
+		handler(msg.Topic, msg.Addr, msg.Payload, msg.RawPayload)
+	}
+}
+
+// SetChannelQueueSize sets size of delivery queue , which is created for every channel and message handler registered after the call.
+func (mh *MqttTransport) SetChannelQueueSize(size int) {
+	mh.channelRegMux.Lock()
+	mh.channelQueueSize = size
+	mh.channelRegMux.Unlock()
+}
+
+// ChannelQueueDepth returns number of messages waiting in delivery queue of the channel or message handler (MessageHandlerID).
+func (mh *MqttTransport) ChannelQueueDepth(channelId string) int {
+	mh.channelRegMux.Lock()
+	defer mh.channelRegMux.Unlock()
+	if w, ok := mh.subWorkers[channelId]; ok {
+		return w.depth()
+	}
+	return 0
+}
+
+// startWorker replaces delivery worker of the channel. Must be called with channelRegMux held.
+func (mh *MqttTransport) startWorker(channelId string, deliver func(msg *Message, stop <-chan struct{})) {
+	mh.stopWorker(channelId)
+	if mh.subWorkers == nil {
+		mh.subWorkers = make(map[string]*deliveryWorker)
+	}
+	w := newDeliveryWorker(mh.channelQueueSize, deliver)
+	// the backlog holds as many messages as main queue , which was filled while dispatch waited for slow channel
+	backlogLimit := cap(mh.mainQueue)
+	if backlogLimit == 0 {
+		backlogLimit = defaultMainQueueSize
+	}
+	w.startFeeder(backlogLimit, time.Second*time.Duration(mh.receiveChTimeout), func(msg *Message) {
+		log.Info("<MqttAd> Channel is not read for ", mh.receiveChTimeout)
+		mh.recordDrop(channelId, msg.Topic, mh.channelPolicy(channelId))
+	})
+	mh.subWorkers[channelId] = w
+}
+
+func (mh *MqttTransport) channelPolicy(channelId string) OverflowPolicy {
+	mh.channelRegMux.Lock()
+	defer mh.channelRegMux.Unlock()
+	return mh.channelPolicies[channelId]
+}
+
+// stopWorker stops delivery worker of the channel. Must be called with channelRegMux held.
+func (mh *MqttTransport) stopWorker(channelId string) {
+	if w, ok := mh.subWorkers[channelId]; ok {
+		w.close()
+		delete(mh.subWorkers, channelId)
+	}
+}
+
+func (mh *MqttTransport) stopWorkers() {
+	mh.channelRegMux.Lock()
+	for id := range mh.subWorkers {
+		mh.stopWorker(id)
+	}
+	mh.channelRegMux.Unlock()
+}
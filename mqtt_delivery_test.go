@@ -0,0 +1,146 @@
+package fimpgo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMqttTransport_ParallelDelivery(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ChannelQueueSize: 10})
+	defer mh.stopWorkers()
+
+	// slow channel is never read
+	mh.RegisterChannel("slow", make(MessageCh))
+	fastCh := make(MessageCh)
+	mh.RegisterChannelWithFilter("fast", fastCh, FimpFilter{Topic: "pt:j1/mt:evt/#", Service: "*", Interface: "*"})
+	handlerCh := make(chan string, 20)
+	mh.SetMessageHandler(func(topic string, addr *Address, iotMsg *FimpMessage, rawPayload []byte) {
+		val, _ := iotMsg.GetIntValue()
+		handlerCh <- fmt.Sprintf("%s/%d", topic, val)
+	})
+
+	topics := []string{"pt:j1/mt:evt/rt:dev/rn:test/ad:1/sv:sensor_temp/ad:1", "pt:j1/mt:evt/rt:dev/rn:test/ad:1/sv:sensor_temp/ad:2"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			for _, topic := range topics {
+				payload, err := NewIntMessage("evt.sensor.report", "sensor_temp", int64(i), nil, nil, nil).SerializeToJson()
+				require.NoError(t, err)
+				mh.handleIncomingMessage(&spilledMessage{topic: topic, payload: payload})
+			}
+		}
+	}()
+
+	lastByTopic := map[string]int64{}
+	for i := 0; i < 10; i++ {
+		select {
+		case msg := <-fastCh:
+			val, err := msg.Payload.GetIntValue()
+			require.NoError(t, err)
+			if last, ok := lastByTopic[msg.Topic]; ok {
+				assert.Equal(t, last+1, val, "messages of the same topic must be delivered in order")
+			}
+			lastByTopic[msg.Topic] = val
+		case <-time.After(time.Second):
+			t.Fatal("fast channel was blocked by slow channel")
+		}
+	}
+	<-done
+
+	for i := 0; i < 10; i++ {
+		select {
+		case <-handlerCh:
+		case <-time.After(time.Second):
+			t.Fatal("message handler was not invoked")
+		}
+	}
+	assert.Eventually(t, func() bool { return mh.ChannelQueueDepth("slow") == 9 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), mh.DroppedMessages("slow"))
+
+	mh.UnregisterChannel("slow")
+	assert.Equal(t, 0, mh.ChannelQueueDepth("slow"))
+}
+
+func TestMqttTransport_BlockedChannelDoesntDelayDispatch(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ChannelQueueSize: 10, ReceiveChTimeout: 10})
+	defer mh.stopWorkers()
+
+	// slow channel is never read , default policy waits for it up to 10s
+	mh.RegisterChannel("slow", make(MessageCh))
+	fastCh := make(MessageCh)
+	mh.RegisterChannel("fast", fastCh)
+
+	payload, err := NewIntMessage("evt.sensor.report", "sensor_temp", 1, nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			mh.handleIncomingMessage(&spilledMessage{topic: overflowTestTopic(0), payload: payload})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		select {
+		case <-fastCh:
+		case <-time.After(time.Second):
+			t.Fatal("fast channel was blocked by slow channel")
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dispatch was blocked by slow channel")
+	}
+	// one message is waited for by the worker , the rest are queued or in the backlog
+	assert.Eventually(t, func() bool { return mh.ChannelQueueDepth("slow") == 19 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(0), mh.DroppedMessages("slow"))
+	assert.Equal(t, uint64(0), mh.DroppedMessages("fast"))
+}
+
+func TestDeliveryWorker_Backlog(t *testing.T) {
+	w := &deliveryWorker{queue: make(MessageCh, 1), stop: make(chan struct{}), backlogSignal: make(chan struct{}, 1)}
+	defer close(w.stop)
+	timedOut := make(chan string, 10)
+	w.startFeeder(2, 200*time.Millisecond, func(msg *Message) {
+		timedOut <- msg.Topic
+	})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, w.push(overflowTestMessage(t, i)))
+	}
+	// backlog is full
+	assert.False(t, w.push(overflowTestMessage(t, 3)))
+	assert.Equal(t, 3, w.depth())
+
+	// backlog is moved to the queue in order
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-w.queue:
+			assert.Equal(t, overflowTestTopic(i), msg.Topic)
+		case <-time.After(time.Second):
+			t.Fatal("backlog wasn't moved to the queue")
+		}
+	}
+
+	assert.Eventually(t, func() bool { return w.depth() == 0 }, time.Second, 10*time.Millisecond)
+
+	// messages , which don't get into the queue within timeout , are dropped
+	for i := 0; i < 3; i++ {
+		assert.True(t, w.push(overflowTestMessage(t, i)))
+	}
+	for i := 1; i < 3; i++ {
+		select {
+		case topic := <-timedOut:
+			assert.Equal(t, overflowTestTopic(i), topic)
+		case <-time.After(time.Second):
+			t.Fatal("backlog message wasn't dropped")
+		}
+	}
+	assert.Eventually(t, func() bool { return w.depth() == 1 }, time.Second, 10*time.Millisecond)
+}
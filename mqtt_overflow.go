@@ -0,0 +1,558 @@
+package fimpgo
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// OverflowPolicy defines what transport does with an inbound message when a queue or a subscriber channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDefault is OverflowDropNewest for main queue and OverflowBlock for channels.
+	OverflowDefault OverflowPolicy = iota
+	// OverflowBlock waits until there is space in the queue. Channels are waited for no longer than receive channel timeout ,
+	// after that the message is dropped. Channel is waited for by its own delivery worker , so slow channel doesn't delay other channels.
+	OverflowBlock
+	// OverflowDropNewest drops the new message.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued message to make space for the new one.
+	OverflowDropOldest
+	// OverflowSpillToDisk writes messages to a temporary file and delivers them once the queue has space again. Ordering is preserved.
+	// Spilled messages are not persisted across restarts.
+	OverflowSpillToDisk
+)
+
+// MainQueueID is the id used to report drops from transport main queue.
+const MainQueueID = "$main"
+
+const spillFlushInterval = time.Second
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDefault:
+		return "default"
+	case OverflowBlock:
+		return "block"
+	case OverflowDropNewest:
+		return "drop-newest"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowSpillToDisk:
+		return "spill-to-disk"
+	default:
+		return fmt.Sprintf("OverflowPolicy(%d)", int(p))
+	}
+}
+
+// DropHandler is invoked every time a message is dropped. queueId is either channel id or MainQueueID.
+// The handler is invoked synchronously from message processing goroutine and must not block.
+type DropHandler func(queueId string, topic string, policy OverflowPolicy)
+
+// SetMainQueueOverflowPolicy sets overflow policy of transport main queue. Default is OverflowDropNewest.
+func (mh *MqttTransport) SetMainQueueOverflowPolicy(policy OverflowPolicy) {
+	mh.overflowMux.Lock()
+	mh.mainQueuePolicy = policy
+	mh.overflowMux.Unlock()
+}
+
+// SetChannelOverflowPolicy sets overflow policy of registered channel. Default is OverflowBlock.
+func (mh *MqttTransport) SetChannelOverflowPolicy(channelId string, policy OverflowPolicy) {
+	mh.channelRegMux.Lock()
+	defer mh.channelRegMux.Unlock()
+	if mh.channelPolicies == nil {
+		mh.channelPolicies = make(map[string]OverflowPolicy)
+	}
+	mh.channelPolicies[channelId] = policy
+}
+
+// SetDropHandler sets callback , which is invoked every time a message is dropped.
+func (mh *MqttTransport) SetDropHandler(handler DropHandler) {
+	mh.overflowMux.Lock()
+	mh.dropHandler = handler
+	mh.overflowMux.Unlock()
+}
+
+// SetSpillDir sets directory for spill files. Default is system temporary directory.
+func (mh *MqttTransport) SetSpillDir(dir string) {
+	mh.overflowMux.Lock()
+	mh.spillDir = dir
+	mh.overflowMux.Unlock()
+}
+
+// DroppedMessages returns number of messages dropped by a channel or by main queue (MainQueueID).
+func (mh *MqttTransport) DroppedMessages(queueId string) uint64 {
+	mh.overflowMux.RLock()
+	defer mh.overflowMux.RUnlock()
+	if counter, ok := mh.dropCounters[queueId]; ok {
+		return atomic.LoadUint64(counter)
+	}
+	return 0
+}
+
+func (mh *MqttTransport) recordDrop(queueId, topic string, policy OverflowPolicy) {
+	mh.overflowMux.Lock()
+	if mh.dropCounters == nil {
+		mh.dropCounters = make(map[string]*uint64)
+	}
+	counter, ok := mh.dropCounters[queueId]
+	if !ok {
+		counter = new(uint64)
+		mh.dropCounters[queueId] = counter
+	}
+	handler := mh.dropHandler
+	mh.overflowMux.Unlock()
+
+	atomic.AddUint64(counter, 1)
+	log.Warnf("<MqttAd> Message from topic %s dropped by %s , policy %s", topic, queueId, policy)
+	if handler != nil {
+		handler(queueId, topic, policy)
+	}
+}
+
+func (mh *MqttTransport) getMainQueuePolicy() OverflowPolicy {
+	mh.overflowMux.RLock()
+	defer mh.overflowMux.RUnlock()
+	return mh.mainQueuePolicy
+}
+
+// newSpillQueue creates spill file in configured directory.
+func (mh *MqttTransport) newSpillQueue() (*spillQueue, error) {
+	mh.overflowMux.RLock()
+	dir := mh.spillDir
+	mh.overflowMux.RUnlock()
+	return newSpillQueue(dir)
+}
+
+// enqueueMainMessage adds message to main queue applying main queue overflow policy.
+func (mh *MqttTransport) enqueueMainMessage(msg MQTT.Message) {
+	policy := mh.getMainQueuePolicy()
+	switch policy {
+	case OverflowBlock:
+		mh.mainQueue <- msg
+	case OverflowDropOldest:
+		for {
+			select {
+			case mh.mainQueue <- msg:
+				return
+			default:
+			}
+			select {
+			case old := <-mh.mainQueue:
+				mh.recordDrop(MainQueueID, old.Topic(), policy)
+			default:
+			}
+		}
+	case OverflowSpillToDisk:
+		mh.spillMainMessage(msg)
+	default:
+		select {
+		case mh.mainQueue <- msg:
+		default:
+			log.Warn("<MqttAd> Main message queue is full")
+			mh.recordDrop(MainQueueID, msg.Topic(), policy)
+		}
+	}
+}
+
+// spillMainMessage adds message to main queue or to spill file if the queue is full or there are already spilled messages.
+func (mh *MqttTransport) spillMainMessage(msg MQTT.Message) {
+	mh.mainSpillMux.Lock()
+	defer mh.mainSpillMux.Unlock()
+
+	if mh.mainSpill == nil || mh.mainSpill.Len() == 0 {
+		select {
+		case mh.mainQueue <- msg:
+			return
+		default:
+		}
+	}
+	if mh.mainSpill == nil {
+		queue, err := mh.newSpillQueue()
+		if err != nil {
+			log.Error("<MqttAd> Spill file can't be created. Error :", err)
+			mh.recordDrop(MainQueueID, msg.Topic(), OverflowSpillToDisk)
+			return
+		}
+		mh.mainSpill = queue
+	}
+	rec := spillRecord{topic: msg.Topic(), payload: msg.Payload()}
+	if v5msg, ok := msg.(propertiesCarrier); ok {
+		rec.meta = spillMeta{Properties: v5msg.properties()}
+	}
+	if err := mh.mainSpill.Push(rec); err != nil {
+		log.Error("<MqttAd> Message can't be spilled to disk. Error :", err)
+		mh.recordDrop(MainQueueID, msg.Topic(), OverflowSpillToDisk)
+		return
+	}
+	select {
+	case mh.spillSignal <- struct{}{}:
+	default:
+	}
+}
+
+// popMainSpill returns the oldest spilled message.
+func (mh *MqttTransport) popMainSpill() (MQTT.Message, bool) {
+	mh.mainSpillMux.Lock()
+	defer mh.mainSpillMux.Unlock()
+	if mh.mainSpill == nil || mh.mainSpill.Len() == 0 {
+		return nil, false
+	}
+	rec, err := mh.mainSpill.Pop()
+	if err != nil {
+		log.Error("<MqttAd> Spilled message can't be read. Error :", err)
+		return nil, false
+	}
+	return &spilledMessage{topic: rec.topic, payload: rec.payload, props: rec.meta.Properties}, true
+}
+
+func (mh *MqttTransport) closeSpillQueues() {
+	mh.mainSpillMux.Lock()
+	if mh.mainSpill != nil {
+		mh.mainSpill.Close()
+		mh.mainSpill = nil
+	}
+	mh.mainSpillMux.Unlock()
+
+	mh.channelRegMux.Lock()
+	for id, queue := range mh.channelSpills {
+		queue.Close()
+		delete(mh.channelSpills, id)
+	}
+	mh.channelRegMux.Unlock()
+}
+
+// deliverToChannel sends message to delivery queue of subscriber channel applying channel overflow policy.
+func (mh *MqttTransport) deliverToChannel(channelId string, w *deliveryWorker, policy OverflowPolicy, msg *Message) {
+	ch := w.queue
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case ch <- msg:
+		default:
+			mh.recordDrop(channelId, msg.Topic, policy)
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case ch <- msg:
+				return
+			default:
+			}
+			select {
+			case old := <-ch:
+				mh.recordDrop(channelId, old.Topic, policy)
+			default:
+			}
+		}
+	case OverflowSpillToDisk:
+		mh.spillChannelMessage(channelId, ch, msg)
+	default:
+		// waiting for slow channel is done by the feeder of the channel , so other channels aren't delayed
+		if !w.push(msg) {
+			log.Info("<MqttAd> Channel backlog is full")
+			mh.recordDrop(channelId, msg.Topic, policy)
+		}
+	}
+}
+
+func (mh *MqttTransport) channelSpill(channelId string, create bool) (*spillQueue, error) {
+	mh.channelRegMux.Lock()
+	defer mh.channelRegMux.Unlock()
+	if queue, ok := mh.channelSpills[channelId]; ok || !create {
+		return queue, nil
+	}
+	queue, err := mh.newSpillQueue()
+	if err != nil {
+		return nil, err
+	}
+	if mh.channelSpills == nil {
+		mh.channelSpills = make(map[string]*spillQueue)
+	}
+	mh.channelSpills[channelId] = queue
+	return queue, nil
+}
+
+// spillChannelMessage delivers spilled messages first and spills the new message if the channel is still full.
+func (mh *MqttTransport) spillChannelMessage(channelId string, ch MessageCh, msg *Message) {
+	queue, _ := mh.channelSpill(channelId, false)
+	if queue == nil || mh.flushChannelSpill(queue, ch) {
+		select {
+		case ch <- msg:
+			return
+		default:
+		}
+	}
+
+	queue, err := mh.channelSpill(channelId, true)
+	if err == nil {
+		err = queue.Push(newSpillRecord(msg))
+	}
+	if err != nil {
+		log.Error("<MqttAd> Message can't be spilled to disk. Error :", err)
+		mh.recordDrop(channelId, msg.Topic, OverflowSpillToDisk)
+	}
+}
+
+// flushChannelSpill delivers spilled messages without blocking. Returns true if all spilled messages were delivered.
+func (mh *MqttTransport) flushChannelSpill(queue *spillQueue, ch MessageCh) bool {
+	for queue.Len() > 0 {
+		rec, err := queue.Peek()
+		if err != nil {
+			log.Error("<MqttAd> Spilled message can't be read. Error :", err)
+			return false
+		}
+		msg, err := rec.message()
+		if err != nil {
+			log.Error("<MqttAd> Spilled message can't be decoded. Error :", err)
+			_ = queue.Discard()
+			continue
+		}
+		select {
+		case ch <- msg:
+			_ = queue.Discard()
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// flushChannelSpills delivers spilled messages of all channels , it's invoked periodically so spilled messages don't wait for the next inbound message.
+func (mh *MqttTransport) flushChannelSpills() {
+	type spilledChannel struct {
+		queue *spillQueue
+		ch    MessageCh
+	}
+	var channels []spilledChannel
+	mh.channelRegMux.Lock()
+	for id, queue := range mh.channelSpills {
+		if w, ok := mh.subWorkers[id]; ok && queue.Len() > 0 {
+			channels = append(channels, spilledChannel{queue: queue, ch: w.queue})
+		}
+	}
+	mh.channelRegMux.Unlock()
+
+	for _, c := range channels {
+		mh.flushChannelSpill(c.queue, c.ch)
+	}
+}
+
+// spilledMessage implements MQTT.Message for messages read back from spill file.
+type spilledMessage struct {
+	topic   string
+	payload []byte
+	props   *MessageProperties
+}
+
+func (m *spilledMessage) Duplicate() bool   { return false }
+func (m *spilledMessage) Qos() byte         { return 0 }
+func (m *spilledMessage) Retained() bool    { return false }
+func (m *spilledMessage) Topic() string     { return m.topic }
+func (m *spilledMessage) MessageID() uint16 { return 0 }
+func (m *spilledMessage) Payload() []byte   { return m.payload }
+func (m *spilledMessage) Ack()              {}
+
+func (m *spilledMessage) properties() *MessageProperties {
+	return m.props
+}
+
+type spillRecord struct {
+	topic   string
+	payload []byte
+	isRaw   bool
+	meta    spillMeta
+}
+
+// spillMeta is part of delivered message , which isn't carried by the topic and the payload. It's stored as JSON.
+type spillMeta struct {
+	Properties *MessageProperties `json:"props,omitempty"`
+	Auth       *Authentication    `json:"auth,omitempty"`
+	RawPayload []byte             `json:"raw,omitempty"` // raw payload of decoded message
+}
+
+func (m spillMeta) isEmpty() bool {
+	return m.Properties == nil && m.Auth == nil && len(m.RawPayload) == 0
+}
+
+func newSpillRecord(msg *Message) spillRecord {
+	meta := spillMeta{Properties: msg.Properties, Auth: msg.Auth}
+	if msg.Payload == nil {
+		return spillRecord{topic: msg.Topic, payload: msg.RawPayload, isRaw: true, meta: meta}
+	}
+	payload, err := msg.Payload.SerializeToJson()
+	if err != nil {
+		return spillRecord{topic: msg.Topic, payload: msg.RawPayload, isRaw: true, meta: meta}
+	}
+	meta.RawPayload = msg.RawPayload
+	return spillRecord{topic: msg.Topic, payload: payload, meta: meta}
+}
+
+func (r spillRecord) message() (*Message, error) {
+	addr, err := NewAddressFromString(r.topic)
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{Topic: r.topic, Addr: addr, Properties: r.meta.Properties, Auth: r.meta.Auth}
+	if r.isRaw {
+		msg.RawPayload = r.payload
+		return msg, nil
+	}
+	if msg.Payload, err = NewMessageFromBytes(r.payload); err != nil {
+		return nil, err
+	}
+	msg.RawPayload = r.meta.RawPayload
+	return msg, nil
+}
+
+// spillHeaderSize is size of record header : raw flag and lengths of topic , payload and metadata.
+const spillHeaderSize = 13
+
+// spillQueue is a FIFO queue backed by temporary file. The file is truncated every time the queue becomes empty.
+type spillQueue struct {
+	mux         sync.Mutex
+	file        *os.File
+	readOffset  int64
+	writeOffset int64
+	count       int
+}
+
+func newSpillQueue(dir string) (*spillQueue, error) {
+	file, err := os.CreateTemp(dir, "fimpgo-*.spill")
+	if err != nil {
+		return nil, err
+	}
+	return &spillQueue{file: file}, nil
+}
+
+func (q *spillQueue) Len() int {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return q.count
+}
+
+func (q *spillQueue) Push(rec spillRecord) error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.file == nil {
+		return errors.New("spill queue is closed")
+	}
+	var meta []byte
+	if !rec.meta.isEmpty() {
+		var err error
+		if meta, err = json.Marshal(rec.meta); err != nil {
+			return err
+		}
+	}
+	buf := make([]byte, spillHeaderSize, spillHeaderSize+len(rec.topic)+len(rec.payload)+len(meta))
+	if rec.isRaw {
+		buf[0] = 1
+	}
+	binary.BigEndian.PutUint32(buf[1:5], uint32(len(rec.topic)))
+	binary.BigEndian.PutUint32(buf[5:9], uint32(len(rec.payload)))
+	binary.BigEndian.PutUint32(buf[9:13], uint32(len(meta)))
+	buf = append(buf, rec.topic...)
+	buf = append(buf, rec.payload...)
+	buf = append(buf, meta...)
+	if _, err := q.file.WriteAt(buf, q.writeOffset); err != nil {
+		return err
+	}
+	q.writeOffset += int64(len(buf))
+	q.count++
+	return nil
+}
+
+func (q *spillQueue) Peek() (spillRecord, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	rec, _, err := q.read()
+	return rec, err
+}
+
+func (q *spillQueue) Pop() (spillRecord, error) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	rec, size, err := q.read()
+	if err != nil {
+		return rec, err
+	}
+	q.advance(size)
+	return rec, nil
+}
+
+// Discard removes the oldest record from the queue.
+func (q *spillQueue) Discard() error {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	_, size, err := q.read()
+	if err != nil {
+		return err
+	}
+	q.advance(size)
+	return nil
+}
+
+func (q *spillQueue) read() (spillRecord, int64, error) {
+	if q.file == nil {
+		return spillRecord{}, 0, errors.New("spill queue is closed")
+	}
+	if q.count == 0 {
+		return spillRecord{}, 0, io.EOF
+	}
+	header := make([]byte, spillHeaderSize)
+	if _, err := q.file.ReadAt(header, q.readOffset); err != nil {
+		return spillRecord{}, 0, err
+	}
+	topicLen := int64(binary.BigEndian.Uint32(header[1:5]))
+	payloadLen := int64(binary.BigEndian.Uint32(header[5:9]))
+	metaLen := int64(binary.BigEndian.Uint32(header[9:13]))
+	body := make([]byte, topicLen+payloadLen+metaLen)
+	if _, err := q.file.ReadAt(body, q.readOffset+spillHeaderSize); err != nil {
+		return spillRecord{}, 0, err
+	}
+	rec := spillRecord{topic: string(body[:topicLen]), payload: body[topicLen : topicLen+payloadLen], isRaw: header[0] == 1}
+	size := spillHeaderSize + topicLen + payloadLen + metaLen
+	if metaLen > 0 {
+		if err := json.Unmarshal(body[topicLen+payloadLen:], &rec.meta); err != nil {
+			// the message is still delivered , but without properties and authentication
+			log.Warn("<MqttAd> Metadata of spilled message can't be decoded. Error :", err)
+		}
+	}
+	return rec, size, nil
+}
+
+func (q *spillQueue) advance(size int64) {
+	q.readOffset += size
+	q.count--
+	if q.count == 0 {
+		q.readOffset = 0
+		q.writeOffset = 0
+		if err := q.file.Truncate(0); err != nil {
+			log.Warn("<MqttAd> Spill file can't be truncated. Error :", err)
+		}
+	}
+}
+
+// Close closes and removes spill file. Spilled messages are lost.
+func (q *spillQueue) Close() {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	if q.file == nil {
+		return
+	}
+	name := q.file.Name()
+	q.file.Close()
+	os.Remove(name)
+	q.file = nil
+	q.count = 0
+}
@@ -0,0 +1,237 @@
+package fimpgo
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func overflowTestTopic(i int) string {
+	return fmt.Sprintf("pt:j1/mt:evt/rt:dev/rn:test/ad:1/sv:sensor_temp/ad:%d", i)
+}
+
+func overflowTestMessage(t *testing.T, i int) *Message {
+	addr, err := NewAddressFromString(overflowTestTopic(i))
+	require.NoError(t, err)
+	return &Message{Topic: overflowTestTopic(i), Addr: addr, Payload: NewIntMessage("evt.sensor.report", "sensor_temp", int64(i), nil, nil, nil)}
+}
+
+func TestSpillQueue(t *testing.T) {
+	queue, err := newSpillQueue(t.TempDir())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, queue.Push(spillRecord{topic: overflowTestTopic(i), payload: []byte{byte(i)}, isRaw: i == 2}))
+	}
+	assert.Equal(t, 3, queue.Len())
+
+	rec, err := queue.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, overflowTestTopic(0), rec.topic)
+	require.NoError(t, queue.Discard())
+
+	rec, err = queue.Pop()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1}, rec.payload)
+	rec, err = queue.Pop()
+	require.NoError(t, err)
+	assert.True(t, rec.isRaw)
+	assert.Equal(t, 0, queue.Len())
+
+	info, err := queue.file.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Size())
+}
+
+func TestSpillRecord_Metadata(t *testing.T) {
+	queue, err := newSpillQueue(t.TempDir())
+	require.NoError(t, err)
+	defer queue.Close()
+
+	decoded := overflowTestMessage(t, 0)
+	decoded.RawPayload = []byte(`{"signed":true}`)
+	decoded.Properties = &MessageProperties{UserProperties: map[string]string{"tenant": "t1"}, ResponseTopic: overflowTestTopic(1), CorrelationData: []byte("abc"), MessageExpiry: time.Minute}
+	decoded.Auth = &Authentication{UserId: "alex@gmail.com", Algorithm: "ES256"}
+	raw := &Message{Topic: "pt:x2/mt:evt/rt:dev/rn:test/ad:1", RawPayload: []byte{1, 2, 3}, Auth: &Authentication{DeviceId: "hub1"}}
+	plain := overflowTestMessage(t, 1)
+
+	for _, msg := range []*Message{decoded, raw, plain} {
+		require.NoError(t, queue.Push(newSpillRecord(msg)))
+	}
+	for _, expected := range []*Message{decoded, raw, plain} {
+		rec, err := queue.Pop()
+		require.NoError(t, err)
+		msg, err := rec.message()
+		require.NoError(t, err)
+		assert.Equal(t, expected.RawPayload, msg.RawPayload, expected.Topic)
+		assert.Equal(t, expected.Properties, msg.Properties, expected.Topic)
+		assert.Equal(t, expected.Auth, msg.Auth, expected.Topic)
+		if expected.Payload != nil {
+			assert.Equal(t, expected.Payload.Type, msg.Payload.Type)
+		}
+	}
+}
+
+func TestMqttTransport_MainSpillProperties(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{MainQueueSize: 1, MainQueueOverflowPolicy: OverflowSpillToDisk}, WithSpillDir(t.TempDir()))
+	defer mh.closeSpillQueues()
+
+	props := &MessageProperties{UserProperties: map[string]string{"tenant": "t1"}}
+	mh.onMessage(nil, &spilledMessage{topic: overflowTestTopic(0)})
+	mh.onMessage(nil, &spilledMessage{topic: overflowTestTopic(1), props: props})
+
+	msg, ok := mh.popMainSpill()
+	require.True(t, ok)
+	assert.Equal(t, overflowTestTopic(1), msg.Topic())
+	require.Implements(t, (*propertiesCarrier)(nil), msg)
+	assert.Equal(t, props, msg.(propertiesCarrier).properties())
+}
+
+func TestMqttTransport_MainQueueOverflow(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		expected []int
+		dropped  uint64
+	}{
+		{policy: OverflowDefault, expected: []int{0, 1}, dropped: 3},
+		{policy: OverflowDropNewest, expected: []int{0, 1}, dropped: 3},
+		{policy: OverflowDropOldest, expected: []int{3, 4}, dropped: 3},
+		{policy: OverflowSpillToDisk, expected: []int{0, 1, 2, 3, 4}, dropped: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			var drops []string
+			mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{MainQueueSize: 2, MainQueueOverflowPolicy: tt.policy},
+				WithSpillDir(t.TempDir()), WithDropHandler(func(queueId, topic string, policy OverflowPolicy) {
+					drops = append(drops, queueId)
+				}))
+			defer mh.closeSpillQueues()
+
+			for i := 0; i < 5; i++ {
+				mh.onMessage(nil, &spilledMessage{topic: overflowTestTopic(i)})
+			}
+
+			var received []int
+			for len(mh.mainQueue) > 0 {
+				msg := <-mh.mainQueue
+				received = append(received, len(received))
+				assert.Equal(t, overflowTestTopic(tt.expected[len(received)-1]), msg.Topic())
+			}
+			for {
+				msg, ok := mh.popMainSpill()
+				if !ok {
+					break
+				}
+				received = append(received, len(received))
+				assert.Equal(t, overflowTestTopic(tt.expected[len(received)-1]), msg.Topic())
+			}
+			assert.Len(t, received, len(tt.expected))
+			assert.Equal(t, tt.dropped, mh.DroppedMessages(MainQueueID))
+			assert.Len(t, drops, int(tt.dropped))
+		})
+	}
+}
+
+func TestMqttTransport_ChannelOverflow(t *testing.T) {
+	tests := []struct {
+		policy   OverflowPolicy
+		expected []int
+		dropped  uint64
+	}{
+		{policy: OverflowDefault, expected: []int{0, 1}, dropped: 3},
+		{policy: OverflowDropNewest, expected: []int{0, 1}, dropped: 3},
+		{policy: OverflowDropOldest, expected: []int{3, 4}, dropped: 3},
+		{policy: OverflowSpillToDisk, expected: []int{0, 1, 2, 3, 4}, dropped: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ReceiveChTimeout: -1, SpillDir: t.TempDir()})
+			defer mh.closeSpillQueues()
+			// worker without backlog and without delivery goroutine , ch stands for delivery queue of the channel
+			ch := make(MessageCh, 2)
+			w := &deliveryWorker{queue: ch}
+			for i := 0; i < 5; i++ {
+				mh.deliverToChannel("test", w, tt.policy, overflowTestMessage(t, i))
+			}
+
+			var received []int64
+			for {
+				select {
+				case msg := <-ch:
+					val, err := msg.Payload.GetIntValue()
+					require.NoError(t, err)
+					received = append(received, val)
+					continue
+				default:
+				}
+				if queue, _ := mh.channelSpill("test", false); queue != nil {
+					mh.flushChannelSpill(queue, ch)
+				}
+				if len(ch) == 0 {
+					break
+				}
+			}
+			var expected []int64
+			for _, i := range tt.expected {
+				expected = append(expected, int64(i))
+			}
+			assert.Equal(t, expected, received)
+			assert.Equal(t, tt.dropped, mh.DroppedMessages("test"))
+		})
+	}
+}
+
+func TestMqttTransport_ChannelSpillFlushedUnderTraffic(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ChannelQueueSize: 1, MainQueueSize: 200000, SpillDir: t.TempDir()})
+	defer mh.closeSpillQueues()
+	defer mh.stopWorkers()
+
+	ch := make(MessageCh)
+	mh.RegisterChannelWithFilter("slow", ch, FimpFilter{Topic: overflowTestTopic(0), Service: "*", Interface: "*"})
+	mh.SetChannelOverflowPolicy("slow", OverflowSpillToDisk)
+	payload, err := NewIntMessage("evt.sensor.report", "sensor_temp", 1, nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		mh.handleIncomingMessage(&spilledMessage{topic: overflowTestTopic(0), payload: payload})
+	}
+	queue, _ := mh.channelSpill("slow", false)
+	require.NotNil(t, queue)
+	require.NotZero(t, queue.Len())
+
+	// steady traffic for other topics keeps main queue full
+	for len(mh.mainQueue) < cap(mh.mainQueue) {
+		mh.mainQueue <- &spilledMessage{topic: overflowTestTopic(1), payload: payload}
+	}
+	mh.done = make(chan struct{})
+	mh.wg.Add(1)
+	go mh.handleIncomingMessages()
+	defer func() {
+		close(mh.done)
+		mh.wg.Wait()
+	}()
+
+	stopTraffic := make(chan struct{})
+	defer close(stopTraffic)
+	go func() {
+		for {
+			select {
+			case mh.mainQueue <- &spilledMessage{topic: overflowTestTopic(1), payload: payload}:
+			case <-stopTraffic:
+				return
+			}
+		}
+	}()
+
+	timeout := time.After(3 * spillFlushInterval)
+	for received := 0; received < 5; received++ {
+		select {
+		case <-ch:
+		case <-timeout:
+			t.Fatalf("spilled messages were not flushed , received %d", received)
+		}
+	}
+}
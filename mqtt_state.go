@@ -0,0 +1,252 @@
+package fimpgo
+
+import (
+	"fmt"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// ConnectionState is state of transport connection with MQTT broker.
+type ConnectionState int
+
+const (
+	// ConnectionStateDisconnected is initial state and state after Stop or failed Start.
+	ConnectionStateDisconnected ConnectionState = iota
+	// ConnectionStateConnecting is set when Start begins connecting to the broker.
+	ConnectionStateConnecting
+	// ConnectionStateConnected is set after every successful (re)connect.
+	ConnectionStateConnected
+	// ConnectionStateLost is set when established connection is lost.
+	ConnectionStateLost
+	// ConnectionStateReconnecting is set before client tries to restore lost connection.
+	ConnectionStateReconnecting
+	// ConnectionStateResubscribed is set after subscriptions are restored on (re)connect , transport is ready to receive messages.
+	ConnectionStateResubscribed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateConnecting:
+		return "connecting"
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateLost:
+		return "lost"
+	case ConnectionStateReconnecting:
+		return "reconnecting"
+	case ConnectionStateResubscribed:
+		return "resubscribed"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int(s))
+	}
+}
+
+// ConnectionEvent is emitted on every connection state change.
+type ConnectionEvent struct {
+	State ConnectionState
+	Err   error // cause of lost connection or failed connection attempt , nil otherwise
+	Time  time.Time
+}
+
+// ConnectionStateHandler is invoked synchronously from MQTT client callbacks and must not block.
+type ConnectionStateHandler func(event ConnectionEvent)
+
+// PublishFailureHandler is invoked for every failed publish , including asynchronous publish failed after Publish returned.
+// It's invoked from publishing goroutine or from background goroutine watching the publish and must not block.
+type PublishFailureHandler func(topic string, err error)
+
+// Health is a snapshot of transport connection and queue state.
+type Health struct {
+	State              ConnectionState
+	Connected          bool
+	LastError          error // last connection , subscription or publish error
+	LastErrorTime      time.Time
+	ReconnectCount     uint64
+	PublishFailures    uint64
+	Subscriptions      int            // number of topics subscribed with the broker
+	MainQueueDepth     int            // number of messages waiting in main queue
+	MainQueueSize      int            // capacity of main queue
+	SpilledMessages    int            // number of messages spilled to disk by OverflowSpillToDisk policy
+	ChannelQueueDepths map[string]int // channel or handler id -> number of messages waiting in its delivery queue
+}
+
+// IsConnected returns true if connection with the broker is open.
+func (mh *MqttTransport) IsConnected() bool {
+	return mh.client != nil && mh.client.IsConnectionOpen()
+}
+
+// ConnectionState returns current connection state.
+func (mh *MqttTransport) ConnectionState() ConnectionState {
+	mh.stateMux.Lock()
+	defer mh.stateMux.Unlock()
+	return mh.connState
+}
+
+// Health returns snapshot of connection state , errors and queue depths.
+func (mh *MqttTransport) Health() Health {
+	mh.stateMux.Lock()
+	health := Health{
+		State:           mh.connState,
+		LastError:       mh.lastError,
+		LastErrorTime:   mh.lastErrorTime,
+		ReconnectCount:  mh.reconnectCount,
+		PublishFailures: mh.publishFailures,
+	}
+	mh.stateMux.Unlock()
+
+	health.Connected = mh.IsConnected()
+
+	mh.subMutex.Lock()
+	health.Subscriptions = len(mh.subs)
+	mh.subMutex.Unlock()
+
+	health.MainQueueDepth = len(mh.mainQueue)
+	health.MainQueueSize = cap(mh.mainQueue)
+
+	mh.mainSpillMux.Lock()
+	if mh.mainSpill != nil {
+		health.SpilledMessages = mh.mainSpill.Len()
+	}
+	mh.mainSpillMux.Unlock()
+
+	mh.channelRegMux.Lock()
+	health.ChannelQueueDepths = make(map[string]int, len(mh.subWorkers))
+	for id, w := range mh.subWorkers {
+		health.ChannelQueueDepths[id] = w.depth()
+	}
+	for _, queue := range mh.channelSpills {
+		health.SpilledMessages += queue.Len()
+	}
+	mh.channelRegMux.Unlock()
+	return health
+}
+
+// SetConnectionStateHandler sets callback invoked on every connection state change.
+func (mh *MqttTransport) SetConnectionStateHandler(handler ConnectionStateHandler) {
+	mh.stateMux.Lock()
+	mh.stateHandler = handler
+	mh.stateMux.Unlock()
+}
+
+// SetPublishFailureHandler sets callback invoked on every failed publish.
+func (mh *MqttTransport) SetPublishFailureHandler(handler PublishFailureHandler) {
+	mh.stateMux.Lock()
+	mh.publishFailureHandler = handler
+	mh.stateMux.Unlock()
+}
+
+// SubscribeConnectionEvents returns channel receiving connection events. Events are dropped if the channel is full.
+func (mh *MqttTransport) SubscribeConnectionEvents(subId string, bufSize int) <-chan ConnectionEvent {
+	mh.stateMux.Lock()
+	defer mh.stateMux.Unlock()
+	if mh.stateListeners == nil {
+		mh.stateListeners = make(map[string]chan ConnectionEvent)
+	}
+	if ch, ok := mh.stateListeners[subId]; ok {
+		close(ch)
+	}
+	ch := make(chan ConnectionEvent, bufSize)
+	mh.stateListeners[subId] = ch
+	return ch
+}
+
+// UnsubscribeConnectionEvents removes and closes connection events channel.
+func (mh *MqttTransport) UnsubscribeConnectionEvents(subId string) {
+	mh.stateMux.Lock()
+	defer mh.stateMux.Unlock()
+	if ch, ok := mh.stateListeners[subId]; ok {
+		close(ch)
+		delete(mh.stateListeners, subId)
+	}
+}
+
+// setConnectionState updates connection state and notifies handler and listeners.
+func (mh *MqttTransport) setConnectionState(state ConnectionState, err error) {
+	event := ConnectionEvent{State: state, Err: err, Time: time.Now()}
+
+	mh.stateMux.Lock()
+	if state == ConnectionStateConnected {
+		if mh.wasConnected {
+			mh.reconnectCount++
+		}
+		mh.wasConnected = true
+	}
+	mh.connState = state
+	if err != nil {
+		mh.lastError = err
+		mh.lastErrorTime = event.Time
+	}
+	for id, ch := range mh.stateListeners {
+		select {
+		case ch <- event:
+		default:
+			log.Debugf("<MqttAd> Connection state listener %s is busy , event dropped", id)
+		}
+	}
+	handler := mh.stateHandler
+	mh.stateMux.Unlock()
+
+	log.Debug("<MqttAd> New connection state = ", state)
+	if handler != nil {
+		handler(event)
+	}
+}
+
+// recordError stores error reported by Health without changing connection state.
+func (mh *MqttTransport) recordError(err error) {
+	mh.stateMux.Lock()
+	mh.lastError = err
+	mh.lastErrorTime = time.Now()
+	mh.stateMux.Unlock()
+}
+
+// publishFailed counts failed publish , notifies publish failure handler and returns the cause.
+// err is nil if publish wasn't acknowledged in time , in that case timeout error is returned (see IsTimeout).
+func (mh *MqttTransport) publishFailed(topic string, err error) error {
+	cause := err
+	if cause == nil {
+		cause = fmt.Errorf("%w: publish wasn't acknowledged within %s", errTimeout, mh.syncPublishTimeout)
+	}
+	log.Debugf("<MqttAd> Publishing to topic %s failed. Error : %v", topic, cause)
+	mh.stateMux.Lock()
+	mh.publishFailures++
+	mh.lastError = fmt.Errorf("publishing to %s: %w", topic, cause)
+	mh.lastErrorTime = time.Now()
+	handler := mh.publishFailureHandler
+	mh.stateMux.Unlock()
+
+	if handler != nil {
+		handler(topic, cause)
+	}
+	return cause
+}
+
+// watchPublish returns error of publish , which has already failed. Otherwise the token is watched in background ,
+// so failure reported by the client after acknowledgement timeout or on lost connection is still counted and reported.
+func (mh *MqttTransport) watchPublish(topic string, token MQTT.Token) error {
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return mh.publishFailed(topic, err)
+		}
+		return nil
+	default:
+	}
+	go func() {
+		// pending tokens are completed by the client on disconnect
+		<-token.Done()
+		if err := token.Error(); err != nil {
+			mh.publishFailed(topic, err)
+		}
+	}()
+	return nil
+}
+
+func (mh *MqttTransport) onReconnecting(_ MQTT.Client, _ *MQTT.ClientOptions) {
+	log.Info("<MqttAd> Reconnecting to MQTT broker ")
+	mh.setConnectionState(ConnectionStateReconnecting, nil)
+}
@@ -0,0 +1,224 @@
+package fimpgo
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMqttTransport_ConnectionEvents(t *testing.T) {
+	client := &recordingClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	events := mh.SubscribeConnectionEvents("test", 10)
+	require.NoError(t, mh.Subscribe("pt:j1/mt:evt/#"))
+
+	lostErr := errors.New("connection reset")
+	mh.onConnect(client)
+	mh.onConnectionLost(client, lostErr)
+	mh.onReconnecting(client, nil)
+	mh.onConnect(client)
+
+	var states []ConnectionState
+	for len(events) > 0 {
+		evt := <-events
+		states = append(states, evt.State)
+		if evt.State == ConnectionStateLost {
+			assert.Equal(t, lostErr, evt.Err)
+		}
+	}
+	assert.Equal(t, []ConnectionState{
+		ConnectionStateConnected,
+		ConnectionStateResubscribed,
+		ConnectionStateLost,
+		ConnectionStateReconnecting,
+		ConnectionStateConnected,
+		ConnectionStateResubscribed,
+	}, states)
+	// subscriptions are restored after every connect
+	assert.Equal(t, []string{"pt:j1/mt:evt/#", "pt:j1/mt:evt/#", "pt:j1/mt:evt/#"}, client.subscribed)
+
+	mh.UnsubscribeConnectionEvents("test")
+	_, ok := <-events
+	assert.False(t, ok, "channel must be closed")
+
+	health := mh.Health()
+	assert.Equal(t, ConnectionStateResubscribed, health.State)
+	assert.True(t, health.Connected)
+	assert.Equal(t, lostErr, health.LastError)
+	assert.Equal(t, uint64(1), health.ReconnectCount)
+	assert.Equal(t, 1, health.Subscriptions)
+	assert.Equal(t, defaultMainQueueSize, health.MainQueueSize)
+}
+
+// flakyConnectClient fails first connect attempts , successful connect invokes onConnect like paho client does.
+type flakyConnectClient struct {
+	recordingClient
+	failures  int
+	onConnect func()
+}
+
+func (c *flakyConnectClient) Connect() MQTT.Token {
+	token := newMqttToken()
+	if c.failures > 0 {
+		c.failures--
+		token.complete(errors.New("connection refused"))
+		return token
+	}
+	c.onConnect()
+	token.complete(nil)
+	return token
+}
+
+func (c *flakyConnectClient) Disconnect(uint) {}
+
+func TestMqttTransport_StartAfterFailedAttempt(t *testing.T) {
+	client := &flakyConnectClient{failures: 1}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	client.onConnect = func() { mh.onConnect(client) }
+	events := mh.SubscribeConnectionEvents("test", 10)
+
+	require.NoError(t, mh.Start())
+	defer mh.Stop()
+	assert.Equal(t, ConnectionStateResubscribed, mh.ConnectionState())
+
+	var states []ConnectionState
+	for len(events) > 0 {
+		states = append(states, (<-events).State)
+	}
+	assert.Equal(t, []ConnectionState{ConnectionStateConnecting, ConnectionStateConnected, ConnectionStateResubscribed}, states)
+}
+
+func TestMqttTransport_ConnectionStateHandler(t *testing.T) {
+	var events []ConnectionEvent
+	var lostHandlerCalled bool
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{},
+		WithConnectionStateHandler(func(event ConnectionEvent) {
+			events = append(events, event)
+		}),
+		WithConnectionLostHandler(func(_ MQTT.Client, _ error) {
+			lostHandlerCalled = true
+		}),
+	)
+	defer mh.stopWorkers()
+
+	mh.onConnectionLost(nil, errors.New("timeout"))
+	require.Len(t, events, 1)
+	assert.Equal(t, ConnectionStateLost, events[0].State)
+	assert.EqualError(t, events[0].Err, "timeout")
+	assert.True(t, lostHandlerCalled, "configured connection lost handler must be invoked")
+	assert.Equal(t, ConnectionStateLost, mh.ConnectionState())
+}
+
+func TestMqttTransport_HealthQueueDepths(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{MainQueueSize: 5, ChannelQueueSize: 5})
+	defer mh.stopWorkers()
+
+	// channel is never read , so one message stays in the channel and the rest in delivery queue
+	ch := make(MessageCh, 1)
+	mh.RegisterChannel("test", ch)
+	payload, err := NewNullMessage("evt.sensor.report", "sensor_temp", nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		mh.handleIncomingMessage(&spilledMessage{topic: "pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1", payload: payload})
+	}
+	mh.onMessage(nil, &spilledMessage{topic: "pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1", payload: payload})
+
+	assert.Eventually(t, func() bool {
+		return mh.Health().ChannelQueueDepths["test"] == 1
+	}, time.Second, 10*time.Millisecond)
+	health := mh.Health()
+	assert.Equal(t, 1, health.MainQueueDepth)
+	assert.Equal(t, 5, health.MainQueueSize)
+	assert.Equal(t, ConnectionStateDisconnected, health.State)
+}
+
+func TestMqttTransport_PublishFailure(t *testing.T) {
+	mh := NewMqttTransportFromConnection(&failingPublishClient{}, 1, 1)
+	msg := NewNullMessage("cmd.binary.set", "out_bin_switch", nil, nil, nil)
+
+	assert.Error(t, mh.PublishRawSync("pt:j1/mt:cmd/rt:dev/rn:zw/ad:1/sv:out_bin_switch/ad:1", []byte("{}")))
+	assert.Error(t, mh.PublishToTopic("pt:j1/mt:cmd/rt:dev/rn:zw/ad:1/sv:out_bin_switch/ad:1", msg))
+
+	health := mh.Health()
+	assert.Equal(t, uint64(2), health.PublishFailures)
+	assert.ErrorContains(t, health.LastError, "not connected")
+}
+
+func TestMqttTransport_PublishTimeout(t *testing.T) {
+	client := &pendingPublishClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	mh.syncPublishTimeout = 10 * time.Millisecond
+	failures := make(chan error, 10)
+	mh.SetPublishFailureHandler(func(topic string, err error) {
+		assert.Equal(t, "pt:j1/mt:evt/rt:app/rn:test/ad:1", topic)
+		failures <- err
+	})
+
+	err := mh.PublishRawSync("pt:j1/mt:evt/rt:app/rn:test/ad:1", []byte("{}"))
+	assert.True(t, IsTimeout(err), err)
+	assert.True(t, IsTimeout(<-failures))
+	assert.Equal(t, uint64(1), mh.Health().PublishFailures)
+}
+
+func TestMqttTransport_AsyncPublishFailure(t *testing.T) {
+	client := &pendingPublishClient{}
+	failures := make(chan error, 10)
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{}, WithPublishFailureHandler(func(_ string, err error) {
+		failures <- err
+	}))
+	defer mh.stopWorkers()
+	mh.client = client
+
+	require.NoError(t, mh.PublishToTopic("pt:j1/mt:evt/rt:app/rn:test/ad:1", NewNullMessage("evt.test.report", "test", nil, nil, nil)))
+	require.NoError(t, mh.Publish(&Address{MsgType: MsgTypeEvt, ResourceType: ResourceTypeApp, ResourceName: "test", ResourceAddress: "1"}, NewNullMessage("evt.test.report", "test", nil, nil, nil)))
+	mh.PublishRaw("pt:j1/mt:evt/rt:app/rn:test/ad:1", []byte("{}"))
+	assert.Zero(t, mh.Health().PublishFailures, "publish is still pending")
+
+	client.completeAll(errors.New("connection lost before Publish completed"))
+	for i := 0; i < 3; i++ {
+		select {
+		case err := <-failures:
+			assert.ErrorContains(t, err, "connection lost")
+		case <-time.After(time.Second):
+			t.Fatal("failure of asynchronous publish was not reported")
+		}
+	}
+	assert.Equal(t, uint64(3), mh.Health().PublishFailures)
+}
+
+// pendingPublishClient returns publish tokens , which are completed only by completeAll.
+type pendingPublishClient struct {
+	recordingClient
+	tokens []*mqttToken
+}
+
+func (c *pendingPublishClient) Publish(_ string, _ byte, _ bool, _ interface{}) MQTT.Token {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	token := newMqttToken()
+	c.tokens = append(c.tokens, token)
+	return token
+}
+
+func (c *pendingPublishClient) completeAll(err error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for _, token := range c.tokens {
+		token.complete(err)
+	}
+	c.tokens = nil
+}
+
+type failingPublishClient struct {
+	recordingClient
+}
+
+func (c *failingPublishClient) Publish(_ string, _ byte, _ bool, _ interface{}) MQTT.Token {
+	token := newMqttToken()
+	token.complete(errors.New("not connected"))
+	return token
+}
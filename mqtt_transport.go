@@ -28,7 +28,7 @@ type MqttConnectionConfigs struct {
 	ClientID            string
 	Username            string
 	Password            string
-	CleanSession        bool
+	CleanSession        bool // must be true with MqttProtocolV5 , persistent sessions are supported only by v3.1.1
 	SubQos              byte
 	PubQos              byte
 	GlobalTopicPrefix   string // Should be set for communicating one single hub via cloud
@@ -39,21 +39,50 @@ type MqttConnectionConfigs struct {
 	ReceiveChTimeout    int
 	IsAws               bool // Should be set to true if cloud broker is AwS IoT platform .
 	MainQueueSize       int
+	ProtocolVersion     uint // MQTT protocol version , MqttProtocolV311 (default) or MqttProtocolV5
+	// SharedSubscriptionGroup , if set , command topics (mt:cmd) are subscribed as shared subscriptions $share/<group>/<topic> ,
+	// so commands are load-balanced between all replicas of the service. Requires broker support , normally MQTT v5.
+	SharedSubscriptionGroup string
+	MainQueueOverflowPolicy OverflowPolicy // Default is OverflowDropNewest
+	SpillDir                string         // Directory for spill files of OverflowSpillToDisk policy , default is system temp dir.
+	ChannelQueueSize        int            // Size of delivery queue of every channel and message handler , default is 100.
 
 	connectionLostHandler MQTT.ConnectionLostHandler
+	dropHandler           DropHandler
+	schemaValidator       *SchemaValidator
+	signatureVerifier     SignatureVerifier
+	signaturePolicy       *SignaturePolicy
+	stateHandler          ConnectionStateHandler
+	publishFailureHandler PublishFailureHandler
 }
 
 type Message struct {
 	Topic      string
 	Addr       *Address
 	Payload    *FimpMessage
-	RawPayload []byte
+	RawPayload []byte             // payload of unknown payload type , Payload is nil in that case
+	Properties *MessageProperties // MQTT v5 properties , nil if message was received over MQTT 3.1.1
+	Auth       *Authentication    // Signer of the message , nil if message wasn't unwrapped from verified signed message
 }
 
+// IsAuthenticated returns true if message was unwrapped from signed message and its signature was verified.
+func (m *Message) IsAuthenticated() bool {
+	return m.Auth != nil
+}
+
+// FimpFilter selects inbound messages delivered to registered channel.
 type FimpFilter struct {
 	Topic     string
 	Service   string
-	Interface string
+	Interface string // exact interface , * or glob pattern , for instance evt.sensor.*
+}
+
+// Matches returns true if message is accepted by the filter. msg is nil if payload can't be parsed , in that case only topic is checked.
+func (f FimpFilter) Matches(topic string, addr *Address, msg *FimpMessage) bool {
+	if !utils.RouteIncludesTopic(f.Topic, topic) {
+		return false
+	}
+	return msg == nil || ((f.Service == "*" || msg.Service == f.Service) && matchInterface(f.Interface, msg.Type))
 }
 
 type FilterFunc func(topic string, addr *Address, iotMsg *FimpMessage) bool
@@ -64,9 +93,12 @@ type MqttTransport struct {
 	subQos         byte
 	pubQos         byte
 	subs           map[string]byte
+	subRefs        map[string]map[*Subscription]struct{} // open subscription handles per topic
+	legacySubs     map[string]struct{}                   // topics subscribed by Subscribe , which weren't unsubscribed yet
 	subChannels    map[string]MessageCh
 	subFilters     map[string]FimpFilter
 	subFilterFuncs map[string]FilterFunc
+	subIndex       *subscriptionIndex
 
 	done      chan struct{}
 	wg        sync.WaitGroup
@@ -83,7 +115,41 @@ type MqttTransport struct {
 	syncPublishTimeout   time.Duration
 	channelRegMux        sync.Mutex
 	subMutex             sync.Mutex
-	compressor           *MsgCompressor
+	protocolVersion      uint
+	sharedSubGroup       string
+
+	overflowMux     sync.RWMutex
+	mainQueuePolicy OverflowPolicy
+	dropHandler     DropHandler
+	dropCounters    map[string]*uint64
+	spillDir        string
+	mainSpillMux    sync.Mutex
+	mainSpill       *spillQueue
+	spillSignal     chan struct{}
+	channelPolicies map[string]OverflowPolicy
+	channelSpills   map[string]*spillQueue
+
+	subWorkers       map[string]*deliveryWorker
+	channelQueueSize int
+
+	schemaMux       sync.RWMutex
+	schemaValidator *SchemaValidator
+
+	authMux           sync.RWMutex
+	signatureVerifier SignatureVerifier
+	signaturePolicy   *SignaturePolicy
+
+	connectionLostHandler MQTT.ConnectionLostHandler
+	stateMux              sync.Mutex
+	connState             ConnectionState
+	wasConnected          bool
+	reconnectCount        uint64
+	publishFailures       uint64
+	lastError             error
+	lastErrorTime         time.Time
+	stateHandler          ConnectionStateHandler
+	stateListeners        map[string]chan ConnectionEvent
+	publishFailureHandler PublishFailureHandler
 }
 
 func (mh *MqttTransport) SetReceiveChTimeout(receiveChTimeout int) {
@@ -102,6 +168,9 @@ func (mh *MqttTransport) SetOptions(options *MQTT.ClientOptions) {
 	mh.client = MQTT.NewClient(options)
 }
 
+// MessageHandler receives every inbound message. If the message was unwrapped from verified signed message , iotMsg is the inner message
+// and rawPayload is the inner message encoded with payload type of the topic , the signer isn't available to the handler ,
+// channels should be used if Authentication is needed. iotMsg is nil for unknown payload types.
 type MessageHandler func(topic string, addr *Address, iotMsg *FimpMessage, rawPayload []byte)
 
 // NewMqttTransport constructor. serverUri="tcp://localhost:1883"
@@ -116,20 +185,24 @@ func NewMqttTransport(serverURI, clientID, username, password string, cleanSessi
 	mh.mqttOptions.SetAutoReconnect(true)
 	mh.mqttOptions.SetConnectionLostHandler(mh.onConnectionLost)
 	mh.mqttOptions.SetOnConnectHandler(mh.onConnect)
+	mh.mqttOptions.SetReconnectingHandler(mh.onReconnecting)
 	mh.mqttOptions.SetWriteTimeout(time.Second * 30)
 	//create and start a client using the above ClientOptions
 	mh.client = MQTT.NewClient(mh.mqttOptions)
 	mh.pubQos = pubQos
 	mh.subQos = subQos
 	mh.subs = make(map[string]byte)
+	mh.subRefs = make(map[string]map[*Subscription]struct{})
+	mh.legacySubs = make(map[string]struct{})
 	mh.subChannels = make(map[string]MessageCh)
 	mh.subFilters = make(map[string]FimpFilter)
 	mh.subFilterFuncs = make(map[string]FilterFunc)
+	mh.subIndex = newSubscriptionIndex()
 	mh.mainQueue = make(chan MQTT.Message, defaultMainQueueSize)
+	mh.spillSignal = make(chan struct{}, 1)
 	mh.startFailRetryCount = 10
 	mh.receiveChTimeout = 10
 	mh.syncPublishTimeout = time.Second * 5
-	mh.compressor = NewMsgCompressor("", "")
 	return &mh
 }
 
@@ -139,14 +212,17 @@ func NewMqttTransportFromConnection(client MQTT.Client, subQos byte, pubQos byte
 	mh.pubQos = pubQos
 	mh.subQos = subQos
 	mh.subs = make(map[string]byte)
+	mh.subRefs = make(map[string]map[*Subscription]struct{})
+	mh.legacySubs = make(map[string]struct{})
 	mh.subChannels = make(map[string]MessageCh)
 	mh.subFilters = make(map[string]FimpFilter)
 	mh.subFilterFuncs = make(map[string]FilterFunc)
+	mh.subIndex = newSubscriptionIndex()
 	mh.mainQueue = make(chan MQTT.Message, defaultMainQueueSize)
+	mh.spillSignal = make(chan struct{}, 1)
 	mh.startFailRetryCount = 10
 	mh.receiveChTimeout = 10
 	mh.syncPublishTimeout = time.Second * 5
-	mh.compressor = NewMsgCompressor("", "")
 	return &mh
 }
 
@@ -167,23 +243,34 @@ func NewMqttTransportFromConfigs(configs MqttConnectionConfigs, options ...Optio
 	mh.mqttOptions.SetDefaultPublishHandler(mh.onMessage)
 	mh.mqttOptions.SetCleanSession(configs.CleanSession)
 	mh.mqttOptions.SetAutoReconnect(true)
-	mh.mqttOptions.SetConnectionLostHandler(configs.connectionLostHandler)
+	mh.mqttOptions.SetConnectionLostHandler(mh.onConnectionLost)
 	mh.mqttOptions.SetOnConnectHandler(mh.onConnect)
+	mh.mqttOptions.SetReconnectingHandler(mh.onReconnecting)
+	mh.connectionLostHandler = configs.connectionLostHandler
+	mh.stateHandler = configs.stateHandler
+	mh.publishFailureHandler = configs.publishFailureHandler
+	mh.protocolVersion = configs.ProtocolVersion
+	mh.sharedSubGroup = configs.SharedSubscriptionGroup
+	if mh.protocolVersion != 0 && mh.protocolVersion < MqttProtocolV5 {
+		mh.mqttOptions.SetProtocolVersion(mh.protocolVersion)
+	}
 
 	//create and start a client using the above ClientOptions
-	mh.client = MQTT.NewClient(mh.mqttOptions)
+	mh.client = mh.newClient()
 	mh.pubQos = configs.PubQos
 	mh.subQos = configs.SubQos
 	mh.subs = make(map[string]byte)
+	mh.subRefs = make(map[string]map[*Subscription]struct{})
+	mh.legacySubs = make(map[string]struct{})
 	mh.subChannels = make(map[string]MessageCh)
 	mh.subFilters = make(map[string]FimpFilter)
 	mh.subFilterFuncs = make(map[string]FilterFunc)
+	mh.subIndex = newSubscriptionIndex()
 	mh.startFailRetryCount = 10
 	mh.receiveChTimeout = 10
 	mh.syncPublishTimeout = time.Second * 5
 	mh.certDir = configs.CertDir
 	mh.globalTopicPrefix = configs.GlobalTopicPrefix
-	mh.compressor = NewMsgCompressor("", "")
 	if configs.StartFailRetryCount == 0 {
 		mh.startFailRetryCount = 10
 	} else {
@@ -201,6 +288,14 @@ func NewMqttTransportFromConfigs(configs MqttConnectionConfigs, options ...Optio
 	}
 
 	mh.mainQueue = make(chan MQTT.Message, mainQueueSize)
+	mh.spillSignal = make(chan struct{}, 1)
+	mh.mainQueuePolicy = configs.MainQueueOverflowPolicy
+	mh.spillDir = configs.SpillDir
+	mh.dropHandler = configs.dropHandler
+	mh.channelQueueSize = configs.ChannelQueueSize
+	mh.schemaValidator = configs.schemaValidator
+	mh.signatureVerifier = configs.signatureVerifier
+	mh.signaturePolicy = configs.signaturePolicy
 
 	if configs.PrivateKeyFileName != "" && configs.CertFileName != "" {
 		err := mh.ConfigureTls(configs.PrivateKeyFileName, configs.CertFileName, configs.CertDir, configs.IsAws)
@@ -250,16 +345,29 @@ func (mh *MqttTransport) SetStartAutoRetryCount(count int) {
 	mh.startFailRetryCount = count
 }
 
-// SetMessageHandler message handler setter
+// SetMessageHandler message handler setter. The handler is invoked from its own delivery worker ,
+// overflow policy and drop counter of the handler queue use MessageHandlerID.
 func (mh *MqttTransport) SetMessageHandler(msgHandler MessageHandler) {
+	mh.channelRegMux.Lock()
+	defer mh.channelRegMux.Unlock()
 	mh.msgHandler = msgHandler
+	if msgHandler == nil {
+		mh.stopWorker(MessageHandlerID)
+		return
+	}
+	mh.startWorker(MessageHandlerID, handlerDelivery(msgHandler))
 }
 
 // RegisterChannel should be used if new message has to be sent to channel instead of callback.
 // multiple channels can be registered , in that case a message bill be multicasted to all channels.
+// Every channel gets its own delivery queue , so slow channel doesn't delay other channels.
 func (mh *MqttTransport) RegisterChannel(channelId string, messageCh MessageCh) {
 	mh.channelRegMux.Lock()
 	mh.subChannels[channelId] = messageCh
+	delete(mh.subFilters, channelId)
+	delete(mh.subFilterFuncs, channelId)
+	mh.subIndex.set(channelId, nil)
+	mh.startWorker(channelId, channelDelivery(messageCh))
 	mh.channelRegMux.Unlock()
 }
 
@@ -269,24 +377,52 @@ func (mh *MqttTransport) UnregisterChannel(channelId string) {
 	delete(mh.subChannels, channelId)
 	delete(mh.subFilters, channelId)
 	delete(mh.subFilterFuncs, channelId)
+	mh.subIndex.remove(channelId)
+	delete(mh.channelPolicies, channelId)
+	mh.stopWorker(channelId)
+	if queue, ok := mh.channelSpills[channelId]; ok {
+		queue.Close()
+		delete(mh.channelSpills, channelId)
+	}
 	mh.channelRegMux.Unlock()
 }
 
 // RegisterChannelWithFilter should be used if new message has to be sent to channel instead of callback.
 // multiple channels can be registered , in that case a message bill be multicasted to all channels.
 func (mh *MqttTransport) RegisterChannelWithFilter(channelId string, messageCh MessageCh, filter FimpFilter) {
+	mh.registerWithFilter(channelId, messageCh, channelDelivery(messageCh), filter)
+}
+
+// registerWithFilter registers delivery worker of the channel , messageCh is nil if messages are delivered to handler.
+func (mh *MqttTransport) registerWithFilter(channelId string, messageCh MessageCh, deliver func(msg *Message, stop <-chan struct{}), filter FimpFilter) {
 	mh.channelRegMux.Lock()
-	mh.subChannels[channelId] = messageCh
+	if messageCh != nil {
+		mh.subChannels[channelId] = messageCh
+	} else {
+		delete(mh.subChannels, channelId)
+	}
 	mh.subFilters[channelId] = filter
+	delete(mh.subFilterFuncs, channelId)
+	mh.subIndex.set(channelId, &filter)
+	mh.startWorker(channelId, deliver)
 	mh.channelRegMux.Unlock()
 }
 
+// RegisterChannelWithPattern registers channel which receives inbound messages with address matching the pattern.
+// Channel registered with invalid pattern doesn't receive any messages , see AddressPattern.Err.
+func (mh *MqttTransport) RegisterChannelWithPattern(channelId string, messageCh MessageCh, pattern *AddressPattern) {
+	mh.RegisterChannelWithFilter(channelId, messageCh, FimpFilter{Topic: pattern.Topic(), Service: "*", Interface: "*"})
+}
+
 // RegisterChannelWithFilterFunc should be used if new message has to be sent to channel instead of callback.
 // multiple channels can be registered , in that case a message bill be multicasted to all channels.
 func (mh *MqttTransport) RegisterChannelWithFilterFunc(channelId string, messageCh MessageCh, filterFunc FilterFunc) {
 	mh.channelRegMux.Lock()
 	mh.subChannels[channelId] = messageCh
 	mh.subFilterFuncs[channelId] = filterFunc
+	delete(mh.subFilters, channelId)
+	mh.subIndex.remove(channelId)
+	mh.startWorker(channelId, channelDelivery(messageCh))
 	mh.channelRegMux.Unlock()
 }
 
@@ -294,18 +430,39 @@ func (mh *MqttTransport) Client() MQTT.Client {
 	return mh.client
 }
 
+// newClient creates MQTT client for configured protocol version.
+func (mh *MqttTransport) newClient() MQTT.Client {
+	if mh.protocolVersion == MqttProtocolV5 {
+		return newMqttV5Client(mh.mqttOptions)
+	}
+	return MQTT.NewClient(mh.mqttOptions)
+}
+
+// subscriptionTopic adds global prefix and converts command topics into shared subscriptions if shared subscription group is configured.
+func (mh *MqttTransport) subscriptionTopic(topic string) string {
+	topic = AddGlobalPrefixToTopic(mh.getGlobalTopicPrefix(), topic)
+	if mh.sharedSubGroup != "" && isCommandTopic(topic) {
+		topic = sharedTopic(mh.sharedSubGroup, topic)
+	}
+	return topic
+}
+
 // Start , starts adapter async.
 func (mh *MqttTransport) Start() error {
 	log.Info("<MqttAd> Connecting to MQTT broker ")
+	mh.setConnectionState(ConnectionStateConnecting, nil)
 
 	var err error
 	var delay time.Duration
 
 	for i := 1; i < mh.startFailRetryCount; i++ {
 		if token := mh.client.Connect(); token.Wait() && token.Error() == nil {
+			// errors of previous attempts must not be reported after successful connect
+			err = nil
 			break
 		} else {
 			err = token.Error()
+			mh.recordError(err)
 		}
 		delay = time.Duration(i) * time.Duration(i)
 		log.Infof("<MqttAd> Connection failed , retrying after %d sec.... ", delay)
@@ -313,6 +470,7 @@ func (mh *MqttTransport) Start() error {
 	}
 
 	if err != nil {
+		mh.setConnectionState(ConnectionStateDisconnected, err)
 		return err
 	}
 
@@ -326,13 +484,18 @@ func (mh *MqttTransport) Start() error {
 // Stop stops adapter . Adapter can't be started again using Start . In order to start adapter it has to be re-initialized
 func (mh *MqttTransport) Stop() {
 	mh.client.Disconnect(250)
+	mh.setConnectionState(ConnectionStateDisconnected, nil)
 
 	close(mh.done)
 	mh.wg.Wait()
 	mh.done = nil
+	mh.stopWorkers()
+	mh.closeSpillQueues()
 }
 
-// Subscribe - subscribing for topic
+// Subscribe - subscribing for topic.
+// Subscribe is idempotent , the topic stays subscribed until Unsubscribe is invoked and all subscription handles
+// of the topic (NewSubscription) are closed.
 func (mh *MqttTransport) Subscribe(topic string) error {
 	if strings.TrimSpace(topic) == "" {
 		return nil
@@ -341,18 +504,30 @@ func (mh *MqttTransport) Subscribe(topic string) error {
 	mh.subMutex.Lock()
 	defer mh.subMutex.Unlock()
 
+	topic = mh.subscriptionTopic(topic)
+	if err := mh.subscribeBroker(topic); err != nil {
+		return err
+	}
+	mh.legacySubs[topic] = struct{}{}
+	return nil
+}
+
+// subscribeBroker sends SUBSCRIBE to the broker , must be invoked under subMutex.
+func (mh *MqttTransport) subscribeBroker(topic string) error {
 	//subscribe to the topic /go-mqtt/sample and request messages to be delivered
 	//at a maximum qos of zero, wait for the receipt to confirm the subscription
-	topic = AddGlobalPrefixToTopic(mh.getGlobalTopicPrefix(), topic)
 	log.Debug("<MqttAd> Subscribing to topic:", topic)
 	token := mh.client.Subscribe(topic, mh.subQos, nil)
 	isInTime := token.WaitTimeout(time.Second * 20)
 	if token.Error() != nil {
 		log.Error("<MqttAd> Can't subscribe. Error :", token.Error())
+		mh.recordError(token.Error())
 		return token.Error()
 	} else if !isInTime {
 		log.Error("<MqttAd> Subscribe operation timed out")
-		return errors.New("subscribe timed out")
+		err := errors.New("subscribe timed out")
+		mh.recordError(err)
+		return err
 	}
 
 	mh.subs[topic] = mh.subQos
@@ -360,11 +535,37 @@ func (mh *MqttTransport) Subscribe(topic string) error {
 	return nil
 }
 
-// Unsubscribe , unsubscribing from topic
+// SubscribePattern subscribes for topic filter of the address pattern. Invalid pattern is rejected , see AddressPattern.Err.
+func (mh *MqttTransport) SubscribePattern(pattern *AddressPattern) error {
+	if err := pattern.Err(); err != nil {
+		return err
+	}
+	return mh.Subscribe(pattern.Topic())
+}
+
+// UnsubscribePattern unsubscribes from topic filter of the address pattern.
+func (mh *MqttTransport) UnsubscribePattern(pattern *AddressPattern) error {
+	if err := pattern.Err(); err != nil {
+		return err
+	}
+	return mh.Unsubscribe(pattern.Topic())
+}
+
+// Unsubscribe , unsubscribing from topic. Broker subscription is kept while the topic is used by open subscription handles.
 func (mh *MqttTransport) Unsubscribe(topic string) error {
 	mh.subMutex.Lock()
 	defer mh.subMutex.Unlock()
-	topic = AddGlobalPrefixToTopic(mh.getGlobalTopicPrefix(), topic)
+	topic = mh.subscriptionTopic(topic)
+	delete(mh.legacySubs, topic)
+	if refs := len(mh.subRefs[topic]); refs > 0 {
+		log.Debugf("<MqttAd> Topic %s is still used by %d subscriptions", topic, refs)
+		return nil
+	}
+	return mh.unsubscribeBroker(topic)
+}
+
+// unsubscribeBroker sends UNSUBSCRIBE to the broker , must be invoked under subMutex.
+func (mh *MqttTransport) unsubscribeBroker(topic string) error {
 	log.Debug("<MqttAd> Unsubscribing from topic:", topic)
 	token := mh.client.Unsubscribe(topic)
 	isInTime := token.WaitTimeout(time.Second * 20)
@@ -378,57 +579,90 @@ func (mh *MqttTransport) Unsubscribe(topic string) error {
 	return nil
 }
 
+// UnsubscribeAll unsubscribes from all topics , including topics used by subscription handles.
 func (mh *MqttTransport) UnsubscribeAll() {
-	var topics []string
 	mh.subMutex.Lock()
-	for i := range mh.subs {
-		topics = append(topics, i)
-	}
-	mh.subMutex.Unlock()
-	for _, t := range topics {
-		if err := mh.Unsubscribe(t); err != nil {
+	defer mh.subMutex.Unlock()
+	for topic := range mh.subs {
+		delete(mh.legacySubs, topic)
+		delete(mh.subRefs, topic)
+		if err := mh.unsubscribeBroker(topic); err != nil {
 			log.Error(errors.Wrap(err, "unsubscribing from topic"))
 		}
 	}
 }
 
-func (mh *MqttTransport) onConnectionLost(_ MQTT.Client, err error) {
+func (mh *MqttTransport) onConnectionLost(client MQTT.Client, err error) {
+	mh.setConnectionState(ConnectionStateLost, err)
+	if mh.connectionLostHandler != nil {
+		mh.connectionLostHandler(client, err)
+		return
+	}
 	log.Errorf("<MqttAd> Connection lost with MQTT broker . Error : %v", err)
 }
 
 func (mh *MqttTransport) onConnect(_ MQTT.Client) {
+	log.Infof("<MqttAd> Connection established with MQTT broker .")
+	mh.setConnectionState(ConnectionStateConnected, nil)
+	if err := mh.resubscribe(); err != nil {
+		log.Error("Can't subscribe. Error :", err)
+		mh.recordError(err)
+		return
+	}
+	mh.setConnectionState(ConnectionStateResubscribed, nil)
+}
+
+// resubscribe restores all subscriptions after (re)connect.
+func (mh *MqttTransport) resubscribe() error {
 	mh.subMutex.Lock()
 	defer mh.subMutex.Unlock()
-
-	log.Infof("<MqttAd> Connection established with MQTT broker .")
 	if len(mh.subs) > 0 {
 		if token := mh.client.SubscribeMultiple(mh.subs, nil); token.Wait() && token.Error() != nil {
-			log.Error("Can't subscribe. Error :", token.Error())
+			return token.Error()
 		}
 	}
+	return nil
 }
 
 // onMessage is a message handler registered with MQTT client.
-// It enqueues incoming messages to an intermediate queue or drops them if the queue is full.
-// The intermediate queue is required, because the handler cannot be blocking.
+// It enqueues incoming messages to an intermediate queue , if the queue is full main queue overflow policy is applied.
+// The intermediate queue is required, because the handler should not be blocking.
 func (mh *MqttTransport) onMessage(_ MQTT.Client, msg MQTT.Message) {
-	select {
-	case mh.mainQueue <- msg:
-		return
-	default:
-		log.Warn("<MqttAd> Main message queue is full")
-	}
+	mh.enqueueMainMessage(msg)
 }
 
 func (mh *MqttTransport) handleIncomingMessages() {
 	defer mh.wg.Done()
 
+	ticker := time.NewTicker(spillFlushInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-mh.done:
 			return
 		case msg := <-mh.mainQueue:
 			mh.handleIncomingMessage(msg)
+			continue
+		case <-ticker.C:
+			// channel spills are flushed also under steady traffic , when main queue is never empty
+			mh.flushChannelSpills()
+			continue
+		default:
+		}
+		// spilled messages are newer than messages in main queue
+		if msg, ok := mh.popMainSpill(); ok {
+			mh.handleIncomingMessage(msg)
+			continue
+		}
+		select {
+		case <-mh.done:
+			return
+		case msg := <-mh.mainQueue:
+			mh.handleIncomingMessage(msg)
+		case <-mh.spillSignal:
+		case <-ticker.C:
+			mh.flushChannelSpills()
 		}
 	}
 }
@@ -454,52 +688,84 @@ func (mh *MqttTransport) handleIncomingMessage(msg MQTT.Message) {
 		return
 	}
 	var fimpMsg *FimpMessage
-
-	switch addr.PayloadType {
-	case DefaultPayload:
-		fimpMsg, err = NewMessageFromBytes(msg.Payload())
-	case CompressedJsonPayload:
-		fimpMsg, err = mh.compressor.DecompressFimpMsg(msg.Payload())
-	default:
-		// This means unknown binary payload , for instance compressed message
-		log.Warnf("[fimpgo] Unknown PayloadType=%s topic=%s", addr.PayloadType, topic)
-		return
+	var props *MessageProperties
+	if v5msg, ok := msg.(propertiesCarrier); ok {
+		props = v5msg.properties()
+	}
+	codec, ok := GetPayloadCodec(addr.PayloadType)
+	if ok {
+		fimpMsg, err = codec.Decode(msg.Payload())
+		if err != nil {
+			log.Errorf("[fimpgo] Processing payload from topic=%s err: %v", topic, err)
+			log.Tracef("[fimpgo] Payload preview (len=%d): %.100s", len(msg.Payload()), msg.Payload())
+			return
+		}
+		applyMessageProperties(fimpMsg, props)
+	} else {
+		// This means unknown binary payload , it's delivered with nil Payload and receiver has to decode RawPayload.
+		// Codec can be registered using RegisterPayloadCodec
+		log.Debugf("[fimpgo] Unknown PayloadType=%s topic=%s", addr.PayloadType, topic)
 	}
 
+	fimpMsg, auth, err := mh.authenticate(addr, fimpMsg)
 	if err != nil {
-		log.Errorf("[fimpgo] Processing payload from topic=%s err: %v", topic, err)
-		log.Tracef("[fimpgo] Payload preview (len=%d): %.100s", len(msg.Payload()), msg.Payload())
+		log.Warnf("<MqttAd> Message from topic=%s is dropped. Error : %v", topic, err)
 		return
 	}
 
-	if mh.msgHandler != nil {
-		mh.msgHandler(topic, addr, fimpMsg, msg.Payload())
+	if fimpMsg != nil {
+		if err := mh.validateSchema(addr, fimpMsg); err != nil {
+			log.Errorf("<MqttAd> Message from topic=%s is dropped. Error : %v", topic, err)
+			return
+		}
+	}
+	// channels get raw payload only if it can't be decoded
+	var rawPayload []byte
+	if fimpMsg == nil {
+		rawPayload = msg.Payload()
 	}
 
+	type subscriber struct {
+		id     string
+		worker *deliveryWorker
+		policy OverflowPolicy
+	}
+	// delivery queues are collected under the lock , but filled without holding it , so full queue doesn't block registrations.
+	var subscribers []subscriber
+	var handlerQueue *subscriber
 	mh.channelRegMux.Lock()
-	defer mh.channelRegMux.Unlock()
-
-	for i := range mh.subChannels {
-		if !mh.isChannelInterested(i, topic, addr, fimpMsg) {
-			continue
+	if w, ok := mh.subWorkers[MessageHandlerID]; ok && mh.msgHandler != nil {
+		handlerQueue = &subscriber{id: MessageHandlerID, worker: w, policy: mh.channelPolicies[MessageHandlerID]}
+	}
+	mh.subIndex.match(topic, fimpMsg, func(id string) {
+		if w, ok := mh.subWorkers[id]; ok {
+			subscribers = append(subscribers, subscriber{id: id, worker: w, policy: mh.channelPolicies[id]})
 		}
-		var fmsg Message
-		if addr.PayloadType == DefaultPayload || addr.PayloadType == CompressedJsonPayload {
-			fmsg = Message{Topic: topic, Addr: addr, Payload: fimpMsg}
-		} else {
-			// message receiver should do decompressions
-			fmsg = Message{Topic: topic, Addr: addr, RawPayload: msg.Payload()}
+	})
+	// filter functions can't be indexed
+	for i := range mh.subFilterFuncs {
+		w, ok := mh.subWorkers[i]
+		if ok && mh.isChannelInterested(i, topic, addr, fimpMsg) {
+			subscribers = append(subscribers, subscriber{id: i, worker: w, policy: mh.channelPolicies[i]})
 		}
-		timer := time.NewTimer(time.Second * time.Duration(mh.receiveChTimeout))
-		select {
-		case mh.subChannels[i] <- &fmsg:
-			timer.Stop()
-			// send to channel
-		case <-timer.C:
-			log.Info("<MqttAd> Channel is not read for ", mh.receiveChTimeout)
+	}
+	mh.channelRegMux.Unlock()
+
+	if handlerQueue != nil {
+		// handler gets raw payload of the message it receives , it's the inner message if the message was unwrapped
+		handlerPayload := msg.Payload()
+		if auth != nil {
+			if handlerPayload, err = codec.Encode(fimpMsg); err != nil {
+				log.Errorf("<MqttAd> Verified message from topic=%s can't be encoded. Error : %v", topic, err)
+				handlerPayload = nil
+			}
 		}
+		mh.deliverToChannel(handlerQueue.id, handlerQueue.worker, handlerQueue.policy, &Message{Topic: topic, Addr: addr, Payload: fimpMsg, RawPayload: handlerPayload, Properties: props, Auth: auth})
 	}
 
+	for _, sub := range subscribers {
+		mh.deliverToChannel(sub.id, sub.worker, sub.policy, &Message{Topic: topic, Addr: addr, Payload: fimpMsg, RawPayload: rawPayload, Properties: props, Auth: auth})
+	}
 }
 
 // isChannelInterested validates if channel is interested in message. Filtering is executed against either static filters or filter function
@@ -519,40 +785,26 @@ func (mh *MqttTransport) isChannelInterested(chanName string, topic string, addr
 		// no filters has been set
 		return true
 	}
-	if msg != nil {
-		if utils.RouteIncludesTopic(filter.Topic, topic) &&
-			(msg.Service == filter.Service || filter.Service == "*") &&
-			(msg.Type == filter.Interface || filter.Interface == "*") {
-			return true
-		}
-	} else {
-		// It means binary payload , and message can't be parsed
-		if utils.RouteIncludesTopic(filter.Topic, topic) {
-			return true
-		}
-	}
-
-	return false
+	return filter.Matches(topic, addr, msg)
 }
 
 // Publish publishes message to FIMP address
 func (mh *MqttTransport) Publish(addr *Address, fimpMsg *FimpMessage) error {
+	return mh.PublishWithProperties(addr, fimpMsg, nil)
+}
+
+// PublishWithProperties publishes message to FIMP address with MQTT v5 properties.
+// Response topic and correlation id of the message are always mapped to v5 properties , properties are ignored in MQTT 3.1.1 mode.
+func (mh *MqttTransport) PublishWithProperties(addr *Address, fimpMsg *FimpMessage, props *MessageProperties) error {
 	mh.ensureDefaultSource(fimpMsg)
+	if err := mh.validateSchema(addr, fimpMsg); err != nil {
+		return err
+	}
 
-	var bytm []byte
-	var err error
 	if addr.PayloadType == "" {
 		addr.PayloadType = DefaultPayload
 	}
-	switch addr.PayloadType {
-	case DefaultPayload:
-		bytm, err = fimpMsg.SerializeToJson()
-	case CompressedJsonPayload:
-		bytm, err = mh.compressor.CompressFimpMsg(fimpMsg)
-	default:
-		// This means unknown binary payload , for instance compressed message
-		log.Trace("<MqttAd> Publish - unknown binary payload :", addr.PayloadType)
-	}
+	bytm, err := EncodePayload(addr.PayloadType, fimpMsg)
 	if err != nil {
 		return err
 	}
@@ -560,30 +812,34 @@ func (mh *MqttTransport) Publish(addr *Address, fimpMsg *FimpMessage) error {
 	if strings.TrimSpace(mh.globalTopicPrefix) != "" {
 		topic = AddGlobalPrefixToTopic(mh.getGlobalTopicPrefix(), topic)
 	}
-	if err == nil {
-		log.Trace("<MqttAd> Publishing msg to topic:", topic)
-		mh.client.Publish(topic, mh.pubQos, false, bytm)
-		return nil
-	}
-	return err
+	log.Trace("<MqttAd> Publishing msg to topic:", topic)
+	return mh.watchPublish(topic, mh.publish(topic, bytm, fimpMsg, props))
 }
 
 // PublishToTopic publishes iotMsg to string topic
 func (mh *MqttTransport) PublishToTopic(topic string, fimpMsg *FimpMessage) error {
+	return mh.PublishToTopicWithProperties(topic, fimpMsg, nil)
+}
+
+// PublishToTopicWithProperties publishes iotMsg to string topic with MQTT v5 properties.
+func (mh *MqttTransport) PublishToTopicWithProperties(topic string, fimpMsg *FimpMessage, props *MessageProperties) error {
 	mh.ensureDefaultSource(fimpMsg)
 
-	byteMessage, err := fimpMsg.SerializeToJson()
+	addr, err := NewAddressFromString(topic)
 	if err != nil {
+		addr = nil
+	}
+	if err = mh.validateSchema(addr, fimpMsg); err != nil {
 		return err
 	}
-	addr, err := NewAddressFromString(topic)
-	if err == nil {
-		if addr.PayloadType == CompressedJsonPayload {
-			byteMessage, err = mh.compressor.CompressBinMsg(byteMessage)
-			if err != nil {
-				return err
-			}
-		}
+	// topics , which are not FIMP addresses , get default JSON payload
+	payloadType := DefaultPayload
+	if addr != nil {
+		payloadType = addr.PayloadType
+	}
+	byteMessage, err := EncodePayload(payloadType, fimpMsg)
+	if err != nil {
+		return err
 	}
 
 	if strings.TrimSpace(mh.globalTopicPrefix) != "" {
@@ -591,7 +847,7 @@ func (mh *MqttTransport) PublishToTopic(topic string, fimpMsg *FimpMessage) erro
 	}
 
 	log.Trace("<MqttAd> Publishing msg to topic:", topic)
-	return mh.client.Publish(topic, mh.pubQos, false, byteMessage).Error()
+	return mh.watchPublish(topic, mh.publish(topic, byteMessage, fimpMsg, props))
 }
 
 // RespondToRequest should be used by a service to respond to request
@@ -604,38 +860,41 @@ func (mh *MqttTransport) RespondToRequest(requestMsg *FimpMessage, responseMsg *
 
 func (mh *MqttTransport) PublishSync(addr *Address, fimpMsg *FimpMessage) error {
 	mh.ensureDefaultSource(fimpMsg)
+	if err := mh.validateSchema(addr, fimpMsg); err != nil {
+		return err
+	}
 
-	var bytm []byte
-	var err error
 	if addr.PayloadType == "" {
 		addr.PayloadType = DefaultPayload
 	}
-	switch addr.PayloadType {
-	case DefaultPayload:
-		bytm, err = fimpMsg.SerializeToJson()
-	case CompressedJsonPayload:
-		bytm, err = mh.compressor.CompressFimpMsg(fimpMsg)
-
-	}
+	bytm, err := EncodePayload(addr.PayloadType, fimpMsg)
 	topic := addr.Serialize()
 	if strings.TrimSpace(mh.globalTopicPrefix) != "" {
 		topic = AddGlobalPrefixToTopic(mh.getGlobalTopicPrefix(), topic)
 	}
 	if err == nil {
 		log.Trace("<MqttAd> Publishing msg to topic:", topic)
-		token := mh.client.Publish(topic, mh.pubQos, false, bytm)
+		token := mh.publish(topic, bytm, fimpMsg, nil)
 		if token.WaitTimeout(mh.syncPublishTimeout) && token.Error() == nil {
 			return nil
 		} else {
-			return token.Error()
+			return mh.publishFailed(topic, token.Error())
 		}
 	}
 	return err
 }
 
+// publish sends serialized message using MQTT v5 properties if the client supports them.
+func (mh *MqttTransport) publish(topic string, payload []byte, fimpMsg *FimpMessage, props *MessageProperties) MQTT.Token {
+	if v5client, ok := mh.client.(*mqttV5Client); ok {
+		return v5client.publishWithProperties(topic, mh.pubQos, false, payload, toPahoProperties(fimpMsg, props))
+	}
+	return mh.client.Publish(topic, mh.pubQos, false, payload)
+}
+
 func (mh *MqttTransport) PublishRaw(topic string, bytem []byte) {
 	log.Trace("<MqttAd> Publishing msg to topic:", topic)
-	mh.client.Publish(topic, mh.pubQos, false, bytem)
+	_ = mh.watchPublish(topic, mh.client.Publish(topic, mh.pubQos, false, bytem))
 }
 
 func (mh *MqttTransport) PublishRawSync(topic string, bytem []byte) error {
@@ -644,7 +903,7 @@ func (mh *MqttTransport) PublishRawSync(topic string, bytem []byte) error {
 	if token.WaitTimeout(mh.syncPublishTimeout) && token.Error() == nil {
 		return nil
 	} else {
-		return token.Error()
+		return mh.publishFailed(topic, token.Error())
 	}
 
 }
@@ -717,7 +976,7 @@ func (mh *MqttTransport) ConfigureTls(privateKeyFileName, certFileName, certDir
 		TLSConfig.Certificates = []tls.Certificate{cert}
 	}
 	mh.mqttOptions.SetTLSConfig(TLSConfig)
-	mh.client = MQTT.NewClient(mh.mqttOptions)
+	mh.client = mh.newClient()
 	return nil
 
 }
@@ -32,3 +32,86 @@ func WithConnectionLostHandler(h mqtt.ConnectionLostHandler) Option {
 	}
 	return connectionLostHandler(h)
 }
+
+type (
+	mainQueueOverflowPolicy OverflowPolicy
+	spillDir                string
+	dropHandlerOption       DropHandler
+)
+
+func (p mainQueueOverflowPolicy) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.MainQueueOverflowPolicy = OverflowPolicy(p)
+}
+
+func (d spillDir) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.SpillDir = string(d)
+}
+
+func (h dropHandlerOption) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.dropHandler = DropHandler(h)
+}
+
+// WithMainQueueOverflowPolicy sets policy applied when transport main queue is full.
+func WithMainQueueOverflowPolicy(policy OverflowPolicy) Option {
+	return mainQueueOverflowPolicy(policy)
+}
+
+// WithSpillDir sets directory used by OverflowSpillToDisk policy.
+func WithSpillDir(dir string) Option {
+	return spillDir(dir)
+}
+
+// WithDropHandler sets callback invoked every time inbound message is dropped.
+func WithDropHandler(h DropHandler) Option {
+	return dropHandlerOption(h)
+}
+
+type schemaValidatorOption struct {
+	validator *SchemaValidator
+}
+
+func (o schemaValidatorOption) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.schemaValidator = o.validator
+}
+
+// WithSchemaValidator validates all published and received messages against service interface definitions.
+func WithSchemaValidator(validator *SchemaValidator) Option {
+	return schemaValidatorOption{validator: validator}
+}
+
+type signatureVerifierOption struct {
+	verifier SignatureVerifier
+	policy   *SignaturePolicy
+}
+
+func (o signatureVerifierOption) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.signatureVerifier = o.verifier
+	connectionConfigs.signaturePolicy = o.policy
+}
+
+// WithSignatureVerifier unwraps and verifies received signed messages and rejects unsigned messages according to the policy , policy can be nil.
+func WithSignatureVerifier(verifier SignatureVerifier, policy *SignaturePolicy) Option {
+	return signatureVerifierOption{verifier: verifier, policy: policy}
+}
+
+type connectionStateHandlerOption ConnectionStateHandler
+
+func (h connectionStateHandlerOption) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.stateHandler = ConnectionStateHandler(h)
+}
+
+// WithConnectionStateHandler sets callback invoked on every connection state change , for instance edgeapp.Lifecycle.ReportConnectionState .
+func WithConnectionStateHandler(h ConnectionStateHandler) Option {
+	return connectionStateHandlerOption(h)
+}
+
+type publishFailureHandlerOption PublishFailureHandler
+
+func (h publishFailureHandlerOption) apply(connectionConfigs *MqttConnectionConfigs) {
+	connectionConfigs.publishFailureHandler = PublishFailureHandler(h)
+}
+
+// WithPublishFailureHandler sets callback invoked on every failed publish , including failures of asynchronous publish.
+func WithPublishFailureHandler(h PublishFailureHandler) Option {
+	return publishFailureHandlerOption(h)
+}
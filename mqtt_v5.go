@@ -0,0 +1,440 @@
+package fimpgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/futurehomeno/fimpgo/utils"
+)
+
+const (
+	MqttProtocolV311 = 4 // MQTT 3.1.1 , default protocol version
+	MqttProtocolV5   = 5 // MQTT 5
+
+	sharedSubscriptionPrefix = "$share/"
+)
+
+// MessageProperties holds MQTT v5 publish properties. Properties are ignored if transport uses MQTT 3.1.1 .
+type MessageProperties struct {
+	UserProperties  map[string]string
+	MessageExpiry   time.Duration // Message is discarded by the broker if it's not delivered within expiry interval. 0 - never expires.
+	ResponseTopic   string
+	CorrelationData []byte
+}
+
+// sharedTopic converts topic into shared subscription $share/<group>/<topic> .
+func sharedTopic(group, topic string) string {
+	if group == "" || strings.HasPrefix(topic, sharedSubscriptionPrefix) {
+		return topic
+	}
+	return sharedSubscriptionPrefix + group + "/" + topic
+}
+
+// stripSharedTopic returns topic filter without $share/<group>/ prefix.
+func stripSharedTopic(topic string) string {
+	if !strings.HasPrefix(topic, sharedSubscriptionPrefix) {
+		return topic
+	}
+	parts := strings.SplitN(topic, "/", 3)
+	if len(parts) < 3 {
+		return topic
+	}
+	return parts[2]
+}
+
+// isCommandTopic returns true if topic carries FIMP commands.
+func isCommandTopic(topic string) bool {
+	for _, seg := range strings.Split(topic, "/") {
+		if seg == "mt:"+MsgTypeCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// toPahoProperties builds v5 publish properties. Response topic and correlation data are taken from FIMP message if not set explicitly.
+func toPahoProperties(fimpMsg *FimpMessage, props *MessageProperties) *paho.PublishProperties {
+	result := &paho.PublishProperties{}
+	if fimpMsg != nil {
+		result.ResponseTopic = fimpMsg.ResponseToTopic
+		if fimpMsg.CorrelationID != "" {
+			result.CorrelationData = []byte(fimpMsg.CorrelationID)
+		}
+	}
+	if props == nil {
+		return result
+	}
+	if props.ResponseTopic != "" {
+		result.ResponseTopic = props.ResponseTopic
+	}
+	if props.CorrelationData != nil {
+		result.CorrelationData = props.CorrelationData
+	}
+	if props.MessageExpiry > 0 {
+		expiry := uint32(props.MessageExpiry / time.Second)
+		if expiry == 0 {
+			expiry = 1
+		}
+		result.MessageExpiry = &expiry
+	}
+	for k, v := range props.UserProperties {
+		result.User.Add(k, v)
+	}
+	return result
+}
+
+// fromPahoProperties converts v5 publish properties into MessageProperties.
+func fromPahoProperties(props *paho.PublishProperties) *MessageProperties {
+	if props == nil {
+		return &MessageProperties{}
+	}
+	result := &MessageProperties{ResponseTopic: props.ResponseTopic, CorrelationData: props.CorrelationData}
+	if props.MessageExpiry != nil {
+		result.MessageExpiry = time.Duration(*props.MessageExpiry) * time.Second
+	}
+	if len(props.User) > 0 {
+		result.UserProperties = make(map[string]string, len(props.User))
+		for _, p := range props.User {
+			result.UserProperties[p.Key] = p.Value
+		}
+	}
+	return result
+}
+
+// applyMessageProperties fills FIMP envelope fields from v5 properties , if they are not set in message body.
+func applyMessageProperties(fimpMsg *FimpMessage, props *MessageProperties) {
+	if fimpMsg == nil || props == nil {
+		return
+	}
+	if fimpMsg.ResponseToTopic == "" {
+		fimpMsg.ResponseToTopic = props.ResponseTopic
+	}
+	if fimpMsg.CorrelationID == "" && len(props.CorrelationData) > 0 {
+		fimpMsg.CorrelationID = string(props.CorrelationData)
+	}
+}
+
+// mqttV5Message is an inbound v5 message , which implements paho v1 MQTT.Message interface.
+type mqttV5Message struct {
+	publish *paho.Publish
+}
+
+func (m *mqttV5Message) Duplicate() bool   { return false }
+func (m *mqttV5Message) Qos() byte         { return m.publish.QoS }
+func (m *mqttV5Message) Retained() bool    { return m.publish.Retain }
+func (m *mqttV5Message) Topic() string     { return m.publish.Topic }
+func (m *mqttV5Message) MessageID() uint16 { return m.publish.PacketID }
+func (m *mqttV5Message) Payload() []byte   { return m.publish.Payload }
+func (m *mqttV5Message) Ack()              {}
+
+// propertiesCarrier is implemented by inbound messages , which carry MQTT v5 properties.
+type propertiesCarrier interface {
+	properties() *MessageProperties
+}
+
+func (m *mqttV5Message) properties() *MessageProperties {
+	return fromPahoProperties(m.publish.Properties)
+}
+
+// mqttToken implements paho v1 MQTT.Token interface.
+type mqttToken struct {
+	done chan struct{}
+	err  error
+}
+
+func newMqttToken() *mqttToken {
+	return &mqttToken{done: make(chan struct{})}
+}
+
+func (t *mqttToken) complete(err error) {
+	t.err = err
+	close(t.done)
+}
+
+func (t *mqttToken) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *mqttToken) WaitTimeout(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *mqttToken) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *mqttToken) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// mqttV5Client adapts paho.golang MQTT v5 client to paho v1 MQTT.Client interface , so MqttTransport can use both protocol versions.
+// Reconnects are handled by autopaho , OnConnect handler is invoked after every successful (re)connect.
+type mqttV5Client struct {
+	options       MQTT.ClientOptions
+	optionsReader MQTT.ClientOptionsReader
+
+	mux         sync.Mutex
+	connManager *autopaho.ConnectionManager
+	isConnected atomic.Bool
+	routesMux   sync.RWMutex
+	routes      map[string]MQTT.MessageHandler
+}
+
+func newMqttV5Client(options *MQTT.ClientOptions) *mqttV5Client {
+	return &mqttV5Client{
+		options:       *options,
+		optionsReader: MQTT.NewClient(options).OptionsReader(),
+		routes:        make(map[string]MQTT.MessageHandler),
+	}
+}
+
+func (c *mqttV5Client) clientConfig() autopaho.ClientConfig {
+	cfg := autopaho.ClientConfig{
+		BrokerUrls:        c.options.Servers,
+		TlsCfg:            c.options.TLSConfig,
+		KeepAlive:         uint16(c.options.KeepAlive),
+		ConnectRetryDelay: c.options.ConnectRetryInterval,
+		ConnectTimeout:    c.options.ConnectTimeout,
+		OnConnectionUp: func(_ *autopaho.ConnectionManager, _ *paho.Connack) {
+			c.isConnected.Store(true)
+			if c.options.OnConnect != nil {
+				c.options.OnConnect(c)
+			}
+		},
+		OnConnectError: func(err error) {
+			log.Debug("<MqttAd> MQTT v5 connection attempt failed. Error :", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: c.options.ClientID,
+			Router:   paho.NewSingleHandlerRouter(c.onPublish),
+			OnClientError: func(err error) {
+				c.onConnectionLost(err)
+			},
+			OnServerDisconnect: func(d *paho.Disconnect) {
+				c.onConnectionLost(fmt.Errorf("server disconnected with reason code %d", d.ReasonCode))
+			},
+		},
+	}
+	if c.options.Username != "" {
+		cfg.SetUsernamePassword(c.options.Username, []byte(c.options.Password))
+	}
+	return cfg
+}
+
+func (c *mqttV5Client) onConnectionLost(err error) {
+	c.isConnected.Store(false)
+	if c.options.OnConnectionLost != nil {
+		c.options.OnConnectionLost(c, err)
+	}
+	// autopaho reconnects automatically until the client is disconnected
+	if c.options.OnReconnecting != nil && c.getConnManager() != nil {
+		c.options.OnReconnecting(c, &c.options)
+	}
+}
+
+func (c *mqttV5Client) onPublish(p *paho.Publish) {
+	msg := &mqttV5Message{publish: p}
+	c.routesMux.RLock()
+	for filter, handler := range c.routes {
+		if utils.RouteIncludesTopic(stripSharedTopic(filter), p.Topic) {
+			c.routesMux.RUnlock()
+			handler(c, msg)
+			return
+		}
+	}
+	c.routesMux.RUnlock()
+	if c.options.DefaultPublishHandler != nil {
+		c.options.DefaultPublishHandler(c, msg)
+	}
+}
+
+func (c *mqttV5Client) getConnManager() *autopaho.ConnectionManager {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.connManager
+}
+
+func (c *mqttV5Client) IsConnected() bool {
+	return c.isConnected.Load()
+}
+
+func (c *mqttV5Client) IsConnectionOpen() bool {
+	return c.isConnected.Load()
+}
+
+// Connect starts connection manager and waits until first connection is established or connect timeout expires.
+func (c *mqttV5Client) Connect() MQTT.Token {
+	token := newMqttToken()
+	go func() {
+		if !c.options.CleanSession {
+			// autopaho always connects with clean start and without session expiry , persistent session would be silently lost
+			token.complete(errors.New("mqtt v5 doesn't support persistent sessions , CleanSession must be true"))
+			return
+		}
+		cfg := c.clientConfig()
+		cm, err := autopaho.NewConnection(context.Background(), cfg)
+		if err != nil {
+			token.complete(err)
+			return
+		}
+		timeout := c.options.ConnectTimeout
+		if timeout == 0 {
+			timeout = 30 * time.Second
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err = cm.AwaitConnection(ctx); err != nil {
+			_ = cm.Disconnect(context.Background())
+			token.complete(fmt.Errorf("mqtt v5 connection failed: %w", err))
+			return
+		}
+		c.mux.Lock()
+		c.connManager = cm
+		c.mux.Unlock()
+		token.complete(nil)
+	}()
+	return token
+}
+
+func (c *mqttV5Client) Disconnect(quiesce uint) {
+	c.mux.Lock()
+	cm := c.connManager
+	c.connManager = nil
+	c.mux.Unlock()
+	if cm == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(quiesce)*time.Millisecond)
+	defer cancel()
+	if err := cm.Disconnect(ctx); err != nil {
+		log.Debug("<MqttAd> MQTT v5 disconnect error :", err)
+	}
+	c.isConnected.Store(false)
+}
+
+func (c *mqttV5Client) Publish(topic string, qos byte, retained bool, payload interface{}) MQTT.Token {
+	return c.publishWithProperties(topic, qos, retained, payload, nil)
+}
+
+func (c *mqttV5Client) publishWithProperties(topic string, qos byte, retained bool, payload interface{}, props *paho.PublishProperties) MQTT.Token {
+	token := newMqttToken()
+	var body []byte
+	switch p := payload.(type) {
+	case []byte:
+		body = p
+	case string:
+		body = []byte(p)
+	default:
+		token.complete(fmt.Errorf("unknown payload type %T", payload))
+		return token
+	}
+	cm := c.getConnManager()
+	if cm == nil {
+		token.complete(errors.New("not connected"))
+		return token
+	}
+	go func() {
+		ctx, cancel := c.operationContext()
+		defer cancel()
+		_, err := cm.Publish(ctx, &paho.Publish{QoS: qos, Retain: retained, Topic: topic, Payload: body, Properties: props})
+		token.complete(err)
+	}()
+	return token
+}
+
+func (c *mqttV5Client) Subscribe(topic string, qos byte, callback MQTT.MessageHandler) MQTT.Token {
+	return c.SubscribeMultiple(map[string]byte{topic: qos}, callback)
+}
+
+func (c *mqttV5Client) SubscribeMultiple(filters map[string]byte, callback MQTT.MessageHandler) MQTT.Token {
+	token := newMqttToken()
+	cm := c.getConnManager()
+	if cm == nil {
+		token.complete(errors.New("not connected"))
+		return token
+	}
+	sub := &paho.Subscribe{}
+	for topic, qos := range filters {
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: qos})
+		if callback != nil {
+			c.AddRoute(topic, callback)
+		}
+	}
+	go func() {
+		ctx, cancel := c.operationContext()
+		defer cancel()
+		suback, err := cm.Subscribe(ctx, sub)
+		if err == nil && suback != nil {
+			for _, code := range suback.Reasons {
+				if code >= 0x80 {
+					err = fmt.Errorf("subscription rejected with reason code %d", code)
+					break
+				}
+			}
+		}
+		token.complete(err)
+	}()
+	return token
+}
+
+func (c *mqttV5Client) Unsubscribe(topics ...string) MQTT.Token {
+	token := newMqttToken()
+	c.routesMux.Lock()
+	for _, topic := range topics {
+		delete(c.routes, topic)
+	}
+	c.routesMux.Unlock()
+	cm := c.getConnManager()
+	if cm == nil {
+		token.complete(errors.New("not connected"))
+		return token
+	}
+	go func() {
+		ctx, cancel := c.operationContext()
+		defer cancel()
+		_, err := cm.Unsubscribe(ctx, &paho.Unsubscribe{Topics: topics})
+		token.complete(err)
+	}()
+	return token
+}
+
+func (c *mqttV5Client) AddRoute(topic string, callback MQTT.MessageHandler) {
+	c.routesMux.Lock()
+	c.routes[topic] = callback
+	c.routesMux.Unlock()
+}
+
+func (c *mqttV5Client) OptionsReader() MQTT.ClientOptionsReader {
+	return c.optionsReader
+}
+
+func (c *mqttV5Client) operationContext() (context.Context, context.CancelFunc) {
+	timeout := c.options.WriteTimeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
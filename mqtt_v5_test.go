@@ -0,0 +1,79 @@
+package fimpgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageProperties_Mapping(t *testing.T) {
+	msg := NewStringMessage("cmd.test.set", "test", "on", nil, nil, nil)
+	msg.ResponseToTopic = "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+	msg.CorrelationID = "123"
+
+	props := toPahoProperties(msg, &MessageProperties{UserProperties: map[string]string{"site_id": "1"}, MessageExpiry: 1500 * time.Millisecond})
+	assert.Equal(t, msg.ResponseToTopic, props.ResponseTopic)
+	assert.Equal(t, []byte("123"), props.CorrelationData)
+	require.NotNil(t, props.MessageExpiry)
+	assert.Equal(t, uint32(1), *props.MessageExpiry)
+	assert.Equal(t, "1", props.User.Get("site_id"))
+
+	received := fromPahoProperties(props)
+	assert.Equal(t, map[string]string{"site_id": "1"}, received.UserProperties)
+	assert.Equal(t, time.Second, received.MessageExpiry)
+
+	inbound := NewStringMessage("cmd.test.set", "test", "on", nil, nil, nil)
+	applyMessageProperties(inbound, received)
+	assert.Equal(t, msg.ResponseToTopic, inbound.ResponseToTopic)
+	assert.Equal(t, "123", inbound.CorrelationID)
+}
+
+func TestSharedTopic(t *testing.T) {
+	topic := "pt:j1/mt:cmd/rt:app/rn:test/ad:1"
+	shared := sharedTopic("backend", topic)
+	assert.Equal(t, "$share/backend/"+topic, shared)
+	assert.Equal(t, shared, sharedTopic("backend", shared))
+	assert.Equal(t, topic, stripSharedTopic(shared))
+	assert.Equal(t, topic, sharedTopic("", topic))
+
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ServerURI: "tcp://localhost:1883", ProtocolVersion: MqttProtocolV5, SharedSubscriptionGroup: "backend", GlobalTopicPrefix: "hub1"})
+	assert.Equal(t, "$share/backend/hub1/"+topic, mh.subscriptionTopic(topic))
+	assert.Equal(t, "hub1/pt:j1/mt:evt/rt:app/rn:test/ad:1", mh.subscriptionTopic("pt:j1/mt:evt/rt:app/rn:test/ad:1"))
+}
+
+func TestMqttTransport_V5InboundMessage(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ServerURI: "tcp://localhost:1883", ProtocolVersion: MqttProtocolV5})
+	_, ok := mh.Client().(*mqttV5Client)
+	require.True(t, ok)
+
+	ch := make(MessageCh, 1)
+	mh.RegisterChannel("test", ch)
+
+	payload, err := NewNullMessage("cmd.test.get_report", "test", nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	mh.handleIncomingMessage(&mqttV5Message{publish: &paho.Publish{
+		Topic:   "pt:j1/mt:cmd/rt:app/rn:test/ad:1",
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			ResponseTopic:   "pt:j1/mt:rsp/rt:app/rn:client/ad:1",
+			CorrelationData: []byte("abc"),
+			User:            paho.UserProperties{{Key: "tenant", Value: "t1"}},
+		},
+	}})
+
+	msg := <-ch
+	assert.Equal(t, "pt:j1/mt:rsp/rt:app/rn:client/ad:1", msg.Payload.ResponseToTopic)
+	assert.Equal(t, "abc", msg.Payload.CorrelationID)
+	require.NotNil(t, msg.Properties)
+	assert.Equal(t, "t1", msg.Properties.UserProperties["tenant"])
+}
+
+func TestMqttV5Client_PersistentSessionRejected(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{ServerURI: "tcp://localhost:1", ProtocolVersion: MqttProtocolV5, CleanSession: false})
+	token := mh.Client().Connect()
+	require.True(t, token.WaitTimeout(time.Second))
+	assert.ErrorContains(t, token.Error(), "persistent sessions")
+}
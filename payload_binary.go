@@ -0,0 +1,341 @@
+package fimpgo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// binaryMessage is the layout of a message in binary payloads. It uses the same keys as JSON payload ,
+// but values are encoded natively , for instance binary value is a byte string instead of base64 string.
+type binaryMessage struct {
+	Type            string            `cbor:"type" msgpack:"type"`
+	Service         string            `cbor:"serv" msgpack:"serv"`
+	ValueType       string            `cbor:"val_t" msgpack:"val_t"`
+	Value           interface{}       `cbor:"val" msgpack:"val"`
+	Tags            []string          `cbor:"tags,omitempty" msgpack:"tags,omitempty"`
+	Properties      map[string]string `cbor:"props,omitempty" msgpack:"props,omitempty"`
+	Storage         *binaryStorage    `cbor:"storage,omitempty" msgpack:"storage,omitempty"`
+	Version         string            `cbor:"ver,omitempty" msgpack:"ver,omitempty"`
+	CorrelationID   string            `cbor:"corid,omitempty" msgpack:"corid,omitempty"`
+	ResponseToTopic string            `cbor:"resp_to,omitempty" msgpack:"resp_to,omitempty"`
+	Source          string            `cbor:"src,omitempty" msgpack:"src,omitempty"`
+	CreationTime    string            `cbor:"ctime,omitempty" msgpack:"ctime,omitempty"`
+	UID             string            `cbor:"uid,omitempty" msgpack:"uid,omitempty"`
+	Topic           string            `cbor:"topic,omitempty" msgpack:"topic,omitempty"`
+}
+
+type binaryStorage struct {
+	Strategy string `cbor:"strategy,omitempty" msgpack:"strategy,omitempty"`
+	SubValue string `cbor:"sub_value,omitempty" msgpack:"sub_value,omitempty"`
+}
+
+var (
+	cborEncMode = mustCBOREncMode(cbor.EncOptions{ShortestFloat: cbor.ShortestFloat16})
+	// invalid UTF-8 is accepted , because strings are not validated when message is created
+	cborDecMode = mustCBORDecMode(cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil)), UTF8: cbor.UTF8DecodeInvalid})
+)
+
+func mustCBOREncMode(opts cbor.EncOptions) cbor.EncMode {
+	mode, err := opts.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+func mustCBORDecMode(opts cbor.DecOptions) cbor.DecMode {
+	mode, err := opts.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}
+
+// cborCodec is codec of cb1 payload , which is CBOR (RFC 8949) encoded message.
+type cborCodec struct{}
+
+func (cborCodec) Encode(msg *FimpMessage) ([]byte, error) {
+	bmsg, err := newBinaryMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	return cborEncMode.Marshal(bmsg)
+}
+
+func (cborCodec) Decode(payload []byte) (*FimpMessage, error) {
+	bmsg := &binaryMessage{}
+	if err := cborDecMode.Unmarshal(payload, bmsg); err != nil {
+		return nil, err
+	}
+	return bmsg.fimpMessage()
+}
+
+// msgPackCodec is codec of mp1 payload , which is MessagePack encoded message.
+type msgPackCodec struct{}
+
+func (msgPackCodec) Encode(msg *FimpMessage) ([]byte, error) {
+	bmsg, err := newBinaryMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(bmsg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgPackCodec) Decode(payload []byte) (*FimpMessage, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(payload))
+	bmsg := &binaryMessage{}
+	if err := dec.Decode(bmsg); err != nil {
+		return nil, err
+	}
+	return bmsg.fimpMessage()
+}
+
+func newBinaryMessage(msg *FimpMessage) (*binaryMessage, error) {
+	bmsg := &binaryMessage{
+		Type:            msg.Type,
+		Service:         msg.Service,
+		ValueType:       msg.ValueType,
+		Value:           msg.Value,
+		Tags:            msg.Tags,
+		Properties:      msg.Properties,
+		Version:         msg.Version,
+		CorrelationID:   msg.CorrelationID,
+		ResponseToTopic: msg.ResponseToTopic,
+		Source:          msg.Source,
+		CreationTime:    msg.CreationTime,
+		UID:             msg.UID,
+		Topic:           msg.Topic,
+	}
+	if msg.Storage != nil {
+		bmsg.Storage = &binaryStorage{Strategy: string(msg.Storage.Strategy), SubValue: msg.Storage.SubValue}
+	}
+
+	switch msg.ValueType {
+	case VTypeObject:
+		data := msg.ValueObj
+		if msg.Value != nil || data == nil {
+			var err error
+			if data, err = json.Marshal(msg.Value); err != nil {
+				return nil, err
+			}
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var value interface{}
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		bmsg.Value = fromJSONNumbers(value)
+	case VTypeBinary:
+		if s, ok := msg.Value.(string); ok {
+			value, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, fmt.Errorf("%w: val is not base64 encoded: %w", errInvalidMessage, err)
+			}
+			bmsg.Value = value
+		}
+	case VTypeNull:
+		bmsg.Value = nil
+	}
+	return bmsg, nil
+}
+
+// fromJSONNumbers replaces json.Number with int64 , or with float64 if number is not an integer.
+func fromJSONNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = fromJSONNumbers(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = fromJSONNumbers(v[k])
+		}
+	}
+	return value
+}
+
+// fimpMessage converts decoded message into FimpMessage. Values have the same types as after JSON decoding.
+func (bmsg *binaryMessage) fimpMessage() (*FimpMessage, error) {
+	msg := &FimpMessage{
+		Type:            bmsg.Type,
+		Service:         bmsg.Service,
+		ValueType:       bmsg.ValueType,
+		Tags:            bmsg.Tags,
+		Properties:      bmsg.Properties,
+		Version:         bmsg.Version,
+		CorrelationID:   bmsg.CorrelationID,
+		ResponseToTopic: bmsg.ResponseToTopic,
+		Source:          bmsg.Source,
+		CreationTime:    bmsg.CreationTime,
+		UID:             bmsg.UID,
+		Topic:           bmsg.Topic,
+	}
+	if bmsg.Storage != nil {
+		msg.Storage = &Storage{Strategy: StorageStrategy(bmsg.Storage.Strategy), SubValue: bmsg.Storage.SubValue}
+	}
+
+	var err error
+	switch bmsg.ValueType {
+	case VTypeString:
+		msg.Value, err = binaryString(bmsg.Value)
+	case VTypeInt:
+		msg.Value, err = binaryInt(bmsg.Value)
+	case VTypeFloat:
+		msg.Value, err = binaryFloat(bmsg.Value)
+	case VTypeBool:
+		msg.Value, err = binaryBool(bmsg.Value)
+	case VTypeStrArray:
+		msg.Value, err = binaryArray(bmsg.Value, binaryString)
+	case VTypeIntArray:
+		msg.Value, err = binaryArray(bmsg.Value, binaryInt)
+	case VTypeFloatArray:
+		msg.Value, err = binaryArray(bmsg.Value, binaryFloat)
+	case VTypeBoolArray:
+		msg.Value, err = binaryArray(bmsg.Value, binaryBool)
+	case VTypeStrMap:
+		msg.Value, err = binaryMap(bmsg.Value, binaryString)
+	case VTypeIntMap:
+		msg.Value, err = binaryMap(bmsg.Value, binaryInt)
+	case VTypeFloatMap:
+		msg.Value, err = binaryMap(bmsg.Value, binaryFloat)
+	case VTypeBoolMap:
+		msg.Value, err = binaryMap(bmsg.Value, binaryBool)
+	case VTypeObject:
+		if bmsg.Value != nil {
+			msg.ValueObj, err = json.Marshal(bmsg.Value)
+		}
+	case VTypeBinary:
+		switch v := bmsg.Value.(type) {
+		case []byte:
+			msg.Value = base64.StdEncoding.EncodeToString(v)
+		case string:
+			msg.Value = v
+		default:
+			err = binaryValueError(bmsg.Value, "byte string")
+		}
+	case VTypeNull:
+	default:
+		msg.Value = bmsg.Value
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func binaryValueError(value interface{}, expected string) error {
+	return fmt.Errorf("%w: val of type %T can't be decoded as %s", errInvalidMessage, value, expected)
+}
+
+func binaryString(value interface{}) (string, error) {
+	if v, ok := value.(string); ok {
+		return v, nil
+	}
+	return "", binaryValueError(value, "string")
+}
+
+func binaryBool(value interface{}) (bool, error) {
+	if v, ok := value.(bool); ok {
+		return v, nil
+	}
+	return false, binaryValueError(value, "bool")
+}
+
+func binaryInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), nil
+		}
+	}
+	return 0, binaryValueError(value, "int")
+}
+
+func binaryFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case uint64:
+		// compact encoding may store integral floats as integers
+		return float64(v), nil
+	}
+	if v, err := binaryInt(value); err == nil {
+		return float64(v), nil
+	}
+	return 0, binaryValueError(value, "float")
+}
+
+func binaryArray[T any](value interface{}, item func(interface{}) (T, error)) ([]T, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, binaryValueError(value, "array")
+	}
+	result := make([]T, len(items))
+	for i := range items {
+		v, err := item(items[i])
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func binaryMap[T any](value interface{}, item func(interface{}) (T, error)) (map[string]T, error) {
+	if value == nil {
+		return nil, nil
+	}
+	items, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, binaryValueError(value, "map")
+	}
+	result := make(map[string]T, len(items))
+	for k := range items {
+		v, err := item(items[k])
+		if err != nil {
+			return nil, err
+		}
+		result[k] = v
+	}
+	return result, nil
+}
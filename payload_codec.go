@@ -0,0 +1,117 @@
+package fimpgo
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	CBORPayload    = "cb1"
+	MsgPackPayload = "mp1"
+)
+
+// PayloadCodec encodes and decodes messages for a payload type , which is carried in pt: segment of the topic.
+type PayloadCodec interface {
+	Encode(msg *FimpMessage) ([]byte, error)
+	Decode(payload []byte) (*FimpMessage, error)
+}
+
+var (
+	payloadCodecsMux sync.RWMutex
+	payloadCodecs    = map[string]PayloadCodec{
+		DefaultPayload:        jsonCodec{},
+		CompressedJsonPayload: &lazyCompressedJsonCodec{alg: CompressionGzip},
+		ZstdJsonPayload:       &lazyCompressedJsonCodec{alg: CompressionZstd},
+		FlateJsonPayload:      &lazyCompressedJsonCodec{alg: CompressionFlate},
+		Lz4JsonPayload:        &lazyCompressedJsonCodec{alg: CompressionLz4},
+		CBORPayload:           cborCodec{},
+		MsgPackPayload:        msgPackCodec{},
+	}
+)
+
+// RegisterPayloadCodec registers codec for payload type. Existing codec of the payload type is replaced.
+func RegisterPayloadCodec(payloadType string, codec PayloadCodec) {
+	payloadCodecsMux.Lock()
+	defer payloadCodecsMux.Unlock()
+	payloadCodecs[payloadType] = codec
+}
+
+// GetPayloadCodec returns codec registered for payload type. Empty payload type means default JSON payload.
+func GetPayloadCodec(payloadType string) (PayloadCodec, bool) {
+	if payloadType == "" {
+		payloadType = DefaultPayload
+	}
+	payloadCodecsMux.RLock()
+	defer payloadCodecsMux.RUnlock()
+	codec, ok := payloadCodecs[payloadType]
+	return codec, ok
+}
+
+// EncodePayload encodes message using codec registered for payload type.
+func EncodePayload(payloadType string, msg *FimpMessage) ([]byte, error) {
+	codec, ok := GetPayloadCodec(payloadType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedPayload, payloadType)
+	}
+	return codec.Encode(msg)
+}
+
+// DecodePayload decodes message using codec registered for payload type.
+func DecodePayload(payloadType string, payload []byte) (*FimpMessage, error) {
+	codec, ok := GetPayloadCodec(payloadType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnsupportedPayload, payloadType)
+	}
+	return codec.Decode(payload)
+}
+
+// jsonCodec is codec of default j1 payload.
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(msg *FimpMessage) ([]byte, error) {
+	return msg.SerializeToJson()
+}
+
+func (jsonCodec) Decode(payload []byte) (*FimpMessage, error) {
+	return NewMessageFromBytes(payload)
+}
+
+// compressedJsonCodec is codec of compressed JSON payloads , for instance j1c1 , which is gzip compressed JSON.
+type compressedJsonCodec struct {
+	compressor *MsgCompressor
+}
+
+// NewCompressedJsonCodec returns codec of JSON compressed by the compressor. It can be used to register payload type with custom level or dictionary.
+func NewCompressedJsonCodec(compressor *MsgCompressor) PayloadCodec {
+	return &compressedJsonCodec{compressor: compressor}
+}
+
+func (c *compressedJsonCodec) Encode(msg *FimpMessage) ([]byte, error) {
+	return c.compressor.CompressFimpMsg(msg)
+}
+
+func (c *compressedJsonCodec) Decode(payload []byte) (*FimpMessage, error) {
+	return c.compressor.DecompressFimpMsg(payload)
+}
+
+// lazyCompressedJsonCodec creates compressor on the first use , so encoders and decoders of unused payload types aren't allocated at package init.
+type lazyCompressedJsonCodec struct {
+	alg   string
+	once  sync.Once
+	codec PayloadCodec
+}
+
+func (c *lazyCompressedJsonCodec) get() PayloadCodec {
+	c.once.Do(func() {
+		c.codec = NewCompressedJsonCodec(NewMsgCompressor(c.alg, ""))
+	})
+	return c.codec
+}
+
+func (c *lazyCompressedJsonCodec) Encode(msg *FimpMessage) ([]byte, error) {
+	return c.get().Encode(msg)
+}
+
+func (c *lazyCompressedJsonCodec) Decode(payload []byte) (*FimpMessage, error) {
+	return c.get().Decode(payload)
+}
@@ -0,0 +1,164 @@
+package fimpgo
+
+import (
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadCodecs_RoundTrip(t *testing.T) {
+	for _, payloadType := range []string{DefaultPayload, CompressedJsonPayload, CBORPayload, MsgPackPayload} {
+		codec, ok := GetPayloadCodec(payloadType)
+		require.True(t, ok, payloadType)
+
+		for _, msg := range codecTestMessages() {
+			// result must be the same as after JSON round trip
+			jsonPayload, err := msg.SerializeToJson()
+			require.NoError(t, err)
+			expected, err := NewMessageFromBytes(jsonPayload)
+			if err != nil {
+				// null arrays are rejected by JSON decoder
+				continue
+			}
+
+			payload, err := codec.Encode(msg)
+			require.NoError(t, err, payloadType)
+			actual, err := codec.Decode(payload)
+			require.NoError(t, err, payloadType)
+
+			if s, ok := msg.Value.(string); ok && !utf8.ValidString(s) && payloadType != DefaultPayload && payloadType != CompressedJsonPayload {
+				// binary payloads keep invalid UTF-8 as is
+				expected.Value = s
+			}
+			if expected.ValueType == VTypeObject {
+				assert.JSONEq(t, string(expected.ValueObj), string(actual.ValueObj), payloadType)
+				expected.ValueObj, actual.ValueObj = nil, nil
+			}
+			if len(expected.Tags) == 0 && len(actual.Tags) == 0 {
+				expected.Tags, actual.Tags = nil, nil
+			}
+			if len(expected.Properties) == 0 && len(actual.Properties) == 0 {
+				expected.Properties, actual.Properties = nil, nil
+			}
+			assert.Equal(t, expected, actual, "%s %s", payloadType, jsonPayload)
+		}
+	}
+}
+
+func TestPayloadCodecs_Compact(t *testing.T) {
+	msg := NewFloatMapMessage("evt.meter_ext.report", "meter_elec", map[string]float64{"p_import": 1520.5, "u1": 230.1, "i1": 6.6}, Props{"unit": "W"}, nil, nil)
+	jsonPayload, err := EncodePayload(DefaultPayload, msg)
+	require.NoError(t, err)
+
+	for _, payloadType := range []string{CBORPayload, MsgPackPayload} {
+		payload, err := EncodePayload(payloadType, msg)
+		require.NoError(t, err)
+		assert.Less(t, len(payload), len(jsonPayload), payloadType)
+	}
+}
+
+func TestPayloadCodecs_WrongValueType(t *testing.T) {
+	msg := NewMessage("evt.test.report", "test", VTypeInt, "1", nil, nil, nil)
+	for _, payloadType := range []string{CBORPayload, MsgPackPayload} {
+		payload, err := EncodePayload(payloadType, msg)
+		require.NoError(t, err)
+		_, err = DecodePayload(payloadType, payload)
+		assert.True(t, IsInvalidMessage(err), payloadType)
+	}
+
+	_, err := EncodePayload("x1", msg)
+	assert.True(t, IsUnsupportedPayload(err))
+	_, err = DecodePayload("x1", nil)
+	assert.True(t, IsUnsupportedPayload(err))
+}
+
+func TestPayloadCodecs_LazyCompressor(t *testing.T) {
+	codec := &lazyCompressedJsonCodec{alg: CompressionZstd}
+	assert.Nil(t, codec.codec)
+
+	payload, err := codec.Encode(NewStringMessage("evt.test.report", "test", "on", nil, nil, nil))
+	require.NoError(t, err)
+	require.NotNil(t, codec.codec)
+	msg, err := codec.Decode(payload)
+	require.NoError(t, err)
+	assert.Equal(t, "on", msg.Value)
+}
+
+type prefixingJsonCodec struct {
+	jsonCodec
+}
+
+func (c prefixingJsonCodec) Decode(payload []byte) (*FimpMessage, error) {
+	msg, err := c.jsonCodec.Decode(payload)
+	if err != nil {
+		return nil, err
+	}
+	msg.Service = "custom_" + msg.Service
+	return msg, nil
+}
+
+func TestMqttTransport_PayloadCodecs(t *testing.T) {
+	RegisterPayloadCodec("x1", prefixingJsonCodec{})
+	defer func() {
+		payloadCodecsMux.Lock()
+		delete(payloadCodecs, "x1")
+		payloadCodecsMux.Unlock()
+	}()
+
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{})
+	defer mh.stopWorkers()
+	ch := make(MessageCh, 10)
+	mh.RegisterChannel("test", ch)
+
+	for _, payloadType := range []string{CBORPayload, MsgPackPayload, "x1"} {
+		addr := &Address{PayloadType: payloadType, MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "test", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1"}
+		payload, err := EncodePayload(payloadType, NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, Props{"unit": "C"}, nil, nil))
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: addr.Serialize(), payload: payload})
+
+		select {
+		case msg := <-ch:
+			assert.Equal(t, payloadType, msg.Addr.PayloadType)
+			assert.Equal(t, 21.5, msg.Payload.Value)
+			assert.Equal(t, "C", msg.Payload.Properties["unit"])
+			if payloadType == "x1" {
+				assert.Equal(t, "custom_sensor_temp", msg.Payload.Service)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("message with payload type %s was not delivered", payloadType)
+		}
+	}
+
+	// unknown payload types can't be published
+	err := mh.Publish(&Address{PayloadType: "x2", MsgType: MsgTypeEvt, ResourceType: ResourceTypeApp, ResourceName: "test", ResourceAddress: "1"}, NewNullMessage("evt.test.report", "test", nil, nil, nil))
+	assert.True(t, IsUnsupportedPayload(err))
+}
+
+func TestMqttTransport_UnknownPayload(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{})
+	defer mh.stopWorkers()
+	ch := make(MessageCh, 10)
+	mh.RegisterChannelWithFilter("test", ch, FimpFilter{Topic: "+/+/rt:dev/#", Service: "sensor_temp", Interface: "evt.sensor.report"})
+	mh.SetSignatureVerifier(nil, &SignaturePolicy{RequireSignedCommands: []string{"door_lock"}})
+
+	// unknown payload is delivered with raw payload , filters check only the topic
+	mh.handleIncomingMessage(&spilledMessage{topic: "pt:x2/mt:evt/rt:dev/rn:test/ad:1/sv:sensor_temp/ad:1", payload: []byte{1, 2, 3}})
+	select {
+	case msg := <-ch:
+		assert.Nil(t, msg.Payload)
+		assert.Equal(t, []byte{1, 2, 3}, msg.RawPayload)
+	case <-time.After(time.Second):
+		t.Fatal("message with unknown payload was not delivered")
+	}
+
+	// command to protected service can't be verified
+	mh.handleIncomingMessage(&spilledMessage{topic: "pt:x2/mt:cmd/rt:dev/rn:test/ad:1/sv:door_lock/ad:1", payload: []byte{1, 2, 3}})
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message on topic %s", msg.Topic)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
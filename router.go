@@ -0,0 +1,284 @@
+package fimpgo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/futurehomeno/fimpgo/utils"
+)
+
+const defaultRouterInboundSize = 50
+
+type (
+	// Handler processes FIMP message. Returned message , if not nil , is sent back as a response.
+	Handler interface {
+		ServeFimp(msg *Message) *FimpMessage
+	}
+
+	// HandlerFunc adapts ordinary function to Handler.
+	HandlerFunc func(msg *Message) *FimpMessage
+
+	// Middleware wraps handler with additional behaviour , for instance logging or authorization.
+	Middleware func(next Handler) Handler
+
+	route struct {
+		topic   string
+		service string
+		msgType string
+		handler Handler
+	}
+)
+
+func (f HandlerFunc) ServeFimp(msg *Message) *FimpMessage {
+	return f(msg)
+}
+
+// Router dispatches inbound messages to handlers registered by topic pattern , service and message type.
+// Routes are matched in registration order , the first matching route wins.
+// Response returned by a handler is published to response topic of the request or , if it's not set , to request topic mirrored as evt.
+type Router struct {
+	transport   Transport
+	channelId   string
+	inboundCh   MessageCh
+	mux         sync.RWMutex
+	routes      []route
+	middlewares []Middleware
+	notFound    Handler
+	stopCh      chan struct{}
+	isStarted   bool
+}
+
+// NewRouter creates router , which uses transport to receive messages and send responses.
+func NewRouter(transport Transport) *Router {
+	return &Router{
+		transport: normalizeTransport(transport),
+		channelId: "router-" + uuid.New().String(),
+	}
+}
+
+// Use appends middleware to the chain. Middlewares are applied in the order they are added , the first one is outermost.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mux.Lock()
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.mux.Unlock()
+}
+
+// Handle registers handler for messages published to topics matching topic pattern (MQTT wildcards are supported) ,
+// with given service and message type. Empty string or "*" matches any service or message type.
+func (r *Router) Handle(topic, service, msgType string, handler Handler) {
+	r.mux.Lock()
+	r.routes = append(r.routes, route{topic: topic, service: service, msgType: msgType, handler: handler})
+	isStarted := r.isStarted
+	r.mux.Unlock()
+	if isStarted && r.transport != nil {
+		if err := r.transport.Subscribe(topic); err != nil {
+			log.Errorf("<Router> Can't subscribe to topic %s. Error : %v", topic, err)
+		}
+	}
+}
+
+// HandleFunc registers handler function , see Handle.
+func (r *Router) HandleFunc(topic, service, msgType string, handler func(msg *Message) *FimpMessage) {
+	r.Handle(topic, service, msgType, HandlerFunc(handler))
+}
+
+// NotFound sets handler for messages , which didn't match any route. By default such messages are ignored.
+func (r *Router) NotFound(handler Handler) {
+	r.mux.Lock()
+	r.notFound = handler
+	r.mux.Unlock()
+}
+
+// Start subscribes to all registered topic patterns , registers router channel with the transport and starts message processing.
+func (r *Router) Start() error {
+	r.mux.Lock()
+	if r.isStarted {
+		r.mux.Unlock()
+		return nil
+	}
+	topics := make(map[string]struct{})
+	for _, rt := range r.routes {
+		topics[rt.topic] = struct{}{}
+	}
+	r.inboundCh = make(MessageCh, defaultRouterInboundSize)
+	r.stopCh = make(chan struct{})
+	r.isStarted = true
+	r.mux.Unlock()
+
+	for topic := range topics {
+		if err := r.transport.Subscribe(topic); err != nil {
+			r.Stop()
+			return err
+		}
+	}
+	r.transport.RegisterChannelWithFilterFunc(r.channelId, r.inboundCh, r.isRouted)
+	go r.processMessages(r.inboundCh, r.stopCh)
+	return nil
+}
+
+// Stop unregisters router channel and stops message processing. Subscriptions are kept.
+func (r *Router) Stop() {
+	r.mux.Lock()
+	if !r.isStarted {
+		r.mux.Unlock()
+		return
+	}
+	stopCh := r.stopCh
+	r.isStarted = false
+	r.mux.Unlock()
+
+	// transport invokes isRouted filter under its channel lock , so the channel must be unregistered without holding r.mux
+	r.transport.UnregisterChannel(r.channelId)
+	close(stopCh)
+}
+
+// MessageHandler returns MessageHandler , which can be set with MqttTransport.SetMessageHandler instead of starting the router.
+func (r *Router) MessageHandler() MessageHandler {
+	return func(topic string, addr *Address, iotMsg *FimpMessage, rawPayload []byte) {
+		r.ServeMessage(&Message{Topic: topic, Addr: addr, Payload: iotMsg, RawPayload: rawPayload})
+	}
+}
+
+func (r *Router) processMessages(inboundCh MessageCh, stopCh chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case msg := <-inboundCh:
+			r.ServeMessage(msg)
+		}
+	}
+}
+
+// isRouted is a transport filter , which accepts only messages matching one of the routes.
+func (r *Router) isRouted(topic string, _ *Address, msg *FimpMessage) bool {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	for i := range r.routes {
+		if r.routes[i].matches(topic, msg) {
+			return true
+		}
+	}
+	return r.notFound != nil
+}
+
+// ServeMessage dispatches message to the first matching handler and sends back the response.
+func (r *Router) ServeMessage(msg *Message) {
+	if msg == nil || msg.Payload == nil {
+		return
+	}
+	handler := r.handler(msg)
+	if handler == nil {
+		return
+	}
+	response := handler.ServeFimp(msg)
+	if response != nil {
+		r.respond(msg, response)
+	}
+}
+
+// handler returns handler of the first matching route wrapped with middlewares.
+func (r *Router) handler(msg *Message) Handler {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	handler := r.notFound
+	for i := range r.routes {
+		if r.routes[i].matches(msg.Topic, msg.Payload) {
+			handler = r.routes[i].handler
+			break
+		}
+	}
+	if handler == nil {
+		return nil
+	}
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+func (r *Router) respond(request *Message, response *FimpMessage) {
+	if r.transport == nil {
+		return
+	}
+	if response.CorrelationID == "" {
+		response.CorrelationID = request.Payload.UID
+	}
+	var err error
+	if request.Payload.ResponseToTopic != "" {
+		err = r.transport.RespondToRequest(request.Payload, response)
+	} else if request.Addr != nil {
+		addr := *request.Addr
+		addr.MsgType = MsgTypeEvt
+		err = r.transport.Publish(&addr, response)
+	}
+	if err != nil {
+		log.Errorf("<Router> Response to %s can't be sent. Error : %v", request.Payload.Type, err)
+	}
+}
+
+func (rt *route) matches(topic string, msg *FimpMessage) bool {
+	if !utils.RouteIncludesTopic(rt.topic, topic) {
+		return false
+	}
+	if msg == nil {
+		return false
+	}
+	return (rt.service == "" || rt.service == "*" || rt.service == msg.Service) &&
+		(rt.msgType == "" || rt.msgType == "*" || rt.msgType == msg.Type)
+}
+
+// LoggingMiddleware logs every routed message and time spent in the handler.
+func LoggingMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) *FimpMessage {
+			start := time.Now()
+			response := next.ServeFimp(msg)
+			log.Debugf("<Router> topic=%s type=%s serv=%s handled in %s", msg.Topic, msg.Payload.Type, msg.Payload.Service, time.Since(start))
+			return response
+		})
+	}
+}
+
+// RecoveryMiddleware recovers handler panics , so one faulty handler doesn't crash the application. No response is sent after panic.
+func RecoveryMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) (response *FimpMessage) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Errorf("<Router> Handler of %s CRASHED with error : %v", msg.Payload.Type, rec)
+					response = nil
+				}
+			}()
+			return next.ServeFimp(msg)
+		})
+	}
+}
+
+// AuthMiddleware passes message to the handler only if authorize returns true. Rejected messages are logged and dropped.
+func AuthMiddleware(authorize func(msg *Message) bool) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) *FimpMessage {
+			if !authorize(msg) {
+				log.Warnf("<Router> Message %s from %s is not authorized", msg.Payload.Type, msg.Payload.Source)
+				return nil
+			}
+			return next.ServeFimp(msg)
+		})
+	}
+}
+
+// MetricsMiddleware reports every handled message , time spent in the handler and the response to observe function.
+func MetricsMiddleware(observe func(msg *Message, duration time.Duration, response *FimpMessage)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) *FimpMessage {
+			start := time.Now()
+			response := next.ServeFimp(msg)
+			observe(msg, time.Since(start), response)
+			return response
+		})
+	}
+}
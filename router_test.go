@@ -0,0 +1,195 @@
+package fimpgo
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTransport records published messages and subscriptions.
+type recordingTransport struct {
+	mux       sync.Mutex
+	subs      []string
+	published map[string]*FimpMessage
+	channels  map[string]FilterFunc
+}
+
+func newRecordingTransport() *recordingTransport {
+	return &recordingTransport{published: make(map[string]*FimpMessage), channels: make(map[string]FilterFunc)}
+}
+
+func (t *recordingTransport) Publish(addr *Address, fimpMsg *FimpMessage) error {
+	return t.PublishToTopic(addr.Serialize(), fimpMsg)
+}
+
+func (t *recordingTransport) PublishToTopic(topic string, fimpMsg *FimpMessage) error {
+	t.mux.Lock()
+	t.published[topic] = fimpMsg
+	t.mux.Unlock()
+	return nil
+}
+
+func (t *recordingTransport) RespondToRequest(requestMsg *FimpMessage, responseMsg *FimpMessage) error {
+	return t.PublishToTopic(requestMsg.ResponseToTopic, responseMsg)
+}
+
+func (t *recordingTransport) Subscribe(topic string) error {
+	t.mux.Lock()
+	t.subs = append(t.subs, topic)
+	t.mux.Unlock()
+	return nil
+}
+
+func (t *recordingTransport) SubscribePattern(pattern *AddressPattern) error {
+	return t.Subscribe(pattern.Topic())
+}
+
+func (t *recordingTransport) Unsubscribe(string) error                                      { return nil }
+func (t *recordingTransport) UnsubscribePattern(*AddressPattern) error                      { return nil }
+func (t *recordingTransport) RegisterChannel(string, MessageCh)                             {}
+func (t *recordingTransport) SetGlobalTopicPrefix(string)                                   {}
+func (t *recordingTransport) UnregisterChannel(channelId string)                            { delete(t.channels, channelId) }
+func (t *recordingTransport) RegisterChannelWithFilter(string, MessageCh, FimpFilter)       {}
+func (t *recordingTransport) RegisterChannelWithPattern(string, MessageCh, *AddressPattern) {}
+func (t *recordingTransport) RegisterChannelWithFilterFunc(channelId string, _ MessageCh, filterFunc FilterFunc) {
+	t.channels[channelId] = filterFunc
+}
+
+func (t *recordingTransport) getPublished(topic string) *FimpMessage {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.published[topic]
+}
+
+func routerTestMessage(t *testing.T, topic string, msg *FimpMessage) *Message {
+	addr, err := NewAddressFromString(topic)
+	require.NoError(t, err)
+	return &Message{Topic: topic, Addr: addr, Payload: msg}
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	transport := newRecordingTransport()
+	router := NewRouter(transport)
+
+	var calls []string
+	router.HandleFunc("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/#", "out_bin_switch", "cmd.binary.set", func(msg *Message) *FimpMessage {
+		calls = append(calls, "set")
+		val, _ := msg.Payload.GetBoolValue()
+		return NewBoolMessage("evt.binary.report", "out_bin_switch", val, nil, nil, msg.Payload)
+	})
+	router.HandleFunc("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/#", "*", "cmd.binary.get_report", func(msg *Message) *FimpMessage {
+		calls = append(calls, "get_report")
+		return nil
+	})
+	router.Use(func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) *FimpMessage {
+			calls = append(calls, "mw1")
+			return next.ServeFimp(msg)
+		})
+	}, func(next Handler) Handler {
+		return HandlerFunc(func(msg *Message) *FimpMessage {
+			calls = append(calls, "mw2")
+			return next.ServeFimp(msg)
+		})
+	})
+	require.NoError(t, router.Start())
+	defer router.Stop()
+	assert.Equal(t, []string{"pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/#"}, transport.subs)
+
+	filter := transport.channels[router.channelId]
+	require.NotNil(t, filter)
+	assert.False(t, filter("pt:j1/mt:cmd/rt:dev/rn:zwave-ad/ad:1/sv:out_bin_switch/ad:1_0", nil, NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)))
+
+	// response is sent to mirrored evt topic
+	req := NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)
+	router.ServeMessage(routerTestMessage(t, "pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:2_0", req))
+	resp := transport.getPublished("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:2_0")
+	require.NotNil(t, resp)
+	assert.Equal(t, req.UID, resp.CorrelationID)
+
+	// response is sent to response topic of the request
+	req = NewBoolMessage("cmd.binary.set", "out_bin_switch", false, nil, nil, nil)
+	req.ResponseToTopic = "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+	router.ServeMessage(routerTestMessage(t, "pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:2_0", req))
+	require.NotNil(t, transport.getPublished(req.ResponseToTopic))
+
+	router.ServeMessage(routerTestMessage(t, "pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:2_0", NewNullMessage("cmd.binary.get_report", "out_bin_switch", nil, nil, nil)))
+	router.ServeMessage(routerTestMessage(t, "pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:2_0", NewNullMessage("cmd.unknown.cmd", "out_bin_switch", nil, nil, nil)))
+
+	assert.Equal(t, []string{"mw1", "mw2", "set", "mw1", "mw2", "set", "mw1", "mw2", "get_report"}, calls)
+}
+
+func TestRouter_Middlewares(t *testing.T) {
+	transport := newRecordingTransport()
+	router := NewRouter(transport)
+
+	var observed []string
+	router.Use(MetricsMiddleware(func(msg *Message, duration time.Duration, response *FimpMessage) {
+		observed = append(observed, msg.Payload.Type)
+	}), LoggingMiddleware(), RecoveryMiddleware(), AuthMiddleware(func(msg *Message) bool {
+		return msg.Payload.Source == "trusted"
+	}))
+	router.HandleFunc("pt:j1/mt:cmd/#", "", "", func(msg *Message) *FimpMessage {
+		if msg.Payload.Type == "cmd.test.panic" {
+			panic("handler failure")
+		}
+		return NewNullMessage("evt.test.report", "test", nil, nil, msg.Payload)
+	})
+
+	topic := "pt:j1/mt:cmd/rt:app/rn:test/ad:1"
+	untrusted := NewNullMessage("cmd.test.get_report", "test", nil, nil, nil)
+	router.ServeMessage(routerTestMessage(t, topic, untrusted))
+	assert.Nil(t, transport.getPublished("pt:j1/mt:evt/rt:app/rn:test/ad:1"))
+
+	panicking := NewNullMessage("cmd.test.panic", "test", nil, nil, nil)
+	panicking.Source = "trusted"
+	assert.NotPanics(t, func() { router.ServeMessage(routerTestMessage(t, topic, panicking)) })
+	assert.Nil(t, transport.getPublished("pt:j1/mt:evt/rt:app/rn:test/ad:1"))
+
+	trusted := NewNullMessage("cmd.test.get_report", "test", nil, nil, nil)
+	trusted.Source = "trusted"
+	router.ServeMessage(routerTestMessage(t, topic, trusted))
+	assert.NotNil(t, transport.getPublished("pt:j1/mt:evt/rt:app/rn:test/ad:1"))
+
+	assert.Equal(t, []string{"cmd.test.get_report", "cmd.test.panic", "cmd.test.get_report"}, observed)
+}
+
+func TestRouter_StopWhileDispatching(t *testing.T) {
+	mh := NewMqttTransportFromConnection(&recordingClient{}, 1, 1)
+	router := NewRouter(mh)
+	router.HandleFunc("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/#", "*", "*", func(msg *Message) *FimpMessage {
+		return nil
+	})
+	require.NoError(t, router.Start())
+
+	// the filter is invoked by transport while it's dispatching the message , Stop is started in the middle of filtering
+	stopped := make(chan struct{})
+	mh.RegisterChannelWithFilterFunc("probe", make(MessageCh, 1), func(topic string, addr *Address, msg *FimpMessage) bool {
+		go func() {
+			router.Stop()
+			close(stopped)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		return router.isRouted(topic, addr, msg)
+	})
+
+	payload, err := NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	dispatched := make(chan struct{})
+	go func() {
+		mh.handleIncomingMessage(&spilledMessage{topic: "pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:out_bin_switch/ad:1", payload: payload})
+		close(dispatched)
+	}()
+
+	for _, done := range []chan struct{}{dispatched, stopped} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("router Stop deadlocked with message dispatching")
+		}
+	}
+	mh.stopWorkers()
+}
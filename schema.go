@@ -0,0 +1,207 @@
+package fimpgo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/futurehomeno/fimpgo/fimptype"
+)
+
+// ValidationMode defines how schema violations are handled by the transport.
+type ValidationMode int
+
+const (
+	// ValidationStrict rejects outgoing messages with an error and drops incoming messages , which violate service schema.
+	ValidationStrict ValidationMode = iota
+	// ValidationWarn only logs schema violations , messages are delivered as usual.
+	ValidationWarn
+)
+
+// Interface direction declared in msg_t field of interface definition.
+const (
+	InterfaceMsgTypeIn  = "in"
+	InterfaceMsgTypeOut = "out"
+)
+
+func (m ValidationMode) String() string {
+	switch m {
+	case ValidationStrict:
+		return "strict"
+	case ValidationWarn:
+		return "warn"
+	default:
+		return fmt.Sprintf("ValidationMode(%d)", int(m))
+	}
+}
+
+// SchemaValidator checks FIMP messages against interfaces declared by services , for instance in inclusion reports.
+// Interfaces can be registered either for a service name , which applies to all service instances ,
+// or for a service address , which applies only to one service of one device and takes precedence over the name.
+// Messages of unknown services are not validated.
+type SchemaValidator struct {
+	mode      ValidationMode
+	mux       sync.RWMutex
+	services  map[string][]fimptype.Interface
+	addresses map[string][]fimptype.Interface
+}
+
+// NewSchemaValidator creates validator with given mode.
+func NewSchemaValidator(mode ValidationMode) *SchemaValidator {
+	return &SchemaValidator{
+		mode:      mode,
+		services:  make(map[string][]fimptype.Interface),
+		addresses: make(map[string][]fimptype.Interface),
+	}
+}
+
+// Mode returns validation mode.
+func (v *SchemaValidator) Mode() ValidationMode {
+	return v.mode
+}
+
+// SetServiceInterfaces sets interfaces of all services with given name.
+func (v *SchemaValidator) SetServiceInterfaces(service string, interfaces []fimptype.Interface) {
+	v.mux.Lock()
+	v.services[service] = interfaces
+	v.mux.Unlock()
+}
+
+// AddService registers service definition. If service address is set , interfaces apply only to that address ,
+// otherwise to all services with the same name.
+func (v *SchemaValidator) AddService(service fimptype.Service) {
+	key := normalizeServiceAddress(service.Address)
+	if key == "" {
+		v.SetServiceInterfaces(service.Name, service.Interfaces)
+		return
+	}
+	v.mux.Lock()
+	v.addresses[key] = service.Interfaces
+	v.mux.Unlock()
+}
+
+// AddInclusionReport registers all services of the thing.
+func (v *SchemaValidator) AddInclusionReport(report *fimptype.ThingInclusionReport) {
+	for _, s := range report.Services {
+		v.AddService(s)
+	}
+}
+
+// RemoveService removes definitions registered for service name and service address.
+func (v *SchemaValidator) RemoveService(service fimptype.Service) {
+	v.mux.Lock()
+	if key := normalizeServiceAddress(service.Address); key != "" {
+		delete(v.addresses, key)
+	} else {
+		delete(v.services, service.Name)
+	}
+	v.mux.Unlock()
+}
+
+// Validate checks that interface type , direction and value type of the message match service definition.
+// Address is optional , without it validation falls back to service name and direction is not checked.
+// Returned error can be checked with IsSchemaViolation.
+func (v *SchemaValidator) Validate(addr *Address, msg *FimpMessage) error {
+	if msg == nil {
+		return nil
+	}
+	interfaces, service, ok := v.lookup(addr, msg)
+	if !ok {
+		return nil
+	}
+
+	direction := ""
+	if addr != nil {
+		switch addr.MsgType {
+		case MsgTypeCmd:
+			direction = InterfaceMsgTypeIn
+		case MsgTypeEvt:
+			direction = InterfaceMsgTypeOut
+		}
+	}
+
+	var declared []fimptype.Interface
+	for _, intf := range interfaces {
+		if intf.Type == msg.Type {
+			declared = append(declared, intf)
+		}
+	}
+	if len(declared) == 0 {
+		return fmt.Errorf("%w: interface %s is not declared by service %s", errSchemaViolation, msg.Type, service)
+	}
+
+	var valueTypes []string
+	for _, intf := range declared {
+		if direction != "" && intf.MsgType != "" && intf.MsgType != direction {
+			continue
+		}
+		if intf.ValueType == "" || intf.ValueType == msg.ValueType {
+			return nil
+		}
+		valueTypes = append(valueTypes, intf.ValueType)
+	}
+	if len(valueTypes) == 0 {
+		return fmt.Errorf("%w: interface %s of service %s is not declared with msg_t=%s", errSchemaViolation, msg.Type, service, direction)
+	}
+	return fmt.Errorf("%w: interface %s of service %s expects val_t %s , got %s", errSchemaViolation, msg.Type, service, strings.Join(valueTypes, "|"), msg.ValueType)
+}
+
+// lookup returns interfaces declared for the service address or , if there is none , for the service name.
+func (v *SchemaValidator) lookup(addr *Address, msg *FimpMessage) ([]fimptype.Interface, string, bool) {
+	v.mux.RLock()
+	defer v.mux.RUnlock()
+	if addr != nil && addr.ResourceType == ResourceTypeDevice {
+		if interfaces, ok := v.addresses[serviceAddressKey(addr)]; ok {
+			return interfaces, addr.ServiceName, true
+		}
+	}
+	service := msg.Service
+	if service == "" && addr != nil {
+		service = addr.ServiceName
+	}
+	interfaces, ok := v.services[service]
+	return interfaces, service, ok
+}
+
+// serviceAddressKey returns service address in the format used by service definitions , without leading slash.
+func serviceAddressKey(addr *Address) string {
+	return fmt.Sprintf("rt:%s/rn:%s/ad:%s/sv:%s/ad:%s", addr.ResourceType, addr.ResourceName, addr.ResourceAddress, addr.ServiceName, addr.ServiceAddress)
+}
+
+// normalizeServiceAddress removes leading slash , payload type and message type from service address.
+func normalizeServiceAddress(address string) string {
+	address = strings.Trim(address, "/")
+	for _, prefix := range []string{"pt:", "mt:"} {
+		if strings.HasPrefix(address, prefix) {
+			if i := strings.Index(address, "/"); i >= 0 {
+				address = address[i+1:]
+			}
+		}
+	}
+	return address
+}
+
+// SetSchemaValidator sets validator used to check published and received messages , nil disables validation.
+func (mh *MqttTransport) SetSchemaValidator(validator *SchemaValidator) {
+	mh.schemaMux.Lock()
+	mh.schemaValidator = validator
+	mh.schemaMux.Unlock()
+}
+
+// validateSchema returns schema violation error only in strict mode , in warn mode violation is logged.
+func (mh *MqttTransport) validateSchema(addr *Address, msg *FimpMessage) error {
+	mh.schemaMux.RLock()
+	validator := mh.schemaValidator
+	mh.schemaMux.RUnlock()
+	if validator == nil {
+		return nil
+	}
+	err := validator.Validate(addr, msg)
+	if err != nil && validator.Mode() == ValidationWarn {
+		log.Warnf("<MqttAd> %v", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package fimpgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo/fimptype"
+)
+
+var sensorTempInterfaces = []fimptype.Interface{
+	{Type: "evt.sensor.report", MsgType: InterfaceMsgTypeOut, ValueType: VTypeFloat, Version: "1"},
+	{Type: "cmd.sensor.get_report", MsgType: InterfaceMsgTypeIn, ValueType: VTypeNull, Version: "1"},
+}
+
+func TestSchemaValidator_Validate(t *testing.T) {
+	v := NewSchemaValidator(ValidationStrict)
+	v.SetServiceInterfaces("sensor_temp", sensorTempInterfaces)
+	v.AddService(fimptype.Service{
+		Name:    "sensor_temp",
+		Address: "/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:2_1",
+		Interfaces: []fimptype.Interface{
+			{Type: "evt.sensor.report", MsgType: "out", ValueType: VTypeInt},
+		},
+	})
+
+	evtAddr, err := NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1")
+	require.NoError(t, err)
+	cmdAddr, err := NewAddressFromString("pt:j1/mt:cmd/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1")
+	require.NoError(t, err)
+	devAddr, err := NewAddressFromString("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:2_1")
+	require.NoError(t, err)
+
+	tcs := []struct {
+		name  string
+		addr  *Address
+		msg   *FimpMessage
+		valid bool
+	}{
+		{
+			name:  "valid event",
+			addr:  evtAddr,
+			msg:   NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil),
+			valid: true,
+		},
+		{
+			name: "wrong value type",
+			addr: evtAddr,
+			msg:  NewStringMessage("evt.sensor.report", "sensor_temp", "21.5", nil, nil, nil),
+		},
+		{
+			name: "undeclared interface",
+			addr: evtAddr,
+			msg:  NewFloatMessage("evt.sensor.unknown", "sensor_temp", 21.5, nil, nil, nil),
+		},
+		{
+			name: "wrong direction",
+			addr: cmdAddr,
+			msg:  NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil),
+		},
+		{
+			name:  "valid command",
+			addr:  cmdAddr,
+			msg:   NewNullMessage("cmd.sensor.get_report", "sensor_temp", nil, nil, nil),
+			valid: true,
+		},
+		{
+			name:  "without address",
+			msg:   NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil),
+			valid: true,
+		},
+		{
+			name:  "address specific definition",
+			addr:  devAddr,
+			msg:   NewIntMessage("evt.sensor.report", "sensor_temp", 21, nil, nil, nil),
+			valid: true,
+		},
+		{
+			name: "address specific definition overrides service name",
+			addr: devAddr,
+			msg:  NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil),
+		},
+		{
+			name:  "unknown service",
+			addr:  evtAddr,
+			msg:   NewStringMessage("evt.sensor.report", "sensor_lumin", "x", nil, nil, nil),
+			valid: true,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := v.Validate(tc.addr, tc.msg)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, IsSchemaViolation(err), "expected schema violation , got %v", err)
+			}
+		})
+	}
+}
+
+func TestMqttTransport_SchemaValidation(t *testing.T) {
+	v := NewSchemaValidator(ValidationStrict)
+	v.SetServiceInterfaces("sensor_temp", sensorTempInterfaces)
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{}, WithSchemaValidator(v))
+	defer mh.stopWorkers()
+
+	addr := &Address{MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "test", ResourceAddress: "1", ServiceName: "sensor_temp", ServiceAddress: "1"}
+	err := mh.Publish(addr, NewStringMessage("evt.sensor.report", "sensor_temp", "21.5", nil, nil, nil))
+	assert.True(t, IsSchemaViolation(err))
+	err = mh.PublishToTopic(addr.Serialize(), NewStringMessage("evt.sensor.report", "sensor_temp", "21.5", nil, nil, nil))
+	assert.True(t, IsSchemaViolation(err))
+
+	ch := make(MessageCh, 10)
+	mh.RegisterChannel("test", ch)
+	for _, msg := range []*FimpMessage{
+		NewStringMessage("evt.sensor.report", "sensor_temp", "invalid", nil, nil, nil),
+		NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil),
+	} {
+		payload, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: addr.Serialize(), payload: payload})
+	}
+	select {
+	case msg := <-ch:
+		assert.Equal(t, VTypeFloat, msg.Payload.ValueType)
+	case <-time.After(time.Second):
+		t.Fatal("valid message was not delivered")
+	}
+
+	// in warn mode invalid messages are delivered
+	warn := NewSchemaValidator(ValidationWarn)
+	warn.SetServiceInterfaces("sensor_temp", sensorTempInterfaces)
+	mh.SetSchemaValidator(warn)
+	payload, err := NewStringMessage("evt.sensor.report", "sensor_temp", "invalid", nil, nil, nil).SerializeToJson()
+	require.NoError(t, err)
+	mh.handleIncomingMessage(&spilledMessage{topic: addr.Serialize(), payload: payload})
+	select {
+	case msg := <-ch:
+		assert.Equal(t, VTypeString, msg.Payload.ValueType)
+	case <-time.After(time.Second):
+		t.Fatal("invalid message was not delivered in warn mode")
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"math/big"
@@ -37,6 +39,24 @@ func (kp *EcdsaKey) PrivateKey() *ecdsa.PrivateKey {
 	return kp.privateKey
 }
 
+// KeyId returns identifier of the key pair , which is base64url encoded first 16 bytes of SHA-256 hash of the public key in PKIX format.
+// It's computed from private key if public key is not set.
+func (kp *EcdsaKey) KeyId() string {
+	pub := kp.publicKey
+	if pub == nil && kp.privateKey != nil {
+		pub = &kp.privateKey.PublicKey
+	}
+	if pub == nil {
+		return ""
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(hash[:16])
+}
+
 func NewEcdsaKey() *EcdsaKey {
 	return &EcdsaKey{}
 }
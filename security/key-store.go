@@ -120,6 +120,16 @@ func (cs *KeyStore) GetEcdsaKey(userId, deviceId ,keyType string) (*EcdsaKey,err
 	return nil,fmt.Errorf("key not found")
 }
 
+// GetVerificationKey returns public or symmetric key of the algorithm , which is used to verify messages signed by user on given device.
+func (cs *KeyStore) GetVerificationKey(userId, deviceId, algorithm string) *KeyRecord {
+	for i := range cs.keyStore {
+		if cs.keyStore[i].DeviceId == deviceId && cs.keyStore[i].UserId == userId && cs.keyStore[i].Algorithm == algorithm &&
+			(cs.keyStore[i].KeyType == KeyTypePublic || cs.keyStore[i].KeyType == KeyTypeSymmetric) {
+			return &cs.keyStore[i]
+		}
+	}
+	return nil
+}
 
 //
 func (cs *KeyStore) GetAllUserKeys(userId string) []KeyRecord {
@@ -159,11 +169,3 @@ func (cs *KeyStore) LoadFromDisk() error {
 	}
 	return nil
 }
-
-// An app should call the method to authenticate message
-//func (cs *KeyStore) IsMessageAuthenticated(msg *fimpgo.FimpMessage) (bool) {
-//	//1. Extract username and signature from user message
-//	//2. Query public key from local key store
-//	//3. Validate signature using public key
-//
-//}
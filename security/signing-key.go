@@ -0,0 +1,166 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// Signing algorithms , names follow JWA (RFC 7518 and RFC 8037).
+const (
+	AlgEcdsa384 = "ES384"
+	AlgEd25519  = "EdDSA"
+	AlgHmac256  = "HS256"
+	AlgNone     = "none"
+)
+
+// SigningKey returns key used to sign messages with the record algorithm.
+// It's *ecdsa.PrivateKey for ES256 and ES384 , ed25519.PrivateKey for EdDSA and []byte secret for HS256.
+func (kr *KeyRecord) SigningKey() (interface{}, error) {
+	if kr.Algorithm == AlgHmac256 {
+		return kr.secret()
+	}
+	if kr.KeyType != KeyTypePrivate {
+		return nil, fmt.Errorf("%s key can't be used for signing", kr.KeyType)
+	}
+	switch kr.Algorithm {
+	case AlgEcdsa256, AlgEcdsa384:
+		key, err := kr.ecdsaPrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		if err := kr.checkCurve(key.Curve); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case AlgEd25519:
+		der, err := pemBytes(kr.SerializedKey)
+		if err != nil {
+			return nil, err
+		}
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("key is not ed25519 private key")
+		}
+		return edKey, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", kr.Algorithm)
+}
+
+// VerificationKey returns key used to verify message signatures. Public key is derived from private key if the record has private key.
+// It's *ecdsa.PublicKey for ES256 and ES384 , ed25519.PublicKey for EdDSA and []byte secret for HS256.
+func (kr *KeyRecord) VerificationKey() (interface{}, error) {
+	if kr.Algorithm == AlgHmac256 {
+		return kr.secret()
+	}
+	if kr.KeyType == KeyTypePrivate {
+		key, err := kr.SigningKey()
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			return &k.PublicKey, nil
+		case ed25519.PrivateKey:
+			return k.Public(), nil
+		}
+	}
+	if kr.KeyType != KeyTypePublic {
+		return nil, fmt.Errorf("unknown key type %s", kr.KeyType)
+	}
+
+	switch kr.Algorithm {
+	case AlgEcdsa256, AlgEcdsa384, AlgEd25519:
+		var key interface{}
+		if kr.Algorithm != AlgEd25519 && kr.EcdsaKey != nil && kr.EcdsaKey.PublicKey() != nil {
+			key = kr.EcdsaKey.PublicKey()
+		} else {
+			der, err := pemBytes(kr.SerializedKey)
+			if err != nil {
+				return nil, err
+			}
+			if key, err = x509.ParsePKIXPublicKey(der); err != nil {
+				return nil, err
+			}
+		}
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			if kr.Algorithm != AlgEd25519 {
+				if err := kr.checkCurve(k.Curve); err != nil {
+					return nil, err
+				}
+				return k, nil
+			}
+		case ed25519.PublicKey:
+			if kr.Algorithm == AlgEd25519 {
+				return k, nil
+			}
+		}
+		return nil, fmt.Errorf("key type %T doesn't match algorithm %s", key, kr.Algorithm)
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", kr.Algorithm)
+}
+
+// ecdsaPrivateKey returns cached or parsed private key. Both SEC 1 and PKCS #8 encodings are supported.
+func (kr *KeyRecord) ecdsaPrivateKey() (*ecdsa.PrivateKey, error) {
+	if kr.EcdsaKey != nil && kr.EcdsaKey.PrivateKey() != nil {
+		return kr.EcdsaKey.PrivateKey(), nil
+	}
+	der, err := pemBytes(kr.SerializedKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(der)
+	if err == nil {
+		return key, nil
+	}
+	if pkcs8, pkcs8Err := x509.ParsePKCS8PrivateKey(der); pkcs8Err == nil {
+		if key, ok := pkcs8.(*ecdsa.PrivateKey); ok {
+			return key, nil
+		}
+	}
+	return nil, err
+}
+
+// secret returns HMAC secret , which is stored base64 encoded in symmetric key record.
+func (kr *KeyRecord) secret() ([]byte, error) {
+	if kr.KeyType != KeyTypeSymmetric {
+		return nil, fmt.Errorf("%s requires %s key , got %s", kr.Algorithm, KeyTypeSymmetric, kr.KeyType)
+	}
+	secret, err := base64.StdEncoding.DecodeString(kr.SerializedKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) < 32 {
+		return nil, errors.New("symmetric key must be at least 32 bytes long")
+	}
+	return secret, nil
+}
+
+func (kr *KeyRecord) checkCurve(curve elliptic.Curve) error {
+	expected := elliptic.P256()
+	if kr.Algorithm == AlgEcdsa384 {
+		expected = elliptic.P384()
+	}
+	if curve != expected {
+		return fmt.Errorf("curve %s doesn't match algorithm %s", curve.Params().Name, kr.Algorithm)
+	}
+	return nil
+}
+
+func pemBytes(serializedKey string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(serializedKey))
+	if block == nil {
+		return nil, errors.New("incorrect PEM format")
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,100 @@
+package fimpgo
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Signed transport messages , which encapsulate original message.
+const (
+	SignedCommandType = "cmd.transport.signed"
+	SignedEventType   = "evt.transport.signed"
+)
+
+// SignatureVerifier verifies signature of signed transport message and returns encapsulated message together with identity of the signer.
+// transport.NewKeyStoreVerifier implements verification using keys of security.KeyStore .
+type SignatureVerifier interface {
+	VerifySignedMessage(signedMsg *FimpMessage) (*FimpMessage, *Authentication, error)
+}
+
+// Authentication is identity of the signer of verified message.
+type Authentication struct {
+	UserId    string
+	DeviceId  string
+	Algorithm string
+}
+
+// SignaturePolicy defines which received messages must be signed.
+type SignaturePolicy struct {
+	// RequireSignedCommands is list of services (for instance door_lock , alarm_fire) , unsigned commands to which are dropped. "*" matches all services.
+	RequireSignedCommands []string
+}
+
+// requiresSignature returns true if unsigned message must be rejected.
+// msg is nil if payload can't be decoded , such message is verified by its address only.
+func (p *SignaturePolicy) requiresSignature(addr *Address, msg *FimpMessage) bool {
+	if p == nil {
+		return false
+	}
+	if msg == nil {
+		if addr.MsgType != MsgTypeCmd {
+			return false
+		}
+	} else if !strings.HasPrefix(msg.Type, "cmd.") {
+		return false
+	}
+	for _, service := range p.RequireSignedCommands {
+		if service == "*" || service == addr.ServiceName || (msg != nil && service == msg.Service) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSignedMessage returns true if message is signed transport message.
+func IsSignedMessage(msg *FimpMessage) bool {
+	return msg.Type == SignedCommandType || msg.Type == SignedEventType
+}
+
+// SetSignatureVerifier enables unwrapping of received signed messages. Signed messages are delivered as encapsulated message with Message.Auth set ,
+// messages which fail verification are dropped. Unsigned messages are rejected according to the policy. nil verifier delivers signed messages as they are.
+func (mh *MqttTransport) SetSignatureVerifier(verifier SignatureVerifier, policy *SignaturePolicy) {
+	mh.authMux.Lock()
+	mh.signatureVerifier = verifier
+	mh.signaturePolicy = policy
+	mh.authMux.Unlock()
+}
+
+// authenticate unwraps and verifies signed message and applies signature policy to unsigned messages.
+func (mh *MqttTransport) authenticate(addr *Address, msg *FimpMessage) (*FimpMessage, *Authentication, error) {
+	mh.authMux.RLock()
+	verifier := mh.signatureVerifier
+	policy := mh.signaturePolicy
+	mh.authMux.RUnlock()
+
+	if verifier != nil && msg != nil && IsSignedMessage(msg) {
+		inner, auth, err := verifier.VerifySignedMessage(msg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", errUnauthenticated, err)
+		}
+		if auth == nil {
+			auth = &Authentication{}
+		}
+		// signature doesn't cover the topic , so verified message can't be redirected to other service
+		if addr.ServiceName != "" && inner.Service != addr.ServiceName {
+			return nil, nil, fmt.Errorf("%w: signed message service %s doesn't match topic service %s", errUnauthenticated, inner.Service, addr.ServiceName)
+		}
+		log.Tracef("<MqttAd> Verified message %s signed by user=%s device=%s", inner.Type, auth.UserId, auth.DeviceId)
+		return inner, auth, nil
+	}
+
+	if policy.requiresSignature(addr, msg) {
+		if msg == nil {
+			return nil, nil, fmt.Errorf("%w: command with %s payload to service %s can't be verified", errUnauthenticated, addr.PayloadType, addr.ServiceName)
+		}
+		return nil, nil, fmt.Errorf("%w: unsigned command %s to service %s", errUnauthenticated, msg.Type, msg.Service)
+	}
+	return msg, nil, nil
+}
@@ -0,0 +1,130 @@
+package fimpgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVerifier accepts signed messages with sig=valid property.
+type fakeVerifier struct{}
+
+func (fakeVerifier) VerifySignedMessage(signedMsg *FimpMessage) (*FimpMessage, *Authentication, error) {
+	if signedMsg.Properties["sig"] != "valid" {
+		return nil, nil, errors.New("bad signature")
+	}
+	bin, err := base64.StdEncoding.DecodeString(signedMsg.Value.(string))
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := NewMessageFromBytes(bin)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, &Authentication{UserId: signedMsg.Properties["user_id"], Algorithm: "ES256"}, nil
+}
+
+func signedTestMessage(t *testing.T, msg *FimpMessage, sig string) *FimpMessage {
+	bin, err := msg.SerializeToJson()
+	require.NoError(t, err)
+	return NewBinaryMessage(SignedCommandType, msg.Service, bin, Props{"user_id": "alex@gmail.com", "sig": sig}, nil, nil)
+}
+
+func TestMqttTransport_SignatureVerification(t *testing.T) {
+	policy := &SignaturePolicy{RequireSignedCommands: []string{"door_lock"}}
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{}, WithSignatureVerifier(fakeVerifier{}, policy))
+	defer mh.stopWorkers()
+
+	ch := make(MessageCh, 10)
+	mh.RegisterChannel("test", ch)
+	lockAddr := &Address{MsgType: MsgTypeCmd, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "door_lock", ServiceAddress: "1"}
+	lightAddr := &Address{MsgType: MsgTypeCmd, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "out_bin_switch", ServiceAddress: "1"}
+	lockCmd := NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil)
+
+	receive := func(addr *Address, msg *FimpMessage) *Message {
+		payload, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: addr.Serialize(), payload: payload})
+		select {
+		case m := <-ch:
+			return m
+		case <-time.After(100 * time.Millisecond):
+			return nil
+		}
+	}
+
+	delivered := receive(lockAddr, signedTestMessage(t, lockCmd, "valid"))
+	require.NotNil(t, delivered)
+	assert.Equal(t, "cmd.lock.set", delivered.Payload.Type)
+	assert.True(t, delivered.IsAuthenticated())
+	assert.Equal(t, "alex@gmail.com", delivered.Auth.UserId)
+
+	assert.Nil(t, receive(lockAddr, signedTestMessage(t, lockCmd, "invalid")), "invalid signature")
+	assert.Nil(t, receive(lockAddr, lockCmd), "unsigned command to protected service")
+	assert.Nil(t, receive(lightAddr, signedTestMessage(t, lockCmd, "valid")), "signed message on topic of other service")
+
+	delivered = receive(lightAddr, NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil))
+	require.NotNil(t, delivered)
+	assert.False(t, delivered.IsAuthenticated())
+
+	lockEvt := &Address{MsgType: MsgTypeEvt, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "door_lock", ServiceAddress: "1"}
+	delivered = receive(lockEvt, NewBoolMessage("evt.lock.report", "door_lock", true, nil, nil, nil))
+	require.NotNil(t, delivered, "policy applies only to commands")
+
+	// without verifier signed messages are delivered as they are
+	mh.SetSignatureVerifier(nil, nil)
+	delivered = receive(lockAddr, signedTestMessage(t, lockCmd, "invalid"))
+	require.NotNil(t, delivered)
+	assert.Equal(t, SignedCommandType, delivered.Payload.Type)
+	assert.False(t, delivered.IsAuthenticated())
+}
+
+func TestMqttTransport_SignedMessageHandler(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{}, WithSignatureVerifier(fakeVerifier{}, nil))
+	defer mh.stopWorkers()
+
+	type received struct {
+		msg *FimpMessage
+		raw []byte
+	}
+	handlerCh := make(chan received, 1)
+	mh.SetMessageHandler(func(topic string, addr *Address, iotMsg *FimpMessage, rawPayload []byte) {
+		handlerCh <- received{msg: iotMsg, raw: rawPayload}
+	})
+
+	lockAddr := &Address{MsgType: MsgTypeCmd, ResourceType: ResourceTypeDevice, ResourceName: "zw", ResourceAddress: "1", ServiceName: "door_lock", ServiceAddress: "1"}
+	payload, err := signedTestMessage(t, NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil), "valid").SerializeToJson()
+	require.NoError(t, err)
+	mh.handleIncomingMessage(&spilledMessage{topic: lockAddr.Serialize(), payload: payload})
+
+	select {
+	case r := <-handlerCh:
+		// handler gets the inner message and its raw payload , not the signed envelope
+		assert.Equal(t, "cmd.lock.set", r.msg.Type)
+		raw, err := NewMessageFromBytes(r.raw)
+		require.NoError(t, err)
+		assert.Equal(t, "cmd.lock.set", raw.Type)
+	case <-time.After(time.Second):
+		t.Fatal("message handler was not invoked")
+	}
+}
+
+func TestSignaturePolicy_RequiresSignature(t *testing.T) {
+	addr := &Address{ServiceName: "alarm_fire"}
+	all := &SignaturePolicy{RequireSignedCommands: []string{"*"}}
+	assert.True(t, all.requiresSignature(addr, NewNullMessage("cmd.alarm.clear", "alarm_fire", nil, nil, nil)))
+	assert.False(t, all.requiresSignature(addr, NewNullMessage("evt.alarm.report", "alarm_fire", nil, nil, nil)))
+
+	var none *SignaturePolicy
+	assert.False(t, none.requiresSignature(addr, NewNullMessage("cmd.alarm.clear", "alarm_fire", nil, nil, nil)))
+
+	// service of topic is checked as well as service of the message
+	alarm := &SignaturePolicy{RequireSignedCommands: []string{"alarm_fire"}}
+	assert.True(t, alarm.requiresSignature(addr, NewNullMessage("cmd.alarm.clear", "other", nil, nil, nil)))
+	_, _, err := (&MqttTransport{signaturePolicy: alarm}).authenticate(addr, NewNullMessage("cmd.alarm.clear", "other", nil, nil, nil))
+	assert.True(t, IsUnauthenticated(err))
+}
@@ -0,0 +1,135 @@
+package fimpgo
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var subscriptionSeq uint64
+
+// Subscription is a handle of topic subscription. Identical topic filters are reference counted ,
+// broker subscription is removed only after the last handle is closed , so components sharing a topic don't interfere.
+// Channels and handlers bound to the subscription receive messages published to its topic and are unregistered by Close.
+type Subscription struct {
+	mh    *MqttTransport
+	topic string // topic filter as requested
+	key   string // broker topic filter including global prefix
+
+	mux        sync.Mutex
+	channelIds []string
+	closed     bool
+}
+
+// NewSubscription subscribes for topic and returns subscription handle , which must be closed once the topic is not needed.
+func (mh *MqttTransport) NewSubscription(topic string) (*Subscription, error) {
+	if strings.TrimSpace(topic) == "" {
+		return nil, fmt.Errorf("%w: empty topic", errSubscribe)
+	}
+
+	mh.subMutex.Lock()
+	defer mh.subMutex.Unlock()
+
+	key := mh.subscriptionTopic(topic)
+	if _, ok := mh.subs[key]; !ok {
+		if err := mh.subscribeBroker(key); err != nil {
+			return nil, fmt.Errorf("%w: %w", errSubscribe, err)
+		}
+	}
+	sub := &Subscription{mh: mh, topic: topic, key: key}
+	if mh.subRefs[key] == nil {
+		mh.subRefs[key] = make(map[*Subscription]struct{})
+	}
+	mh.subRefs[key][sub] = struct{}{}
+	return sub, nil
+}
+
+// SubscribeHandle is NewSubscription implementing HandleSubscriber.
+func (mh *MqttTransport) SubscribeHandle(topic string) (SubscriptionHandle, error) {
+	sub, err := mh.NewSubscription(topic)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// releaseSubscription removes the handle and unsubscribes if the topic isn't used anymore.
+func (mh *MqttTransport) releaseSubscription(sub *Subscription) error {
+	mh.subMutex.Lock()
+	defer mh.subMutex.Unlock()
+	handles := mh.subRefs[sub.key]
+	if _, ok := handles[sub]; !ok {
+		// already removed by UnsubscribeAll , handles created later must not be affected
+		return nil
+	}
+	delete(handles, sub)
+	if len(handles) > 0 {
+		return nil
+	}
+	delete(mh.subRefs, sub.key)
+	if _, ok := mh.legacySubs[sub.key]; ok {
+		return nil
+	}
+	return mh.unsubscribeBroker(sub.key)
+}
+
+// Topic returns topic filter of the subscription.
+func (s *Subscription) Topic() string {
+	return s.topic
+}
+
+// BindChannel registers channel , which receives all messages matching subscription topic , and returns its channel id.
+// The id can be used to set overflow policy of the channel. Nothing is registered if subscription is closed.
+func (s *Subscription) BindChannel(ch MessageCh) string {
+	return s.bind(func(id string, filter FimpFilter) {
+		s.mh.RegisterChannelWithFilter(id, ch, filter)
+	})
+}
+
+// BindHandler registers handler , which is invoked from its own delivery worker for all messages matching subscription topic , and returns its channel id.
+func (s *Subscription) BindHandler(handler func(msg *Message)) string {
+	return s.bind(func(id string, filter FimpFilter) {
+		s.mh.registerWithFilter(id, nil, func(msg *Message, _ <-chan struct{}) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("<MqttAd> Subscription handler CRASHED with error :", r)
+				}
+			}()
+			handler(msg)
+		}, filter)
+	})
+}
+
+func (s *Subscription) bind(register func(id string, filter FimpFilter)) string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.closed {
+		return ""
+	}
+	id := fmt.Sprintf("$subscription-%d", atomic.AddUint64(&subscriptionSeq, 1))
+	// inbound topics don't contain global prefix and shared subscription group
+	register(id, FimpFilter{Topic: stripSharedTopic(s.topic), Service: "*", Interface: "*"})
+	s.channelIds = append(s.channelIds, id)
+	return id
+}
+
+// Close unregisters bound channels and handlers and releases the subscription. Close can be invoked multiple times.
+func (s *Subscription) Close() error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return nil
+	}
+	s.closed = true
+	channelIds := s.channelIds
+	s.channelIds = nil
+	s.mux.Unlock()
+
+	for _, id := range channelIds {
+		s.mh.UnregisterChannel(id)
+	}
+	return s.mh.releaseSubscription(s)
+}
@@ -0,0 +1,180 @@
+package fimpgo
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingClient records subscribe and unsubscribe requests sent to the broker.
+type recordingClient struct {
+	MQTT.Client
+	mux          sync.Mutex
+	subscribed   []string
+	unsubscribed []string
+}
+
+func (c *recordingClient) Subscribe(topic string, _ byte, _ MQTT.MessageHandler) MQTT.Token {
+	c.mux.Lock()
+	c.subscribed = append(c.subscribed, topic)
+	c.mux.Unlock()
+	return completedToken()
+}
+
+func (c *recordingClient) Unsubscribe(topics ...string) MQTT.Token {
+	c.mux.Lock()
+	c.unsubscribed = append(c.unsubscribed, topics...)
+	c.mux.Unlock()
+	return completedToken()
+}
+
+func (c *recordingClient) SubscribeMultiple(filters map[string]byte, _ MQTT.MessageHandler) MQTT.Token {
+	c.mux.Lock()
+	for topic := range filters {
+		c.subscribed = append(c.subscribed, topic)
+	}
+	c.mux.Unlock()
+	return completedToken()
+}
+
+func (c *recordingClient) IsConnectionOpen() bool {
+	return true
+}
+
+func completedToken() MQTT.Token {
+	token := newMqttToken()
+	token.complete(nil)
+	return token
+}
+
+func TestSubscription_RefCount(t *testing.T) {
+	client := &recordingClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	topic := "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+
+	sub1, err := mh.NewSubscription(topic)
+	require.NoError(t, err)
+	sub2, err := mh.NewSubscription(topic)
+	require.NoError(t, err)
+	assert.Equal(t, topic, sub2.Topic())
+	assert.Equal(t, []string{topic}, client.subscribed, "identical topic must be subscribed once")
+
+	require.NoError(t, sub1.Close())
+	require.NoError(t, sub1.Close())
+	assert.Empty(t, client.unsubscribed, "topic is still used by the second subscription")
+
+	require.NoError(t, sub2.Close())
+	assert.Equal(t, []string{topic}, client.unsubscribed)
+	assert.Empty(t, mh.subRefs)
+
+	_, err = mh.NewSubscription(" ")
+	assert.True(t, IsSubscribeFailed(err))
+}
+
+func TestSubscription_LegacySubscribe(t *testing.T) {
+	client := &recordingClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	topic := "pt:j1/mt:evt/rt:dev/rn:zigbee/#"
+
+	require.NoError(t, mh.Subscribe(topic))
+	sub, err := mh.NewSubscription(topic)
+	require.NoError(t, err)
+	assert.Len(t, client.subscribed, 1)
+
+	// handle doesn't remove subscription made by Subscribe
+	require.NoError(t, sub.Close())
+	assert.Empty(t, client.unsubscribed)
+
+	sub, err = mh.NewSubscription(topic)
+	require.NoError(t, err)
+	// Unsubscribe doesn't remove subscription used by open handle
+	require.NoError(t, mh.Unsubscribe(topic))
+	assert.Empty(t, client.unsubscribed)
+	require.NoError(t, sub.Close())
+	assert.Equal(t, []string{topic}, client.unsubscribed)
+}
+
+func TestSubscription_Bind(t *testing.T) {
+	mh := NewMqttTransportFromConnection(&recordingClient{}, 1, 1)
+	defer mh.stopWorkers()
+
+	sub, err := mh.NewSubscription("pt:j1/mt:evt/rt:dev/rn:zigbee/+/sv:sensor_temp/#")
+	require.NoError(t, err)
+
+	ch := make(MessageCh, 10)
+	sub.BindChannel(ch)
+	handled := make(chan *Message, 10)
+	sub.BindHandler(func(msg *Message) {
+		handled <- msg
+	})
+
+	deliver := func(topic string) {
+		payload, err := NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil).SerializeToJson()
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: topic, payload: payload})
+	}
+	deliver("pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:sensor_temp/ad:1")
+	deliver("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1")
+
+	for _, received := range []chan *Message{ch, handled} {
+		select {
+		case msg := <-received:
+			assert.Equal(t, "zigbee", msg.Addr.ResourceName)
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+	}
+
+	require.NoError(t, sub.Close())
+	assert.Empty(t, mh.subIndex.filters, "bound channels must be unregistered")
+	assert.Empty(t, sub.BindChannel(ch), "closed subscription can't be bound")
+
+	deliver("pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1")
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected message %v", msg.Payload)
+	case msg := <-handled:
+		t.Fatalf("unexpected message %v", msg.Payload)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscription_LegacySubscribeIdempotent(t *testing.T) {
+	client := &recordingClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	topic := "pt:j1/mt:evt/rt:app/rn:test/ad:1"
+
+	// repeated Subscribe , for instance after reconnect , is released by single Unsubscribe
+	require.NoError(t, mh.Subscribe(topic))
+	require.NoError(t, mh.Subscribe(topic))
+	require.NoError(t, mh.Unsubscribe(topic))
+	assert.Equal(t, []string{topic}, client.unsubscribed)
+	assert.Empty(t, mh.legacySubs)
+}
+
+func TestSubscription_UnsubscribeAll(t *testing.T) {
+	client := &recordingClient{}
+	mh := NewMqttTransportFromConnection(client, 1, 1)
+	topic := "pt:j1/mt:rsp/rt:app/rn:test/ad:1"
+
+	stale, err := mh.NewSubscription(topic)
+	require.NoError(t, err)
+	mh.UnsubscribeAll()
+	assert.Equal(t, []string{topic}, client.unsubscribed)
+
+	sub, err := mh.NewSubscription(topic)
+	require.NoError(t, err)
+	assert.Equal(t, []string{topic, topic}, client.subscribed)
+
+	// handle removed by UnsubscribeAll must not release handles created later
+	require.NoError(t, stale.Close())
+	assert.Len(t, client.unsubscribed, 1)
+
+	require.NoError(t, sub.Close())
+	assert.Equal(t, []string{topic, topic}, client.unsubscribed)
+	assert.Empty(t, mh.subRefs)
+}
@@ -1,6 +1,9 @@
 package fimpgo
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,7 +12,8 @@ import (
 
 // SyncClient allows sync interaction over async channel.
 type SyncClient struct {
-	mqttTransport         *MqttTransport
+	transport             Transport
+	ownTransport          *MqttTransport // transport created by Connect , it's stopped together with the client
 	mqttConnPool          *MqttConnectionPool
 	isConnPoolEnabled     bool
 	transactionPoolSize   int // Max transaction pool size
@@ -19,6 +23,30 @@ type SyncClient struct {
 	stopSignalCh          chan bool
 	isStartedUsingConnect bool
 	globalPrefix          string
+
+	pendingMux     sync.RWMutex
+	pendingByUID   map[string]*pendingRequest          // requests indexed by request uid , responses are matched using corid
+	pendingByRoute map[responseRoute][]*pendingRequest // requests waiting for response on topic with service and msg type
+
+	dispatcherMux        sync.Mutex
+	isDispatcherRunning  bool
+	registeredTransports map[Transport]struct{}
+}
+
+// responseRoute is used to match responses which are not correlated using uid->corid.
+type responseRoute struct {
+	topic   string
+	service string
+	msgType string
+}
+
+// pendingRequest represents request awaiting response.
+type pendingRequest struct {
+	uid      string
+	route    responseRoute
+	hasRoute bool
+	isMulti  bool // multi-response request stays pending until it is released by the caller
+	respCh   chan *FimpMessage
 }
 
 // SetGlobalPrefix configures global prefix/site_id . Most be used from backend services.
@@ -30,18 +58,18 @@ func (sc *SyncClient) SetTransactionPoolSize(transactionPoolSize int) {
 	sc.transactionPoolSize = transactionPoolSize
 }
 
-// NewSyncClient creates sync client using existing mqtt connection
-func NewSyncClient(mqttTransport *MqttTransport) *SyncClient {
-	sc := SyncClient{mqttTransport: mqttTransport}
+// NewSyncClient creates sync client using existing transport
+func NewSyncClient(transport Transport) *SyncClient {
+	sc := SyncClient{transport: normalizeTransport(transport)}
 	sc.transactionPoolSize = 20
 	sc.inboundBufferSize = 10
 	sc.init()
 	return &sc
 }
 
-// NewSyncClientV2 creates new sync client using existing mqtt connection and configures transactionPool size and inboundBufferSize
-func NewSyncClientV2(mqttTransport *MqttTransport, transactionPoolSize int, inboundBuffSize int) *SyncClient {
-	sc := SyncClient{mqttTransport: mqttTransport}
+// NewSyncClientV2 creates new sync client using existing transport and configures transactionPool size and inboundBufferSize
+func NewSyncClientV2(transport Transport, transactionPoolSize int, inboundBuffSize int) *SyncClient {
+	sc := SyncClient{transport: normalizeTransport(transport)}
 	sc.transactionPoolSize = transactionPoolSize
 	sc.inboundBufferSize = inboundBuffSize
 	sc.init()
@@ -58,20 +86,35 @@ func (sc *SyncClient) SetConfigs(transactionPoolSize int, inboundBuffSize int) {
 }
 
 func (sc *SyncClient) init() {
-	sc.stopSignalCh = make(chan bool)
+	if sc.inboundBufferSize <= 0 {
+		sc.inboundBufferSize = 10
+	}
+	sc.inboundMsgChannel = make(MessageCh, sc.inboundBufferSize)
+	sc.inboundChannelName = "sync-client-" + uuid.New().String()
+	sc.pendingByUID = make(map[string]*pendingRequest, sc.transactionPoolSize)
+	sc.pendingByRoute = make(map[responseRoute][]*pendingRequest)
+	sc.registeredTransports = make(map[Transport]struct{})
+}
+
+// PendingRequests returns number of requests which are still awaiting response.
+func (sc *SyncClient) PendingRequests() int {
+	sc.pendingMux.RLock()
+	defer sc.pendingMux.RUnlock()
+	return len(sc.pendingByUID)
 }
 
 // Connect establishes internal connection to mqtt broker and initializes mqtt
 // Should be used if MqttTransport instance is not provided in constructor .
 func (sc *SyncClient) Connect(serverURI string, clientID string, username string, password string, cleanSession bool, subQos byte, pubQos byte) error {
-	if sc.mqttTransport == nil {
+	if sc.transport == nil {
 		log.Info("<SyncClient> Connecting to mqtt broker")
-		sc.mqttTransport = NewMqttTransport(serverURI, clientID, username, password, cleanSession, subQos, pubQos)
-		err := sc.mqttTransport.Start()
+		sc.ownTransport = NewMqttTransport(serverURI, clientID, username, password, cleanSession, subQos, pubQos)
+		err := sc.ownTransport.Start()
 		if err != nil {
 			log.Error("<SyncClient> Error connecting to broker :", err)
 			return err
 		}
+		sc.transport = sc.ownTransport
 		sc.isStartedUsingConnect = true
 	} else {
 		log.Info("<SyncClient> Already connected")
@@ -82,98 +125,233 @@ func (sc *SyncClient) Connect(serverURI string, clientID string, username string
 
 // Stop has to be invoked to stop message listener
 func (sc *SyncClient) Stop() {
+	sc.dispatcherMux.Lock()
+	for conn := range sc.registeredTransports {
+		conn.UnregisterChannel(sc.inboundChannelName)
+		delete(sc.registeredTransports, conn)
+	}
+	if sc.isDispatcherRunning {
+		close(sc.stopSignalCh)
+		sc.isDispatcherRunning = false
+	}
+	sc.dispatcherMux.Unlock()
+
 	if sc.isStartedUsingConnect {
-		sc.mqttTransport.Stop()
+		sc.ownTransport.Stop()
 	}
 
 }
 
 // AddSubscription has to be invoked before Send methods
 func (sc *SyncClient) AddSubscription(topic string) {
-	if err := sc.mqttTransport.Subscribe(topic); err != nil {
+	if err := sc.transport.Subscribe(topic); err != nil {
 		log.Error("<SyncClient> error subscribing to topic:", err)
 	}
 }
 
 // RemoveSubscription
 func (sc *SyncClient) RemoveSubscription(topic string) {
-	if err := sc.mqttTransport.Unsubscribe(topic); err != nil {
+	if err := sc.transport.Unsubscribe(topic); err != nil {
 		log.Error("<SyncClient> error unsubscribing from topic:", err)
 	}
 }
 
-// SendFimpWithTopicResponse send message over mqtt and awaits response from responseTopic with responseService and responseMsgType
-func (sc *SyncClient) sendFimpWithTopicResponse(topic string, fimpMsg *FimpMessage, responseTopic string, responseService string, responseMsgType string, timeout int64, autoSubscribe bool) (*FimpMessage, error) {
-	//log.Debug("Registering request uid = ",fimpMsg.UID)
+// sendFimpWithTopicResponse send message over mqtt and awaits response from responseTopic with responseService and responseMsgType.
+// The call is aborted as soon as ctx is canceled or its deadline is exceeded.
+func (sc *SyncClient) sendFimpWithTopicResponse(ctx context.Context, topic string, fimpMsg *FimpMessage, cfg requestConfig) (*FimpMessage, error) {
+	req, release, err := sc.sendRequest(ctx, topic, fimpMsg, cfg, false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	select {
+	case fimpResponse := <-req.respCh:
+		return fimpResponse, nil
+	case <-ctx.Done():
+		log.Info("<SyncClient> No response from queue. Request aborted: ", ctx.Err())
+		return nil, contextError(ctx)
+	}
+}
+
+// sendRequest registers pending request and publishes request message.
+// Returned release function must be invoked once the caller is not interested in responses anymore.
+func (sc *SyncClient) sendRequest(ctx context.Context, topic string, fimpMsg *FimpMessage, cfg requestConfig, isMulti bool) (*pendingRequest, func(), error) {
 	var conId int
-	var conn *MqttTransport
-	var inboundCh = make(MessageCh, 10)
-	var responseChannel chan *FimpMessage
+	var conn Transport
+	var req *pendingRequest
+	var sub SubscriptionHandle
+	var isSubscribed bool // response topic subscribed using Subscribe , it's unsubscribed on release
 	var err error
-	var chanName = uuid.New().String()
 
-	defer func() {
-		if autoSubscribe && responseTopic != "" && conn != nil {
-			if err := conn.Unsubscribe(responseTopic); err != nil {
+	if ctx.Err() != nil {
+		return nil, nil, contextError(ctx)
+	}
+
+	release := func() {
+		if req != nil {
+			sc.removePendingRequest(req)
+		}
+		if sub != nil {
+			if err := sub.Close(); err != nil {
 				log.Error("<SyncClient> error unsubscribing from topic:", err)
 			}
-		}
-		if conn != nil {
-			conn.UnregisterChannel(chanName)
-			close(inboundCh)
-			if sc.isConnPoolEnabled {
-				// force unset global prefix
-				conn.SetGlobalTopicPrefix("")
-				sc.mqttConnPool.ReturnConnection(conId)
+		} else if isSubscribed {
+			if err := conn.Unsubscribe(cfg.responseTopic); err != nil {
+				log.Error("<SyncClient> error unsubscribing from topic:", err)
 			}
 		}
-	}()
+		if conn != nil && sc.isConnPoolEnabled {
+			sc.releaseTransport(conn)
+			// force unset global prefix
+			conn.SetGlobalTopicPrefix("")
+			sc.mqttConnPool.ReturnConnection(conId)
+		}
+	}
 
 	if sc.isConnPoolEnabled {
-		conId, conn, err = sc.mqttConnPool.BorrowConnection()
+		var pooledConn *MqttTransport
+		conId, pooledConn, err = sc.mqttConnPool.BorrowConnection()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
+		conn = pooledConn
 	} else {
-		conn = sc.mqttTransport
+		conn = sc.transport
 	}
-	conn.RegisterChannel(chanName, inboundCh)
+	sc.ensureDispatcher(conn)
 
-	responseChannel = sc.startResponseListener(fimpMsg, responseMsgType, responseService, responseTopic, inboundCh, timeout)
+	req = sc.addPendingRequest(fimpMsg, cfg, isMulti)
 
 	// force the global prefix -> this is useful for per-site operations
 	if sc.globalPrefix != "" {
 		conn.SetGlobalTopicPrefix(sc.globalPrefix)
 	}
 
-	if autoSubscribe && responseTopic != "" {
-		if err := conn.Subscribe(responseTopic); err != nil {
+	if cfg.autoSubscribe && cfg.responseTopic != "" {
+		// subscription handle doesn't remove subscription of the same topic used by other requests or components
+		if hs, ok := conn.(HandleSubscriber); ok {
+			sub, err = hs.SubscribeHandle(cfg.responseTopic)
+		} else if err = conn.Subscribe(cfg.responseTopic); err == nil {
+			isSubscribed = true
+		}
+		if err != nil {
 			log.Error("<SyncClient> error subscribing to topic:", err)
+			release()
+			return nil, nil, fmt.Errorf("%w: %w", errSubscribe, err)
 		}
-	} else if responseTopic != "" {
-		if err := conn.Subscribe(responseTopic); err != nil {
+	} else if cfg.responseTopic != "" {
+		if err := conn.Subscribe(cfg.responseTopic); err != nil {
 			log.Error("<SyncClient> error subscribing to topic:", err)
-			return nil, errSubscribe
+			release()
+			return nil, nil, fmt.Errorf("%w: %w", errSubscribe, err)
 		}
 	}
 
 	if err := conn.PublishToTopic(topic, fimpMsg); err != nil {
 		log.Error("<SyncClient> error publishing to topic:", err)
-		return nil, errPublish
+		release()
+		return nil, nil, fmt.Errorf("%w: %w", errPublish, err)
 	}
 
-	select {
-	case fimpResponse := <-responseChannel:
-		return fimpResponse, nil
-	case <-time.After(time.Second * time.Duration(timeout)):
-		log.Info("<SyncClient> No response from queue for ", timeout)
-		return nil, errTimeout
+	return req, release, nil
+}
+
+// receiveResponses passes responses of multi-response request to emit function until ctx is done , max number of responses is reached
+// or final response is received. The method returns nil if request was completed by reaching max number of responses or final response.
+func (sc *SyncClient) receiveResponses(ctx context.Context, req *pendingRequest, cfg requestConfig, emit func(msg *FimpMessage) bool) error {
+	var count int
+	for {
+		select {
+		case msg := <-req.respCh:
+			count++
+			if !emit(msg) {
+				return contextError(ctx)
+			}
+			if cfg.maxResponses > 0 && count >= cfg.maxResponses {
+				return nil
+			}
+			if cfg.isFinalResponse != nil && cfg.isFinalResponse(msg) {
+				return nil
+			}
+		case <-ctx.Done():
+			return contextError(ctx)
+		}
+	}
+}
+
+// SendFimpCollect sends message over mqtt and collects all responses correlated to the request.
+// Collection stops when ctx deadline is exceeded , max number of responses is reached (WithMaxResponses) or final response is received (WithFinalResponse).
+// Reaching ctx deadline is a normal way of completing the request , so collected responses are returned without error.
+// If ctx is canceled , responses collected so far are returned together with an error.
+func (sc *SyncClient) SendFimpCollect(ctx context.Context, topic string, fimpMsg *FimpMessage, opts ...RequestOption) ([]*FimpMessage, error) {
+	cfg := newRequestConfig(opts)
+	req, release, err := sc.sendRequest(ctx, topic, fimpMsg, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var responses []*FimpMessage
+	err = sc.receiveResponses(ctx, req, cfg, func(msg *FimpMessage) bool {
+		responses = append(responses, msg)
+		return true
+	})
+	if err != nil && !IsTimeout(err) {
+		return responses, err
 	}
+	return responses, nil
+}
+
+// SendFimpStream sends message over mqtt and returns channel which delivers all responses correlated to the request.
+// The channel is closed when ctx is done , max number of responses is reached (WithMaxResponses) or final response is received (WithFinalResponse).
+// The caller must either read the channel until it is closed or cancel ctx.
+func (sc *SyncClient) SendFimpStream(ctx context.Context, topic string, fimpMsg *FimpMessage, opts ...RequestOption) (<-chan *FimpMessage, error) {
+	cfg := newRequestConfig(opts)
+	req, release, err := sc.sendRequest(ctx, topic, fimpMsg, cfg, true)
+	if err != nil {
+		return nil, err
+	}
+
+	responseCh := make(chan *FimpMessage, sc.inboundBufferSize)
+	go func() {
+		defer close(responseCh)
+		defer release()
+		if err := sc.receiveResponses(ctx, req, cfg, func(msg *FimpMessage) bool {
+			select {
+			case responseCh <- msg:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}); err != nil {
+			log.Debug("<SyncClient> Response stream is closed: ", err)
+		}
+	}()
+	return responseCh, nil
+}
+
+// SendFimpContext sends message over mqtt and blocks until response is received or ctx is done.
+// By default messages are correlated using uid->corid , the behaviour can be changed by providing request options.
+func (sc *SyncClient) SendFimpContext(ctx context.Context, topic string, fimpMsg *FimpMessage, opts ...RequestOption) (*FimpMessage, error) {
+	return sc.sendFimpWithTopicResponse(ctx, topic, fimpMsg, newRequestConfig(opts))
+}
+
+// SendReqRespFimpContext is a context aware version of SendReqRespFimp.
+func (sc *SyncClient) SendReqRespFimpContext(ctx context.Context, cmdTopic, responseTopic string, reqMsg *FimpMessage, autoSubscribe bool) (*FimpMessage, error) {
+	return sc.sendFimpWithTopicResponse(ctx, cmdTopic, reqMsg, requestConfig{responseTopic: responseTopic, autoSubscribe: autoSubscribe})
+}
+
+// SendFimpWithTopicResponseContext is a context aware version of SendFimpWithTopicResponse.
+func (sc *SyncClient) SendFimpWithTopicResponseContext(ctx context.Context, topic string, fimpMsg *FimpMessage, responseTopic string, responseService string, responseMsgType string) (*FimpMessage, error) {
+	return sc.sendFimpWithTopicResponse(ctx, topic, fimpMsg, requestConfig{responseTopic: responseTopic, responseService: responseService, responseMsgType: responseMsgType})
 }
 
 // SendReqRespFimp sends msg to topic and expects to receive response on response topic . If autoSubscribe is set to true , the system will automatically subscribe and unsubscribe from response topic.
 func (sc *SyncClient) SendReqRespFimp(cmdTopic, responseTopic string, reqMsg *FimpMessage, timeout int64, autoSubscribe bool) (*FimpMessage, error) {
-	return sc.sendFimpWithTopicResponse(cmdTopic, reqMsg, responseTopic, "", "", timeout, autoSubscribe)
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return sc.SendReqRespFimpContext(ctx, cmdTopic, responseTopic, reqMsg, autoSubscribe)
 }
 
 // SendFimp sends message over mqtt and blocks until request is received or timeout is reached .
@@ -184,23 +362,159 @@ func (sc *SyncClient) SendFimp(topic string, fimpMsg *FimpMessage, timeout int64
 
 // SendFimpWithTopicResponse send message over mqtt and awaits response from responseTopic with responseService and responseMsgType (the method is for backward compatibility)
 func (sc *SyncClient) SendFimpWithTopicResponse(topic string, fimpMsg *FimpMessage, responseTopic string, responseService string, responseMsgType string, timeout int64) (*FimpMessage, error) {
-	return sc.sendFimpWithTopicResponse(topic, fimpMsg, responseTopic, responseService, responseMsgType, timeout, false)
+	ctx, cancel := timeoutContext(timeout)
+	defer cancel()
+	return sc.SendFimpWithTopicResponseContext(ctx, topic, fimpMsg, responseTopic, responseService, responseMsgType)
 }
 
-// startResponseListener starts response listener , it blocks callers proc until response is received or timeout.
-func (sc *SyncClient) startResponseListener(requestMsg *FimpMessage, respMsgType, respService, respTopic string, inboundCh MessageCh, timeout int64) chan *FimpMessage {
-	log.Debug("<SyncClient> Msg listener is started")
-	respChan := make(chan *FimpMessage)
-	go func() {
-		for msg := range inboundCh {
-			if (respMsgType == msg.Payload.Type && respService == msg.Payload.Service && respTopic == msg.Topic) || requestMsg.UID == msg.Payload.CorrelationID {
-				select {
-				case respChan <- msg.Payload:
-				case <-time.After(time.Second * time.Duration(timeout)):
-				}
-				return
+// ensureDispatcher starts response dispatcher and registers shared inbound channel on the transport.
+func (sc *SyncClient) ensureDispatcher(conn Transport) {
+	sc.dispatcherMux.Lock()
+	defer sc.dispatcherMux.Unlock()
+
+	if !sc.isDispatcherRunning {
+		sc.stopSignalCh = make(chan bool)
+		sc.isDispatcherRunning = true
+		go sc.dispatchResponses(sc.stopSignalCh)
+	}
+	if _, ok := sc.registeredTransports[conn]; !ok {
+		conn.RegisterChannelWithFilterFunc(sc.inboundChannelName, sc.inboundMsgChannel, sc.isPendingResponse)
+		sc.registeredTransports[conn] = struct{}{}
+	}
+}
+
+// releaseTransport unregisters shared inbound channel from the transport.
+func (sc *SyncClient) releaseTransport(conn Transport) {
+	sc.dispatcherMux.Lock()
+	defer sc.dispatcherMux.Unlock()
+
+	conn.UnregisterChannel(sc.inboundChannelName)
+	delete(sc.registeredTransports, conn)
+}
+
+// dispatchResponses resolves inbound messages against pending requests until stop signal is received.
+func (sc *SyncClient) dispatchResponses(stopSignalCh chan bool) {
+	log.Debug("<SyncClient> Response dispatcher is started")
+	for {
+		select {
+		case msg := <-sc.inboundMsgChannel:
+			sc.resolvePendingRequest(msg)
+		case <-stopSignalCh:
+			log.Debug("<SyncClient> Response dispatcher is stopped")
+			return
+		}
+	}
+}
+
+// isPendingResponse is used as transport filter , it lets through only messages which are awaited by one of pending requests.
+func (sc *SyncClient) isPendingResponse(topic string, _ *Address, msg *FimpMessage) bool {
+	if msg == nil {
+		return false
+	}
+	sc.pendingMux.RLock()
+	defer sc.pendingMux.RUnlock()
+
+	if _, ok := sc.pendingByUID[msg.CorrelationID]; ok && msg.CorrelationID != "" {
+		return true
+	}
+	_, ok := sc.pendingByRoute[responseRoute{topic: topic, service: msg.Service, msgType: msg.Type}]
+	return ok
+}
+
+// resolvePendingRequest finds requests the message is response to and delivers the message.
+// Correlated message is delivered to the request with matching uid. Other messages are delivered to the oldest single-response request
+// and to all multi-response requests waiting on the same topic , service and message type.
+func (sc *SyncClient) resolvePendingRequest(msg *Message) {
+	if msg == nil || msg.Payload == nil {
+		return
+	}
+	var targets []*pendingRequest
+	sc.pendingMux.Lock()
+	if req, ok := sc.pendingByUID[msg.Payload.CorrelationID]; ok && msg.Payload.CorrelationID != "" {
+		targets = append(targets, req)
+	} else {
+		route := responseRoute{topic: msg.Topic, service: msg.Payload.Service, msgType: msg.Payload.Type}
+		var isSingleFound bool
+		for _, req := range sc.pendingByRoute[route] {
+			if req.isMulti {
+				targets = append(targets, req)
+			} else if !isSingleFound {
+				targets = append(targets, req)
+				isSingleFound = true
 			}
 		}
-	}()
-	return respChan
+	}
+	for _, req := range targets {
+		if !req.isMulti {
+			sc.deletePendingRequest(req)
+		}
+	}
+	sc.pendingMux.Unlock()
+
+	for _, req := range targets {
+		if !req.isMulti {
+			// channel is buffered and request is removed from the index , so the send never blocks
+			req.respCh <- msg.Payload
+			continue
+		}
+		select {
+		case req.respCh <- msg.Payload:
+		default:
+			log.Warnf("<SyncClient> Response buffer of request %s is full , response dropped", req.uid)
+		}
+	}
+}
+
+func (sc *SyncClient) addPendingRequest(requestMsg *FimpMessage, cfg requestConfig, isMulti bool) *pendingRequest {
+	req := &pendingRequest{uid: requestMsg.UID, isMulti: isMulti}
+	if isMulti {
+		req.respCh = make(chan *FimpMessage, sc.inboundBufferSize)
+	} else {
+		req.respCh = make(chan *FimpMessage, 1)
+	}
+	if cfg.responseService != "" || cfg.responseMsgType != "" {
+		req.route = responseRoute{topic: cfg.responseTopic, service: cfg.responseService, msgType: cfg.responseMsgType}
+		req.hasRoute = true
+	}
+
+	sc.pendingMux.Lock()
+	sc.pendingByUID[req.uid] = req
+	if req.hasRoute {
+		sc.pendingByRoute[req.route] = append(sc.pendingByRoute[req.route], req)
+	}
+	sc.pendingMux.Unlock()
+	return req
+}
+
+func (sc *SyncClient) removePendingRequest(req *pendingRequest) {
+	sc.pendingMux.Lock()
+	sc.deletePendingRequest(req)
+	sc.pendingMux.Unlock()
+}
+
+// deletePendingRequest removes request from indexes. Must be invoked under pendingMux lock.
+func (sc *SyncClient) deletePendingRequest(req *pendingRequest) {
+	if sc.pendingByUID[req.uid] == req {
+		delete(sc.pendingByUID, req.uid)
+	}
+	if !req.hasRoute {
+		return
+	}
+	reqs := sc.pendingByRoute[req.route]
+	for i := range reqs {
+		if reqs[i] == req {
+			reqs = append(reqs[:i], reqs[i+1:]...)
+			break
+		}
+	}
+	if len(reqs) == 0 {
+		delete(sc.pendingByRoute, req.route)
+	} else {
+		sc.pendingByRoute[req.route] = reqs
+	}
+}
+
+// timeoutContext converts legacy timeout in seconds into context.
+func timeoutContext(timeout int64) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), time.Second*time.Duration(timeout))
 }
@@ -0,0 +1,72 @@
+package fimpgo_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/fimpgotest"
+)
+
+// failingSubscribeTransport is in-memory transport , which can't subscribe for any topic.
+type failingSubscribeTransport struct {
+	*fimpgotest.Transport
+}
+
+func (t failingSubscribeTransport) Subscribe(string) error {
+	return errors.New("not authorized")
+}
+
+func (t failingSubscribeTransport) SubscribeHandle(string) (fimpgo.SubscriptionHandle, error) {
+	return nil, errors.New("not authorized")
+}
+
+func TestSyncClient_SendFimpContextCanceled(t *testing.T) {
+	broker := fimpgotest.NewBroker()
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	client := fimpgo.NewSyncClient(tr)
+	defer client.Stop()
+
+	responseTopic := "pt:j1/mt:rsp/rt:app/rn:client/ad:1"
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 1, client.PendingRequests())
+		assert.Len(t, tr.Subscriptions(), 1, "response topic must be subscribed while request is pending")
+		cancel()
+	}()
+
+	_, err := client.SendFimpContext(ctx, "pt:j1/mt:cmd/rt:app/rn:test/ad:1", fimpgo.NewNullMessage("cmd.test.get_report", "test", nil, nil, nil),
+		fimpgo.WithResponseTopic(responseTopic), fimpgo.WithAutoSubscribe())
+	require.Error(t, err)
+	assert.True(t, fimpgo.IsCanceled(err))
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// canceled request is unregistered and its response topic unsubscribed
+	assert.Equal(t, 0, client.PendingRequests())
+	assert.Empty(t, tr.Subscriptions())
+}
+
+func TestSyncClient_AutoSubscribeFailed(t *testing.T) {
+	broker := fimpgotest.NewBroker()
+	tr := broker.NewTransport("client")
+	defer tr.Stop()
+	client := fimpgo.NewSyncClient(failingSubscribeTransport{tr})
+	defer client.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := client.SendFimpContext(ctx, "pt:j1/mt:cmd/rt:app/rn:test/ad:1", fimpgo.NewNullMessage("cmd.test.get_report", "test", nil, nil, nil),
+		fimpgo.WithResponseTopic("pt:j1/mt:rsp/rt:app/rn:client/ad:1"), fimpgo.WithAutoSubscribe())
+	require.Error(t, err)
+	assert.True(t, fimpgo.IsSubscribeFailed(err))
+	assert.False(t, fimpgo.IsTimeout(err))
+	assert.Equal(t, 0, client.PendingRequests())
+	assert.Empty(t, broker.Published(), "request must not be published without response subscription")
+}
@@ -0,0 +1,78 @@
+package fimpgo
+
+type (
+	// RequestOption configures a single request sent by SyncClient.
+	RequestOption interface {
+		apply(*requestConfig)
+	}
+
+	requestConfig struct {
+		responseTopic   string
+		responseService string
+		responseMsgType string
+		autoSubscribe   bool
+		maxResponses    int
+		isFinalResponse func(msg *FimpMessage) bool
+	}
+
+	responseTopicOption  string
+	responseFilterOption struct{ service, msgType string }
+	autoSubscribeOption  bool
+	maxResponsesOption   int
+	finalResponseOption  func(msg *FimpMessage) bool
+)
+
+func newRequestConfig(opts []RequestOption) requestConfig {
+	cfg := requestConfig{}
+	for _, o := range opts {
+		o.apply(&cfg)
+	}
+	return cfg
+}
+
+func (o responseTopicOption) apply(cfg *requestConfig) {
+	cfg.responseTopic = string(o)
+}
+
+func (o responseFilterOption) apply(cfg *requestConfig) {
+	cfg.responseService = o.service
+	cfg.responseMsgType = o.msgType
+}
+
+func (o autoSubscribeOption) apply(cfg *requestConfig) {
+	cfg.autoSubscribe = bool(o)
+}
+
+// WithResponseTopic sets topic the response is expected on. The client subscribes to the topic before sending the request.
+func WithResponseTopic(topic string) RequestOption {
+	return responseTopicOption(topic)
+}
+
+// WithResponseFilter allows to match response by service and message type in addition to uid->corid correlation.
+// Filter is applied only together with response topic.
+func WithResponseFilter(service, msgType string) RequestOption {
+	return responseFilterOption{service: service, msgType: msgType}
+}
+
+// WithAutoSubscribe makes the client unsubscribe from response topic once request is completed.
+func WithAutoSubscribe() RequestOption {
+	return autoSubscribeOption(true)
+}
+
+func (o maxResponsesOption) apply(cfg *requestConfig) {
+	cfg.maxResponses = int(o)
+}
+
+func (o finalResponseOption) apply(cfg *requestConfig) {
+	cfg.isFinalResponse = o
+}
+
+// WithMaxResponses completes multi-response request once n responses are received.
+func WithMaxResponses(n int) RequestOption {
+	return maxResponsesOption(n)
+}
+
+// WithFinalResponse completes multi-response request once response matching the predicate is received. The final response is included in results.
+func WithFinalResponse(isFinal func(msg *FimpMessage) bool) RequestOption {
+	return finalResponseOption(isFinal)
+}
@@ -1,6 +1,7 @@
 package fimpgo
 
 import (
+	"context"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -179,3 +180,87 @@ func TestSyncClient_SendFimpWithTopicResponse(t *testing.T) {
 	t.Log("SyncClient test - OK")
 
 }
+
+func TestSyncClient_ResolvePendingRequests(t *testing.T) {
+	syncClient := NewSyncClient(nil)
+	requests := make([]*pendingRequest, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		msg := NewFloatMessage("cmd.sensor.get_report", "temp_sensor", float64(i), nil, nil, nil)
+		requests = append(requests, syncClient.addPendingRequest(msg, requestConfig{}, false))
+	}
+	routeMsg := NewNullMessage("cmd.sensor.get_report", "temp_sensor", nil, nil, nil)
+	routeReq := syncClient.addPendingRequest(routeMsg, requestConfig{responseTopic: "pt:j1/mt:evt/rt:app/rn:testapp/ad:1", responseService: "temp_sensor", responseMsgType: "evt.sensor.report"}, false)
+
+	if syncClient.PendingRequests() != 5001 {
+		t.Fatal("Wrong number of pending requests ", syncClient.PendingRequests())
+	}
+
+	for i := len(requests) - 1; i >= 0; i-- {
+		response := NewFloatMessage("evt.sensor.report", "temp_sensor", float64(i), nil, nil, &FimpMessage{UID: requests[i].uid})
+		if !syncClient.isPendingResponse("pt:j1/mt:evt/rt:app/rn:testapp/ad:1", nil, response) {
+			t.Fatal("Response is filtered out")
+		}
+		syncClient.resolvePendingRequest(&Message{Topic: "pt:j1/mt:evt/rt:app/rn:testapp/ad:1", Payload: response})
+	}
+	for i := range requests {
+		response := <-requests[i].respCh
+		if val, _ := response.GetFloatValue(); val != float64(i) {
+			t.Fatal("Response is delivered to wrong request")
+		}
+	}
+
+	unrelated := NewFloatMessage("evt.sensor.report", "temp_sensor", 1, nil, nil, nil)
+	if syncClient.isPendingResponse("pt:j1/mt:evt/rt:app/rn:otherapp/ad:1", nil, unrelated) {
+		t.Fatal("Unrelated message is not filtered out")
+	}
+	syncClient.resolvePendingRequest(&Message{Topic: "pt:j1/mt:evt/rt:app/rn:testapp/ad:1", Payload: unrelated})
+	if response := <-routeReq.respCh; response.UID != unrelated.UID {
+		t.Fatal("Response is not matched by topic , service and type")
+	}
+
+	if syncClient.PendingRequests() != 0 {
+		t.Fatal("Pending requests are not cleaned up")
+	}
+}
+
+func TestSyncClient_ReceiveMultipleResponses(t *testing.T) {
+	syncClient := NewSyncClient(nil)
+	topic := "pt:j1/mt:evt/rt:discovery"
+	cfg := newRequestConfig([]RequestOption{
+		WithResponseTopic(topic),
+		WithResponseFilter("system", "evt.discovery.report"),
+		WithFinalResponse(func(msg *FimpMessage) bool {
+			val, _ := msg.GetStringValue()
+			return val == "last"
+		}),
+	})
+	reqMsg := NewNullMessage("cmd.discovery.request", "system", nil, nil, nil)
+	req := syncClient.addPendingRequest(reqMsg, cfg, true)
+	defer syncClient.removePendingRequest(req)
+
+	for _, val := range []string{"first", "second", "last", "ignored"} {
+		syncClient.resolvePendingRequest(&Message{Topic: topic, Payload: NewStringMessage("evt.discovery.report", "system", val, nil, nil, nil)})
+	}
+	if syncClient.PendingRequests() != 1 {
+		t.Fatal("Multi-response request must stay pending")
+	}
+
+	var responses []string
+	err := syncClient.receiveResponses(context.Background(), req, cfg, func(msg *FimpMessage) bool {
+		val, _ := msg.GetStringValue()
+		responses = append(responses, val)
+		return true
+	})
+	if err != nil || len(responses) != 3 || responses[2] != "last" {
+		t.Fatal("Wrong responses ", responses, err)
+	}
+
+	cfg.isFinalResponse = nil
+	cfg.maxResponses = 5
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = syncClient.receiveResponses(ctx, req, cfg, func(msg *FimpMessage) bool { return true })
+	if !IsTimeout(err) {
+		t.Fatal("Expected timeout , got ", err)
+	}
+}
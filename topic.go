@@ -1 +1,193 @@
 package fimpgo
+
+import (
+	"path"
+	"strings"
+)
+
+// subscriptionIndex finds channels interested in a message. Filters are stored in a trie over topic levels ,
+// every filter topic ends in a node , which indexes channels by service and interface , so lookup time is proportional to topic depth
+// and doesn't depend on number of registered channels.
+type subscriptionIndex struct {
+	root       *topicNode
+	filters    map[string]FimpFilter // channel id -> indexed filter
+	unfiltered map[string]struct{}   // channels receiving all messages
+}
+
+type topicNode struct {
+	children map[string]*topicNode
+	services map[string]*interfaceSet // service or * -> channels of filters ending at the node
+}
+
+type interfaceSet struct {
+	exact map[string]map[string]struct{} // interface or * -> channel ids
+	globs map[string]map[string]struct{} // interface glob , for instance evt.sensor.* -> channel ids
+}
+
+func newSubscriptionIndex() *subscriptionIndex {
+	return &subscriptionIndex{root: &topicNode{}, filters: make(map[string]FimpFilter), unfiltered: make(map[string]struct{})}
+}
+
+// set registers channel with the filter , nil filter means channel receives all messages.
+func (idx *subscriptionIndex) set(channelId string, filter *FimpFilter) {
+	idx.remove(channelId)
+	if filter == nil {
+		idx.unfiltered[channelId] = struct{}{}
+		return
+	}
+	topic, service, iface := filter.Topic, filter.Service, filter.Interface
+	node := idx.root
+	for _, level := range strings.Split(topic, "/") {
+		child, ok := node.children[level]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*topicNode)
+			}
+			child = &topicNode{}
+			node.children[level] = child
+		}
+		node = child
+	}
+	if node.services == nil {
+		node.services = make(map[string]*interfaceSet)
+	}
+	set, ok := node.services[service]
+	if !ok {
+		set = &interfaceSet{exact: make(map[string]map[string]struct{}), globs: make(map[string]map[string]struct{})}
+		node.services[service] = set
+	}
+	ids := set.exact
+	if isInterfaceGlob(iface) {
+		ids = set.globs
+	}
+	if ids[iface] == nil {
+		ids[iface] = make(map[string]struct{})
+	}
+	ids[iface][channelId] = struct{}{}
+	idx.filters[channelId] = *filter
+}
+
+// remove unregisters channel and prunes empty trie nodes.
+func (idx *subscriptionIndex) remove(channelId string) {
+	delete(idx.unfiltered, channelId)
+	filter, ok := idx.filters[channelId]
+	if !ok {
+		return
+	}
+	delete(idx.filters, channelId)
+	topic, service, iface := filter.Topic, filter.Service, filter.Interface
+	idx.root.remove(strings.Split(topic, "/"), service, iface, channelId)
+}
+
+func (n *topicNode) remove(levels []string, service, iface, channelId string) (empty bool) {
+	if len(levels) == 0 {
+		if set, ok := n.services[service]; ok {
+			ids := set.exact
+			if isInterfaceGlob(iface) {
+				ids = set.globs
+			}
+			delete(ids[iface], channelId)
+			if len(ids[iface]) == 0 {
+				delete(ids, iface)
+			}
+			if len(set.exact) == 0 && len(set.globs) == 0 {
+				delete(n.services, service)
+			}
+		}
+	} else if child, ok := n.children[levels[0]]; ok && child.remove(levels[1:], service, iface, channelId) {
+		delete(n.children, levels[0])
+	}
+	return len(n.children) == 0 && len(n.services) == 0
+}
+
+// match calls visit for every channel interested in the message. msg is nil if payload can't be parsed , in that case only topic is matched.
+func (idx *subscriptionIndex) match(topic string, msg *FimpMessage, visit func(channelId string)) {
+	for id := range idx.unfiltered {
+		visit(id)
+	}
+	idx.root.match(strings.Split(topic, "/"), msg, visit)
+}
+
+func (n *topicNode) match(levels []string, msg *FimpMessage, visit func(channelId string)) {
+	if child, ok := n.children[WildcardMultiLevel]; ok {
+		child.visit(msg, visit)
+	}
+	if len(levels) == 0 {
+		n.visit(msg, visit)
+		return
+	}
+	if child, ok := n.children[WildcardSingleLevel]; ok {
+		child.match(levels[1:], msg, visit)
+	}
+	if isWildcard(levels[0]) {
+		return
+	}
+	if child, ok := n.children[levels[0]]; ok {
+		child.match(levels[1:], msg, visit)
+	}
+}
+
+// visit calls visit for channels of filters ending at the node , which match service and interface of the message.
+func (n *topicNode) visit(msg *FimpMessage, visit func(channelId string)) {
+	if msg == nil {
+		for _, set := range n.services {
+			set.visit(nil, visit)
+		}
+		return
+	}
+	if set, ok := n.services[msg.Service]; ok {
+		set.visit(msg, visit)
+	}
+	if set, ok := n.services["*"]; ok && msg.Service != "*" {
+		set.visit(msg, visit)
+	}
+}
+
+func (s *interfaceSet) visit(msg *FimpMessage, visit func(channelId string)) {
+	if msg == nil {
+		for _, ids := range s.exact {
+			visitAll(ids, visit)
+		}
+		for _, ids := range s.globs {
+			visitAll(ids, visit)
+		}
+		return
+	}
+	visitAll(s.exact[msg.Type], visit)
+	if msg.Type != "*" {
+		visitAll(s.exact["*"], visit)
+	}
+	for glob, ids := range s.globs {
+		if globMatches(glob, msg.Type) {
+			visitAll(ids, visit)
+		}
+	}
+}
+
+func visitAll(ids map[string]struct{}, visit func(channelId string)) {
+	for id := range ids {
+		visit(id)
+	}
+}
+
+// isInterfaceGlob returns true if interface filter is glob pattern , for instance evt.sensor.* .
+func isInterfaceGlob(iface string) bool {
+	return iface != "*" && strings.ContainsAny(iface, "*?[")
+}
+
+// matchInterface returns true if message type matches interface filter , which is either exact interface , * or glob pattern.
+func matchInterface(filter, msgType string) bool {
+	if filter == "*" || filter == msgType {
+		return true
+	}
+	return isInterfaceGlob(filter) && globMatches(filter, msgType)
+}
+
+// globMatches returns true if value matches glob , malformed glob matches only itself.
+func globMatches(glob, value string) bool {
+	ok, err := path.Match(glob, value)
+	if err != nil {
+		return glob == value
+	}
+	return ok
+}
@@ -0,0 +1,188 @@
+package fimpgo
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func indexedChannels(idx *subscriptionIndex, topic string, msg *FimpMessage) []string {
+	var ids []string
+	idx.match(topic, msg, func(id string) {
+		ids = append(ids, id)
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSubscriptionIndex_MatchesFilters(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	pick := func(values ...string) string {
+		return values[r.Intn(len(values))]
+	}
+	topicLevels := [][]string{
+		{"pt:j1", "pt:j1c1"},
+		{"mt:evt", "mt:cmd"},
+		{"rt:dev", "rt:ad"},
+		{"rn:zigbee", "rn:zw"},
+		{"ad:1", "ad:2"},
+		{"sv:sensor_temp", "sv:out_bin_switch"},
+		{"ad:1_1", "ad:2_1"},
+	}
+	randomTopic := func(wildcards bool) string {
+		topic := ""
+		depth := 3 + r.Intn(len(topicLevels)-2)
+		for i := 0; i < depth; i++ {
+			level := pick(topicLevels[i]...)
+			if wildcards && r.Intn(4) == 0 {
+				level = WildcardSingleLevel
+			}
+			if wildcards && r.Intn(10) == 0 {
+				return topic + WildcardMultiLevel
+			}
+			topic += level + "/"
+		}
+		return topic[:len(topic)-1]
+	}
+
+	idx := newSubscriptionIndex()
+	filters := make(map[string]FimpFilter)
+	for i := 0; i < 500; i++ {
+		filter := FimpFilter{
+			Topic:     randomTopic(true),
+			Service:   pick("*", "sensor_temp", "out_bin_switch", ""),
+			Interface: pick("*", "evt.sensor.report", "evt.sensor.*", "cmd.binary.set", "*.binary.*", "evt.?ensor.report", "evt.sensor.["),
+		}
+		if r.Intn(5) == 0 {
+			// filter of channel registered with pattern
+			filter = FimpFilter{Topic: Match().MsgType(pick(MsgTypeEvt, MsgTypeCmd)).Adapter(pick("zigbee", "zw")).Topic(), Service: "*", Interface: "*"}
+		}
+		id := fmt.Sprintf("ch%d", i)
+		filters[id] = filter
+		idx.set(id, &filter)
+	}
+	// some channels are removed or replaced
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("ch%d", r.Intn(500))
+		if i%2 == 0 {
+			idx.remove(id)
+			delete(filters, id)
+		} else {
+			filter := FimpFilter{Topic: randomTopic(true), Service: "*", Interface: "*"}
+			filters[id] = filter
+			idx.set(id, &filter)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		topic := randomTopic(false)
+		addr, err := NewAddressFromString(topic)
+		require.NoError(t, err)
+		msg := NewNullMessage(pick("evt.sensor.report", "cmd.binary.set", "evt.binary.report"), pick("sensor_temp", "out_bin_switch"), nil, nil, nil)
+		if i%10 == 0 {
+			msg = nil
+		}
+
+		var expected []string
+		for id, filter := range filters {
+			if filter.Matches(topic, addr, msg) {
+				expected = append(expected, id)
+			}
+		}
+		sort.Strings(expected)
+		assert.Equal(t, expected, indexedChannels(idx, topic, msg), topic)
+	}
+}
+
+func TestSubscriptionIndex_Remove(t *testing.T) {
+	idx := newSubscriptionIndex()
+	idx.set("all", nil)
+	idx.set("temp", &FimpFilter{Topic: "pt:j1/mt:evt/+/+/+/sv:sensor_temp/#", Service: "*", Interface: "evt.sensor.*"})
+	idx.set("temp2", &FimpFilter{Topic: "pt:j1/mt:evt/+/+/+/sv:sensor_temp/#", Service: "*", Interface: "evt.sensor.*"})
+
+	topic := "pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1"
+	msg := NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil)
+	assert.Equal(t, []string{"all", "temp", "temp2"}, indexedChannels(idx, topic, msg))
+	assert.Equal(t, []string{"all"}, indexedChannels(idx, topic, NewNullMessage("evt.meter.report", "sensor_temp", nil, nil, nil)))
+
+	// channel can be switched to receive all messages
+	idx.set("temp", nil)
+	assert.Equal(t, []string{"all", "temp", "temp2"}, indexedChannels(idx, topic, msg))
+
+	idx.remove("temp")
+	idx.remove("temp2")
+	idx.remove("all")
+	assert.Empty(t, indexedChannels(idx, topic, msg))
+	assert.Empty(t, idx.root.children, "empty nodes must be pruned")
+	assert.Empty(t, idx.filters)
+}
+
+func TestMatchInterface(t *testing.T) {
+	assert.True(t, matchInterface("*", "evt.sensor.report"))
+	assert.True(t, matchInterface("evt.sensor.report", "evt.sensor.report"))
+	assert.True(t, matchInterface("evt.sensor.*", "evt.sensor.report"))
+	assert.True(t, matchInterface("*.sensor.report", "evt.sensor.report"))
+	assert.False(t, matchInterface("evt.sensor.*", "evt.meter.report"))
+	assert.True(t, matchInterface("evt.sensor.[", "evt.sensor.["), "malformed glob matches only itself")
+	assert.False(t, matchInterface("evt.sensor.[", "evt.sensor.report"))
+	assert.False(t, matchInterface("", "evt.sensor.report"))
+}
+
+func TestMqttTransport_InterfaceGlobFilter(t *testing.T) {
+	mh := NewMqttTransportFromConfigs(MqttConnectionConfigs{})
+	defer mh.stopWorkers()
+
+	ch := make(MessageCh, 10)
+	mh.RegisterChannelWithFilter("test", ch, FimpFilter{Topic: "pt:j1/mt:evt/#", Service: "*", Interface: "evt.sensor.*"})
+	for _, msg := range []*FimpMessage{
+		NewFloatMessage("evt.meter.report", "meter_elec", 1, nil, nil, nil),
+		NewFloatMessage("evt.sensor.report", "sensor_temp", 2, nil, nil, nil),
+	} {
+		payload, err := msg.SerializeToJson()
+		require.NoError(t, err)
+		mh.handleIncomingMessage(&spilledMessage{topic: "pt:j1/mt:evt/rt:dev/rn:zw/ad:1/sv:" + msg.Service + "/ad:1", payload: payload})
+	}
+	select {
+	case msg := <-ch:
+		assert.Equal(t, "evt.sensor.report", msg.Payload.Type)
+	case <-time.After(time.Second):
+		t.Fatal("message was not delivered")
+	}
+
+	// unregistered channel is removed from the index
+	mh.UnregisterChannel("test")
+	assert.Empty(t, mh.subIndex.filters)
+}
+
+func BenchmarkSubscriptionIndex_Match(b *testing.B) {
+	const sites = 5000
+	idx := newSubscriptionIndex()
+	filters := make([]FimpFilter, 0, sites)
+	for i := 0; i < sites; i++ {
+		filter := FimpFilter{Topic: fmt.Sprintf("site%d/pt:j1/mt:evt/rt:dev/+/+/sv:sensor_temp/#", i), Service: "*", Interface: "evt.sensor.*"}
+		filters = append(filters, filter)
+		idx.set(fmt.Sprintf("ch%d", i), &filter)
+	}
+	topic := "site2500/pt:j1/mt:evt/rt:dev/rn:zigbee/ad:1/sv:sensor_temp/ad:1_1"
+	msg := NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil)
+
+	b.Run("index", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			idx.match(topic, msg, func(string) {})
+		}
+	})
+	b.Run("linear", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, f := range filters {
+				f.Matches(topic, nil, msg)
+			}
+		}
+	})
+}
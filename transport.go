@@ -0,0 +1,60 @@
+package fimpgo
+
+// Transport is a broker independent interface used to exchange FIMP messages.
+// MqttTransport is the default implementation , higher level components (SyncClient , discovery , primefimp) accept any implementation.
+type Transport interface {
+	// Publish publishes message to FIMP address.
+	Publish(addr *Address, fimpMsg *FimpMessage) error
+	// PublishToTopic publishes message to string topic.
+	PublishToTopic(topic string, fimpMsg *FimpMessage) error
+	// RespondToRequest publishes response message to response topic of the request.
+	RespondToRequest(requestMsg *FimpMessage, responseMsg *FimpMessage) error
+	// Subscribe subscribes for topic. Topic may contain wildcards.
+	Subscribe(topic string) error
+	// Unsubscribe unsubscribes from topic.
+	Unsubscribe(topic string) error
+	// SubscribePattern subscribes for topic filter of the address pattern. Invalid pattern is rejected.
+	SubscribePattern(pattern *AddressPattern) error
+	// UnsubscribePattern unsubscribes from topic filter of the address pattern.
+	UnsubscribePattern(pattern *AddressPattern) error
+	// RegisterChannel registers channel which receives all inbound messages.
+	RegisterChannel(channelId string, messageCh MessageCh)
+	// RegisterChannelWithFilter registers channel which receives inbound messages matching the filter.
+	RegisterChannelWithFilter(channelId string, messageCh MessageCh, filter FimpFilter)
+	// RegisterChannelWithPattern registers channel which receives inbound messages with address matching the pattern.
+	RegisterChannelWithPattern(channelId string, messageCh MessageCh, pattern *AddressPattern)
+	// RegisterChannelWithFilterFunc registers channel which receives inbound messages accepted by filter function.
+	RegisterChannelWithFilterFunc(channelId string, messageCh MessageCh, filterFunc FilterFunc)
+	// UnregisterChannel unregisters channel.
+	UnregisterChannel(channelId string)
+	// SetGlobalTopicPrefix sets global prefix , which is added to all outgoing and removed from all inbound topics.
+	SetGlobalTopicPrefix(prefix string)
+}
+
+// SubscriptionHandle is a handle of reference counted topic subscription. The topic stays subscribed until all its handles are closed.
+type SubscriptionHandle interface {
+	// Topic returns topic filter of the subscription.
+	Topic() string
+	// Close releases the subscription , it can be invoked multiple times.
+	Close() error
+}
+
+// HandleSubscriber is implemented by transports supporting reference counted subscription handles.
+// SyncClient uses handles for auto subscribed response topics , so concurrent requests don't unsubscribe each other.
+type HandleSubscriber interface {
+	// SubscribeHandle subscribes for topic and returns handle , which must be closed once the topic is not needed.
+	SubscribeHandle(topic string) (SubscriptionHandle, error)
+}
+
+var (
+	_ Transport        = (*MqttTransport)(nil)
+	_ HandleSubscriber = (*MqttTransport)(nil)
+)
+
+// normalizeTransport converts nil MqttTransport pointer into nil interface.
+func normalizeTransport(transport Transport) Transport {
+	if mh, ok := transport.(*MqttTransport); ok && mh == nil {
+		return nil
+	}
+	return transport
+}
@@ -0,0 +1,249 @@
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+// Content encryption algorithms , names follow JWE (RFC 7518).
+const (
+	EncA256GCM = "A256GCM"
+	EncC20P    = "C20P" // ChaCha20-Poly1305
+)
+
+// Properties of encrypted message.
+const (
+	PropEncryptionAlg  = "alg"
+	PropEncryption     = "enc"
+	PropSenderKeyId    = "sender_kid"
+	PropRecipientKeyId = "recipient_kid"
+	PropEphemeralKey   = "epk"
+)
+
+const (
+	// ECDH-1PU key agreement with HKDF-SHA256 instead of JOSE Concat KDF , so the name is fimp specific
+	algECDH1PU     = "fimp-ECDH-1PU+HKDF-SHA256"
+	encryptionSalt = 16
+	encryptionInfo = "fimp.transport.encrypted"
+)
+
+// Message encryption
+// Plain fimp msg -> serialize into []binary -> generate ephemeral key -> derive content key from ECDH of ephemeral private key and recipient public key
+// and ECDH of sender private key and recipient public key (ECDH-1PU) with HKDF-SHA256 -> encrypt with AES-GCM or ChaCha20-Poly1305 ->
+// base64 encode salt , nonce and ciphertext -> encapsulate into evt.transport.encrypted or cmd.transport.encrypted message
+// {
+//  "type": "cmd.transport.encrypted",
+//  "serv": "out_bin_switch",
+//  "val_t": "bin",
+//  "val": "<base64 encoded salt | nonce | ciphertext>",
+//  "props": {
+//      "user_id":"aleks@gmail.com",
+//      "alg":"fimp-ECDH-1PU+HKDF-SHA256",
+//      "enc":"A256GCM",
+//      "sender_kid":"<sender key id>",
+//      "recipient_kid":"<recipient key id>",
+//      "epk":"<base64 encoded ephemeral public key>"
+//  },
+//  ...
+//}
+// Type , service and all properties (including user_id) are authenticated , so they can't be altered without breaking decryption.
+// The static sender key authenticates the sender , the ephemeral key protects messages if the sender private key leaks.
+// Messages are not protected if the recipient private key leaks , because the recipient key is long-term.
+
+// EncryptMessage encapsulates original message into encrypted transport message , which can be decrypted only by the recipient.
+// enc is either EncA256GCM or EncC20P , empty value means EncA256GCM.
+func EncryptMessage(payload *fimpgo.FimpMessage, requestMsg *fimpgo.FimpMessage, senderKey, recipientKey *security.EcdsaKey, enc string, props *fimpgo.Props) (*fimpgo.FimpMessage, error) {
+	if senderKey == nil || senderKey.PrivateKey() == nil {
+		return nil, errors.New("sender private key is missing")
+	}
+	if recipientKey == nil || publicKey(recipientKey) == nil {
+		return nil, errors.New("recipient public key is missing")
+	}
+	if enc == "" {
+		enc = EncA256GCM
+	}
+	serializedMsg, err := payload.SerializeToJson()
+	if err != nil {
+		return nil, err
+	}
+
+	msgType := "evt.transport.encrypted"
+	if strings.Contains(payload.Type, "cmd") {
+		msgType = "cmd.transport.encrypted"
+	}
+	encProps := fimpgo.Props{}
+	if props != nil {
+		for k, v := range *props {
+			encProps[k] = v
+		}
+	}
+	recipientPublic, err := publicKey(recipientKey).ECDH()
+	if err != nil {
+		return nil, err
+	}
+	ephemeralKey, err := recipientPublic.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	encProps[PropEncryptionAlg] = algECDH1PU
+	encProps[PropEncryption] = enc
+	encProps[PropSenderKeyId] = senderKey.KeyId()
+	encProps[PropRecipientKeyId] = recipientKey.KeyId()
+	encProps[PropEphemeralKey] = base64.StdEncoding.EncodeToString(ephemeralKey.PublicKey().Bytes())
+	encryptedMsg := fimpgo.NewBinaryMessage(msgType, payload.Service, nil, encProps, nil, requestMsg)
+
+	salt := make([]byte, encryptionSalt)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	staticKey, err := senderKey.PrivateKey().ECDH()
+	if err != nil {
+		return nil, err
+	}
+	ephemeralSecret, err := ephemeralKey.ECDH(recipientPublic)
+	if err != nil {
+		return nil, err
+	}
+	staticSecret, err := staticKey.ECDH(recipientPublic)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newContentCipher(ephemeralSecret, staticSecret, salt, enc)
+	if err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, encryptionSalt+aead.NonceSize(), encryptionSalt+aead.NonceSize()+len(serializedMsg)+aead.Overhead())
+	copy(sealed, salt)
+	nonce := sealed[encryptionSalt:]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed = aead.Seal(sealed, nonce, serializedMsg, additionalData(encryptedMsg))
+	encryptedMsg.Value = base64.StdEncoding.EncodeToString(sealed)
+	return encryptedMsg, nil
+}
+
+// DecryptMessage decrypts message encrypted by the sender for the recipient. recipientKey must contain private key.
+// Key ids are available in PropSenderKeyId and PropRecipientKeyId properties , so keys can be looked up before decryption.
+func DecryptMessage(encryptedMsg *fimpgo.FimpMessage, recipientKey, senderKey *security.EcdsaKey) (*fimpgo.FimpMessage, error) {
+	if recipientKey == nil || recipientKey.PrivateKey() == nil {
+		return nil, errors.New("recipient private key is missing")
+	}
+	if senderKey == nil || publicKey(senderKey) == nil {
+		return nil, errors.New("sender public key is missing")
+	}
+	if encryptedMsg.Type != "cmd.transport.encrypted" && encryptedMsg.Type != "evt.transport.encrypted" {
+		return nil, errors.New("incorrect message type")
+	}
+	encMsgBin, ok := encryptedMsg.Value.(string)
+	if !ok {
+		return nil, errors.New("incorrect encapsulated message format")
+	}
+	if alg := encryptedMsg.Properties[PropEncryptionAlg]; alg != algECDH1PU {
+		return nil, fmt.Errorf("unsupported key agreement algorithm %s", alg)
+	}
+	if encryptedMsg.Properties[PropRecipientKeyId] != recipientKey.KeyId() {
+		return nil, errors.New("message is encrypted for another recipient key")
+	}
+	if encryptedMsg.Properties[PropSenderKeyId] != senderKey.KeyId() {
+		return nil, errors.New("message is encrypted by another sender key")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encMsgBin)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < encryptionSalt {
+		return nil, errors.New("encrypted message is too short")
+	}
+	recipientPrivate, err := recipientKey.PrivateKey().ECDH()
+	if err != nil {
+		return nil, err
+	}
+	senderPublic, err := publicKey(senderKey).ECDH()
+	if err != nil {
+		return nil, err
+	}
+	epk, err := base64.StdEncoding.DecodeString(encryptedMsg.Properties[PropEphemeralKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	ephemeralPublic, err := recipientPrivate.Curve().NewPublicKey(epk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %w", err)
+	}
+	ephemeralSecret, err := recipientPrivate.ECDH(ephemeralPublic)
+	if err != nil {
+		return nil, err
+	}
+	staticSecret, err := recipientPrivate.ECDH(senderPublic)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newContentCipher(ephemeralSecret, staticSecret, sealed[:encryptionSalt], encryptedMsg.Properties[PropEncryption])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[encryptionSalt:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("encrypted message is too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData(encryptedMsg))
+	if err != nil {
+		return nil, err
+	}
+	return fimpgo.NewMessageFromBytes(plain)
+}
+
+// newContentCipher derives content encryption key from ephemeral-static and static-static ECDH shared secrets using HKDF-SHA256.
+func newContentCipher(ephemeralSecret, staticSecret, salt []byte, enc string) (cipher.AEAD, error) {
+	secret := make([]byte, 0, len(ephemeralSecret)+len(staticSecret))
+	secret = append(append(secret, ephemeralSecret...), staticSecret...)
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(encryptionInfo+" "+enc)), key); err != nil {
+		return nil, err
+	}
+	switch enc {
+	case EncA256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case EncC20P:
+		return chacha20poly1305.New(key)
+	}
+	return nil, fmt.Errorf("unsupported content encryption algorithm %s", enc)
+}
+
+// additionalData binds type , service and all properties of the envelope to the ciphertext. Properties are serialized as JSON object with sorted keys.
+func additionalData(msg *fimpgo.FimpMessage) []byte {
+	props, _ := json.Marshal(msg.Properties)
+	return []byte(strings.Join([]string{msg.Type, msg.Service, string(props)}, "\n"))
+}
+
+func publicKey(key *security.EcdsaKey) *ecdsa.PublicKey {
+	if key.PublicKey() != nil {
+		return key.PublicKey()
+	}
+	if key.PrivateKey() != nil {
+		return &key.PrivateKey().PublicKey
+	}
+	return nil
+}
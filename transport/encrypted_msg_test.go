@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+func generateKey(t *testing.T) *security.EcdsaKey {
+	key := security.NewEcdsaKey()
+	require.NoError(t, key.Generate())
+	return key
+}
+
+func TestEncryptMessage(t *testing.T) {
+	sender, recipient := generateKey(t), generateKey(t)
+
+	// peers know only public keys of each other
+	senderPub, recipientPub := security.NewEcdsaKey(), security.NewEcdsaKey()
+	_, pub := sender.ExportX509EncodedKeys()
+	require.NoError(t, senderPub.ImportX509PublicKey(pub))
+	_, pub = recipient.ExportX509EncodedKeys()
+	require.NoError(t, recipientPub.ImportX509PublicKey(pub))
+
+	for _, enc := range []string{"", EncA256GCM, EncC20P} {
+		msg := fimpgo.NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)
+		encrypted, err := EncryptMessage(msg, nil, sender, recipientPub, enc, &fimpgo.Props{"user_id": "alex@gmail.com"})
+		require.NoError(t, err)
+		assert.Equal(t, "cmd.transport.encrypted", encrypted.Type)
+		assert.Equal(t, "out_bin_switch", encrypted.Service)
+		assert.Equal(t, "alex@gmail.com", encrypted.Properties["user_id"])
+		assert.Equal(t, sender.KeyId(), encrypted.Properties[PropSenderKeyId])
+		assert.Equal(t, recipientPub.KeyId(), encrypted.Properties[PropRecipientKeyId])
+
+		data, err := encrypted.SerializeToJson()
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "cmd.binary.set")
+		received, err := fimpgo.NewMessageFromBytes(data)
+		require.NoError(t, err)
+
+		decrypted, err := DecryptMessage(received, recipient, senderPub)
+		require.NoError(t, err)
+		assert.Equal(t, "cmd.binary.set", decrypted.Type)
+		assert.Equal(t, true, decrypted.Value)
+	}
+}
+
+func TestDecryptMessage_Rejected(t *testing.T) {
+	sender, recipient, other := generateKey(t), generateKey(t), generateKey(t)
+	encrypt := func() *fimpgo.FimpMessage {
+		encrypted, err := EncryptMessage(fimpgo.NewFloatMessage("evt.sensor.report", "sensor_temp", 21.5, nil, nil, nil), nil, sender, recipient, EncC20P, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "evt.transport.encrypted", encrypted.Type)
+		return encrypted
+	}
+
+	_, err := DecryptMessage(encrypt(), other, sender)
+	assert.Error(t, err, "wrong recipient")
+	_, err = DecryptMessage(encrypt(), recipient, other)
+	assert.Error(t, err, "wrong sender")
+
+	msg := encrypt()
+	msg.Service = "sensor_humid"
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "altered service")
+
+	msg = encrypt()
+	msg.Properties[PropEncryption] = EncA256GCM
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "altered algorithm")
+
+	msg = encrypt()
+	msg.Value = msg.Value.(string)[:20]
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "truncated ciphertext")
+
+	msg = encrypt()
+	msg.Properties[PropEphemeralKey] = encrypt().Properties[PropEphemeralKey]
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "replaced ephemeral key")
+
+	msg, err = EncryptMessage(fimpgo.NewNullMessage("evt.test.report", "test", nil, nil, nil), nil, sender, recipient, "", &fimpgo.Props{"user_id": "alex@gmail.com"})
+	require.NoError(t, err)
+	msg.Properties["user_id"] = "mallory@gmail.com"
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "altered user id")
+
+	msg, err = EncryptMessage(fimpgo.NewNullMessage("evt.test.report", "test", nil, nil, nil), nil, sender, recipient, "", nil)
+	require.NoError(t, err)
+	msg.Properties["user_id"] = "mallory@gmail.com"
+	_, err = DecryptMessage(msg, recipient, sender)
+	assert.Error(t, err, "added property")
+
+	_, err = EncryptMessage(fimpgo.NewNullMessage("evt.test.report", "test", nil, nil, nil), nil, sender, recipient, "A128CBC", nil)
+	assert.Error(t, err)
+}
+
+func TestEncryptMessage_EphemeralKey(t *testing.T) {
+	sender, recipient := generateKey(t), generateKey(t)
+	msg := fimpgo.NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)
+
+	first, err := EncryptMessage(msg, nil, sender, recipient, "", nil)
+	require.NoError(t, err)
+	second, err := EncryptMessage(msg, nil, sender, recipient, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "fimp-ECDH-1PU+HKDF-SHA256", first.Properties[PropEncryptionAlg])
+	assert.NotEmpty(t, first.Properties[PropEphemeralKey])
+	assert.NotEqual(t, first.Properties[PropEphemeralKey], second.Properties[PropEphemeralKey], "every message must use new ephemeral key")
+}
+
+func TestEncryptMessage_MissingKeys(t *testing.T) {
+	sender, recipient := generateKey(t), generateKey(t)
+	msg := fimpgo.NewBoolMessage("cmd.binary.set", "out_bin_switch", true, nil, nil, nil)
+
+	_, err := EncryptMessage(msg, nil, nil, recipient, "", nil)
+	assert.Error(t, err)
+	_, err = EncryptMessage(msg, nil, sender, nil, "", nil)
+	assert.Error(t, err)
+	_, err = EncryptMessage(msg, nil, security.NewEcdsaKey(), recipient, "", nil)
+	assert.Error(t, err)
+
+	encrypted, err := EncryptMessage(msg, nil, sender, recipient, "", nil)
+	require.NoError(t, err)
+	_, err = DecryptMessage(encrypted, nil, sender)
+	assert.Error(t, err)
+	_, err = DecryptMessage(encrypted, recipient, nil)
+	assert.Error(t, err)
+}
+
+func TestEcdsaKey_KeyId(t *testing.T) {
+	key := generateKey(t)
+	private, pub := key.ExportJsonEncodedKeys()
+
+	privateOnly := security.NewEcdsaKey()
+	require.NoError(t, privateOnly.ImportJsonPrivateKey(private))
+	publicOnly := security.NewEcdsaKey()
+	require.NoError(t, publicOnly.ImportJsonPublicKey(pub))
+
+	assert.NotEmpty(t, key.KeyId())
+	assert.Equal(t, key.KeyId(), privateOnly.KeyId())
+	assert.Equal(t, key.KeyId(), publicOnly.KeyId())
+	assert.NotEqual(t, key.KeyId(), generateKey(t).KeyId())
+	assert.Empty(t, security.NewEcdsaKey().KeyId())
+}
@@ -0,0 +1,186 @@
+package transport
+
+import (
+	"container/heap"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+// Properties of the signed message , which protect it from replay.
+const (
+	PropNonce     = "sig_nonce"
+	PropTimestamp = "sig_ts" // unix time in milliseconds
+)
+
+const (
+	DefaultMaxClockSkew    = 5 * time.Minute
+	DefaultReplayCacheSize = 10000
+)
+
+var (
+	errBadSignature = errors.New("bad signature")
+	errExpired      = errors.New("message expired")
+	errReplayed     = errors.New("message replayed")
+	errCacheFull    = errors.New("replay cache full")
+)
+
+// IsBadSignature returns true if message signature is missing or invalid.
+func IsBadSignature(err error) bool {
+	return errors.Is(err, errBadSignature)
+}
+
+// IsExpired returns true if timestamp of signed message is missing or outside of allowed clock skew.
+func IsExpired(err error) bool {
+	return errors.Is(err, errExpired)
+}
+
+// IsReplayed returns true if signed message has been already accepted.
+func IsReplayed(err error) bool {
+	return errors.Is(err, errReplayed)
+}
+
+// IsReplayCacheFull returns true if signed message was rejected , because replay cache is full of nonces , which haven't expired yet.
+func IsReplayCacheFull(err error) bool {
+	return errors.Is(err, errCacheFull)
+}
+
+// addReplayProtection returns copy of the message with random nonce and current timestamp in properties.
+func addReplayProtection(msg *fimpgo.FimpMessage, now time.Time) (*fimpgo.FimpMessage, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	protected := *msg
+	protected.Properties = fimpgo.Props{}
+	for k, v := range msg.Properties {
+		protected.Properties[k] = v
+	}
+	protected.Properties[PropNonce] = base64.RawURLEncoding.EncodeToString(nonce)
+	protected.Properties[PropTimestamp] = strconv.FormatInt(now.UnixMilli(), 10)
+	return &protected, nil
+}
+
+// ReplayGuard rejects signed messages , which are too old or have been already accepted.
+// Accepted nonces are kept until they are outside of clock skew window. If cache is full of nonces , which haven't expired yet ,
+// new messages are rejected until the oldest nonce expires.
+type ReplayGuard struct {
+	maxSkew   time.Duration
+	cacheSize int
+	now       func() time.Time
+
+	mux    sync.Mutex
+	seen   map[string]struct{}
+	expiry nonceHeap // seenNonce ordered by expiration time
+}
+
+type seenNonce struct {
+	nonce     string
+	expiresAt time.Time
+}
+
+// nonceHeap is min-heap of nonces by expiration time. Expiration comes from sender timestamp , so it doesn't follow acceptance order.
+type nonceHeap []seenNonce
+
+func (h nonceHeap) Len() int           { return len(h) }
+func (h nonceHeap) Less(i, j int) bool { return h[i].expiresAt.Before(h[j].expiresAt) }
+func (h nonceHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *nonceHeap) Push(x interface{}) {
+	*h = append(*h, x.(seenNonce))
+}
+
+func (h *nonceHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// NewReplayGuard creates guard. Zero values mean DefaultMaxClockSkew and DefaultReplayCacheSize.
+func NewReplayGuard(maxSkew time.Duration, cacheSize int) *ReplayGuard {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+	if cacheSize <= 0 {
+		cacheSize = DefaultReplayCacheSize
+	}
+	return &ReplayGuard{
+		maxSkew:   maxSkew,
+		cacheSize: cacheSize,
+		now:       time.Now,
+		seen:      make(map[string]struct{}),
+	}
+}
+
+// Check validates timestamp and nonce of verified inner message and remembers the nonce.
+func (g *ReplayGuard) Check(msg *fimpgo.FimpMessage) error {
+	nonce := msg.Properties[PropNonce]
+	if nonce == "" {
+		return fmt.Errorf("%w: missing nonce", errReplayed)
+	}
+	tsMillis, err := strconv.ParseInt(msg.Properties[PropTimestamp], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or invalid timestamp", errExpired)
+	}
+
+	now := g.now()
+	ts := time.UnixMilli(tsMillis)
+	if skew := now.Sub(ts); skew > g.maxSkew || skew < -g.maxSkew {
+		return fmt.Errorf("%w: timestamp %s is outside of allowed clock skew %s", errExpired, ts.Format(time.RFC3339), g.maxSkew)
+	}
+
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.evict(now)
+	if _, ok := g.seen[nonce]; ok {
+		return fmt.Errorf("%w: nonce %s", errReplayed, nonce)
+	}
+	// live nonce can't be forgotten , otherwise the message could be replayed
+	if len(g.expiry) >= g.cacheSize {
+		return fmt.Errorf("%w: %d nonces , the oldest expires at %s", errCacheFull, len(g.expiry), g.expiry[0].expiresAt.Format(time.RFC3339))
+	}
+	// the message is rejected by timestamp check after the nonce expires
+	g.seen[nonce] = struct{}{}
+	heap.Push(&g.expiry, seenNonce{nonce: nonce, expiresAt: ts.Add(g.maxSkew)})
+	return nil
+}
+
+// evict removes expired nonces.
+func (g *ReplayGuard) evict(now time.Time) {
+	for len(g.expiry) > 0 && !g.expiry[0].expiresAt.After(now) {
+		entry := heap.Pop(&g.expiry).(seenNonce)
+		delete(g.seen, entry.nonce)
+	}
+}
+
+// GetVerifiedMessageES256 verifies signature of the message and rejects expired and replayed messages.
+func (g *ReplayGuard) GetVerifiedMessageES256(signedMsg *fimpgo.FimpMessage, key *security.EcdsaKey) (*fimpgo.FimpMessage, error) {
+	msg, err := GetVerifiedMessageES256(signedMsg, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Check(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// VerifyMessage verifies signature of the message using the key record and rejects expired and replayed messages.
+func (g *ReplayGuard) VerifyMessage(signedMsg *fimpgo.FimpMessage, key *security.KeyRecord) (*fimpgo.FimpMessage, error) {
+	msg, err := VerifyMessage(signedMsg, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Check(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
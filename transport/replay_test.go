@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+)
+
+func TestReplayGuard_GetVerifiedMessageES256(t *testing.T) {
+	keys := generateKey(t)
+	guard := NewReplayGuard(time.Minute, 0)
+
+	msg := fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", false, fimpgo.Props{"timeout": "5"}, nil, nil)
+	signed, err := SignMessageES256(msg, nil, "alex@gmail.com", keys, nil)
+	require.NoError(t, err)
+	assert.NotContains(t, msg.Properties, PropNonce, "original message must not be modified")
+
+	inner, err := guard.GetVerifiedMessageES256(signed, keys)
+	require.NoError(t, err)
+	assert.Equal(t, "cmd.lock.set", inner.Type)
+	assert.Equal(t, "5", inner.Properties["timeout"])
+	assert.NotEmpty(t, inner.Properties[PropNonce])
+
+	_, err = guard.GetVerifiedMessageES256(signed, keys)
+	assert.True(t, IsReplayed(err))
+
+	// signature covers nonce and timestamp
+	tampered, err := SignMessageES256(msg, nil, "alex@gmail.com", keys, nil)
+	require.NoError(t, err)
+	tampered.Value = signed.Value
+	_, err = guard.GetVerifiedMessageES256(tampered, keys)
+	assert.True(t, IsBadSignature(err))
+
+	delete(tampered.Properties, "sig")
+	_, err = guard.GetVerifiedMessageES256(tampered, keys)
+	assert.True(t, IsBadSignature(err))
+
+	// guard is per receiver , other receivers accept the message once as well
+	_, err = NewReplayGuard(time.Minute, 0).GetVerifiedMessageES256(signed, keys)
+	assert.NoError(t, err)
+}
+
+func TestReplayGuard_Check(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	guard := NewReplayGuard(time.Minute, 2)
+	guard.now = func() time.Time { return now }
+
+	message := func(nonce string, ts time.Time) *fimpgo.FimpMessage {
+		return fimpgo.NewNullMessage("cmd.test.set", "test", fimpgo.Props{PropNonce: nonce, PropTimestamp: strconv.FormatInt(ts.UnixMilli(), 10)}, nil, nil)
+	}
+
+	assert.NoError(t, guard.Check(message("a", now.Add(-30*time.Second))))
+	assert.True(t, IsReplayed(guard.Check(message("a", now.Add(-30*time.Second)))))
+	assert.True(t, IsExpired(guard.Check(message("b", now.Add(-2*time.Minute)))))
+	assert.True(t, IsExpired(guard.Check(message("b", now.Add(2*time.Minute)))))
+	assert.True(t, IsExpired(guard.Check(fimpgo.NewNullMessage("cmd.test.set", "test", fimpgo.Props{PropNonce: "b"}, nil, nil))))
+	assert.True(t, IsReplayed(guard.Check(fimpgo.NewNullMessage("cmd.test.set", "test", nil, nil, nil))))
+
+	// expired nonces are evicted , replay is then rejected by timestamp
+	now = now.Add(45 * time.Second)
+	assert.NoError(t, guard.Check(message("b", now)))
+	assert.Equal(t, 1, guard.expiry.Len())
+	assert.True(t, IsExpired(guard.Check(message("a", now.Add(-75*time.Second)))))
+
+	// cache is bounded , live nonces are never evicted
+	assert.NoError(t, guard.Check(message("c", now)))
+	assert.True(t, IsReplayCacheFull(guard.Check(message("d", now))))
+	assert.Equal(t, 2, guard.expiry.Len())
+	assert.True(t, IsReplayed(guard.Check(message("b", now))))
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, guard.Check(message("d", now)))
+}
+
+func TestReplayGuard_EvictByExpiration(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	guard := NewReplayGuard(time.Minute, 2)
+	guard.now = func() time.Time { return now }
+
+	message := func(nonce string, ts time.Time) *fimpgo.FimpMessage {
+		return fimpgo.NewNullMessage("cmd.test.set", "test", fimpgo.Props{PropNonce: nonce, PropTimestamp: strconv.FormatInt(ts.UnixMilli(), 10)}, nil, nil)
+	}
+
+	// "a" is accepted first , but expires later than "b"
+	assert.NoError(t, guard.Check(message("a", now.Add(30*time.Second))))
+	assert.NoError(t, guard.Check(message("b", now.Add(-30*time.Second))))
+
+	// "b" expires , "a" must be still remembered
+	now = now.Add(45 * time.Second)
+	assert.NoError(t, guard.Check(message("c", now)))
+	assert.True(t, IsReplayed(guard.Check(message("a", now.Add(-15*time.Second)))))
+	assert.ElementsMatch(t, []string{"a", "c"}, []string{guard.expiry[0].nonce, guard.expiry[1].nonce})
+}
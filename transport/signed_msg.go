@@ -4,7 +4,7 @@ import (
 	"crypto"
 	"encoding/base64"
 	"errors"
-	"strings"
+	"fmt"
 
 	"github.com/golang-jwt/jwt"
 
@@ -63,26 +63,23 @@ https://tools.ietf.org/html/rfc7518#section-3
 //}
 
 // SignMessageES256 encapsulate original message into special transport message with added signature.
+// Nonce and timestamp are added to properties of the original message , so receiver can reject replayed messages using ReplayGuard.
 func SignMessageES256(payload *fimpgo.FimpMessage, requestMsg *fimpgo.FimpMessage, userId string, keys *security.EcdsaKey, props *fimpgo.Props) (*fimpgo.FimpMessage, error) {
-	serializedMsg, err := payload.SerializeToJson()
-	if err != nil {
-		return nil, err
-	}
 	if props == nil {
 		props = &fimpgo.Props{"user_id": userId}
 	}
-	msgType := "evt.transport.signed"
-	if strings.Contains(payload.Type, "cmd") {
-		msgType = "cmd.transport.signed"
+	signedMsg, err := newSignedMessage(payload, requestMsg, *props)
+	if err != nil {
+		return nil, err
 	}
-	signedMsg := fimpgo.NewBinaryMessage(msgType, payload.Service, serializedMsg, *props, nil, requestMsg)
+	signedMsg.Properties[PropSignatureAlg] = security.AlgEcdsa256
 
 	signingMethodES256 := &jwt.SigningMethodECDSA{Name: "ES256", Hash: crypto.SHA256, KeySize: 32, CurveBits: 256}
 	signature, err := signingMethodES256.Sign(signedMsg.Value.(string), keys.PrivateKey())
 	if err != nil {
 		return nil, err
 	}
-	signedMsg.Properties["sig"] = signature
+	signedMsg.Properties[PropSignature] = signature
 	return signedMsg, nil
 }
 
@@ -97,7 +94,7 @@ func GetVerifiedMessageES256(signedMsg *fimpgo.FimpMessage, key *security.EcdsaK
 	}
 	sig, ok2 := signedMsg.Properties["sig"]
 	if !ok2 {
-		return nil, errors.New("missing signature")
+		return nil, fmt.Errorf("%w: missing signature", errBadSignature)
 	}
 	signingMethodES256 := &jwt.SigningMethodECDSA{Name: "ES256", Hash: crypto.SHA256, KeySize: 32, CurveBits: 256}
 	err := signingMethodES256.Verify(origMsgBin, sig, key.PublicKey())
@@ -108,6 +105,6 @@ func GetVerifiedMessageES256(signedMsg *fimpgo.FimpMessage, key *security.EcdsaK
 		}
 		return fimpgo.NewMessageFromBytes(decodedPayloadBin)
 	} else {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", errBadSignature, err)
 	}
 }
@@ -0,0 +1,132 @@
+package transport
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+// Properties of signed message.
+const (
+	PropSignature    = "sig"
+	PropSignatureAlg = "alg"
+)
+
+// signingMethod returns JWS signing method of the algorithm. none is never supported.
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case security.AlgEcdsa256:
+		return jwt.SigningMethodES256, nil
+	case security.AlgEcdsa384:
+		return jwt.SigningMethodES384, nil
+	case security.AlgEd25519:
+		return jwt.SigningMethodEdDSA, nil
+	case security.AlgHmac256:
+		return jwt.SigningMethodHS256, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", alg)
+}
+
+// SignMessage encapsulates original message into signed transport message. Algorithm is taken from the key record and written into alg property.
+// Nonce and timestamp are added to properties of the original message , so receiver can reject replayed messages using ReplayGuard.
+func SignMessage(payload *fimpgo.FimpMessage, requestMsg *fimpgo.FimpMessage, userId string, key *security.KeyRecord, props *fimpgo.Props) (*fimpgo.FimpMessage, error) {
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := key.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	signedProps := fimpgo.Props{}
+	if props != nil {
+		for k, v := range *props {
+			signedProps[k] = v
+		}
+	}
+	if _, ok := signedProps["user_id"]; !ok && userId != "" {
+		signedProps["user_id"] = userId
+	}
+	signedProps[PropSignatureAlg] = key.Algorithm
+	signedMsg, err := newSignedMessage(payload, requestMsg, signedProps)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := method.Sign(signedMsg.Value.(string), signingKey)
+	if err != nil {
+		return nil, err
+	}
+	signedMsg.Properties[PropSignature] = signature
+	return signedMsg, nil
+}
+
+// VerifyMessage verifies signature of the message and returns original message.
+// alg property must match algorithm of the key record , messages without alg property are accepted only by ES256 keys.
+func VerifyMessage(signedMsg *fimpgo.FimpMessage, key *security.KeyRecord) (*fimpgo.FimpMessage, error) {
+	if signedMsg.Type != "cmd.transport.signed" && signedMsg.Type != "evt.transport.signed" {
+		return nil, errors.New("incorrect message type")
+	}
+	origMsgBin, ok := signedMsg.Value.(string)
+	if !ok {
+		return nil, errors.New("incorrect encapsulated message format")
+	}
+	sig, ok := signedMsg.Properties[PropSignature]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing signature", errBadSignature)
+	}
+
+	alg, ok := signedMsg.Properties[PropSignatureAlg]
+	if !ok {
+		// messages signed by SignMessageES256 before alg was added
+		alg = security.AlgEcdsa256
+	}
+	if strings.EqualFold(alg, security.AlgNone) {
+		return nil, fmt.Errorf("%w: unsigned message", errBadSignature)
+	}
+	if alg != key.Algorithm {
+		return nil, fmt.Errorf("%w: algorithm %s doesn't match key algorithm %s", errBadSignature, alg, key.Algorithm)
+	}
+	method, err := signingMethod(alg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadSignature, err)
+	}
+	verificationKey, err := key.VerificationKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := method.Verify(origMsgBin, sig, verificationKey); err != nil {
+		return nil, fmt.Errorf("%w: %w", errBadSignature, err)
+	}
+
+	decodedPayloadBin, err := base64.StdEncoding.DecodeString(origMsgBin)
+	if err != nil {
+		return nil, err
+	}
+	return fimpgo.NewMessageFromBytes(decodedPayloadBin)
+}
+
+// newSignedMessage encapsulates replay protected copy of the message into evt.transport.signed or cmd.transport.signed message.
+func newSignedMessage(payload *fimpgo.FimpMessage, requestMsg *fimpgo.FimpMessage, props fimpgo.Props) (*fimpgo.FimpMessage, error) {
+	protected, err := addReplayProtection(payload, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	serializedMsg, err := protected.SerializeToJson()
+	if err != nil {
+		return nil, err
+	}
+	msgType := "evt.transport.signed"
+	if strings.Contains(payload.Type, "cmd") {
+		msgType = "cmd.transport.signed"
+	}
+	return fimpgo.NewBinaryMessage(msgType, payload.Service, serializedMsg, props, nil, requestMsg), nil
+}
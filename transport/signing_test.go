@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+// generateKeyRecords returns private and public key records of the algorithm.
+func generateKeyRecords(t *testing.T, alg string) (*security.KeyRecord, *security.KeyRecord) {
+	var private, public interface{}
+	switch alg {
+	case security.AlgEcdsa256, security.AlgEcdsa384:
+		curve := elliptic.P256()
+		if alg == security.AlgEcdsa384 {
+			curve = elliptic.P384()
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		require.NoError(t, err)
+		private, public = key, &key.PublicKey
+	case security.AlgEd25519:
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		private, public = key, pub
+	case security.AlgHmac256:
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		require.NoError(t, err)
+		record := &security.KeyRecord{UserId: "alex@gmail.com", Algorithm: alg, KeyType: security.KeyTypeSymmetric, SerializedKey: base64.StdEncoding.EncodeToString(secret)}
+		return record, record
+	}
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	return &security.KeyRecord{UserId: "alex@gmail.com", Algorithm: alg, KeyType: security.KeyTypePrivate, SerializedKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}))},
+		&security.KeyRecord{UserId: "alex@gmail.com", Algorithm: alg, KeyType: security.KeyTypePublic, SerializedKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}))}
+}
+
+func TestSignMessage(t *testing.T) {
+	for _, alg := range []string{security.AlgEcdsa256, security.AlgEcdsa384, security.AlgEd25519, security.AlgHmac256} {
+		private, public := generateKeyRecords(t, alg)
+		msg := fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil)
+
+		signed, err := SignMessage(msg, nil, "alex@gmail.com", private, &fimpgo.Props{"device_id": "phone"})
+		require.NoError(t, err, alg)
+		assert.Equal(t, "cmd.transport.signed", signed.Type)
+		assert.Equal(t, alg, signed.Properties[PropSignatureAlg])
+		assert.Equal(t, "alex@gmail.com", signed.Properties["user_id"])
+		assert.Equal(t, "phone", signed.Properties["device_id"])
+
+		inner, err := VerifyMessage(signed, public)
+		require.NoError(t, err, alg)
+		assert.Equal(t, "cmd.lock.set", inner.Type)
+
+		// private key record can verify its own signatures
+		_, err = VerifyMessage(signed, private)
+		assert.NoError(t, err, alg)
+
+		_, other := generateKeyRecords(t, alg)
+		_, err = VerifyMessage(signed, other)
+		assert.True(t, IsBadSignature(err), alg)
+	}
+}
+
+func TestVerifyMessage_Algorithm(t *testing.T) {
+	private, public := generateKeyRecords(t, security.AlgEcdsa256)
+	signed, err := SignMessage(fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil), nil, "alex@gmail.com", private, nil)
+	require.NoError(t, err)
+
+	for _, alg := range []string{"none", "None", security.AlgHmac256, security.AlgEcdsa384} {
+		signed.Properties[PropSignatureAlg] = alg
+		_, err = VerifyMessage(signed, public)
+		assert.True(t, IsBadSignature(err), alg)
+	}
+
+	// messages without alg are verified as ES256
+	delete(signed.Properties, PropSignatureAlg)
+	_, err = VerifyMessage(signed, public)
+	assert.NoError(t, err)
+	_, ed25519Public := generateKeyRecords(t, security.AlgEd25519)
+	_, err = VerifyMessage(signed, ed25519Public)
+	assert.True(t, IsBadSignature(err))
+
+	// legacy signatures are compatible
+	ecKey := security.NewEcdsaKey()
+	require.NoError(t, ecKey.Generate())
+	legacy, err := SignMessageES256(fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil), nil, "alex@gmail.com", ecKey, nil)
+	require.NoError(t, err)
+	_, err = VerifyMessage(legacy, &security.KeyRecord{Algorithm: security.AlgEcdsa256, KeyType: security.KeyTypePublic, EcdsaKey: ecKey})
+	assert.NoError(t, err)
+}
+
+func TestSignMessage_InvalidKey(t *testing.T) {
+	msg := fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil)
+	_, public := generateKeyRecords(t, security.AlgEd25519)
+	_, err := SignMessage(msg, nil, "", public, nil)
+	assert.Error(t, err, "public key can't sign")
+
+	private, _ := generateKeyRecords(t, security.AlgEcdsa384)
+	private.Algorithm = security.AlgEcdsa256
+	_, err = SignMessage(msg, nil, "", private, nil)
+	assert.Error(t, err, "curve mismatch")
+
+	_, err = SignMessage(msg, nil, "", &security.KeyRecord{Algorithm: security.AlgNone, KeyType: security.KeyTypeSymmetric}, nil)
+	assert.Error(t, err)
+
+	secret, _ := generateKeyRecords(t, security.AlgHmac256)
+	secret.KeyType = security.KeyTypePrivate
+	_, err = SignMessage(msg, nil, "", secret, nil)
+	assert.Error(t, err, "HS256 requires symmetric key")
+}
@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"fmt"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+// KeyStoreVerifier verifies signed messages using keys of the key store. It implements fimpgo.SignatureVerifier ,
+// so it can be passed to fimpgo.WithSignatureVerifier to unwrap signed messages on receive.
+type KeyStoreVerifier struct {
+	keyStore *security.KeyStore
+	guard    *ReplayGuard
+}
+
+// NewKeyStoreVerifier creates verifier. Key is looked up by user_id and device_id properties of the signed message.
+// Expired and replayed messages are rejected by the guard , nil means guard with default settings.
+func NewKeyStoreVerifier(keyStore *security.KeyStore, guard *ReplayGuard) *KeyStoreVerifier {
+	if guard == nil {
+		guard = NewReplayGuard(0, 0)
+	}
+	return &KeyStoreVerifier{keyStore: keyStore, guard: guard}
+}
+
+// VerifySignedMessage verifies signature of the message and returns original message and identity of the signer.
+func (v *KeyStoreVerifier) VerifySignedMessage(signedMsg *fimpgo.FimpMessage) (*fimpgo.FimpMessage, *fimpgo.Authentication, error) {
+	userId := signedMsg.Properties["user_id"]
+	deviceId := signedMsg.Properties["device_id"]
+	alg, ok := signedMsg.Properties[PropSignatureAlg]
+	if !ok {
+		alg = security.AlgEcdsa256
+	}
+
+	key := v.keyStore.GetVerificationKey(userId, deviceId, alg)
+	if key == nil {
+		return nil, nil, fmt.Errorf("%w: no %s key for user=%s device=%s", errBadSignature, alg, userId, deviceId)
+	}
+	msg, err := v.guard.VerifyMessage(signedMsg, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, &fimpgo.Authentication{UserId: userId, DeviceId: deviceId, Algorithm: alg}, nil
+}
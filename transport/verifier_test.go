@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/futurehomeno/fimpgo"
+	"github.com/futurehomeno/fimpgo/security"
+)
+
+func TestKeyStoreVerifier_VerifySignedMessage(t *testing.T) {
+	keyStore := security.NewKeyStore(filepath.Join(t.TempDir(), "key-store.json"), true)
+	edPrivate, edPublic := generateKeyRecords(t, security.AlgEd25519)
+	require.NoError(t, keyStore.AddSerializedKey("alex@gmail.com", "phone", edPublic.SerializedKey, edPublic.KeyType, edPublic.Algorithm))
+	ecKey := generateKey(t)
+	_, ecPublic := ecKey.ExportX509EncodedKeys()
+	require.NoError(t, keyStore.AddSerializedKey("alex@gmail.com", "tablet", ecPublic, security.KeyTypePublic, security.AlgEcdsa256))
+
+	verifier := NewKeyStoreVerifier(keyStore, nil)
+	var _ fimpgo.SignatureVerifier = verifier
+	msg := fimpgo.NewBoolMessage("cmd.lock.set", "door_lock", true, nil, nil, nil)
+
+	signed, err := SignMessage(msg, nil, "alex@gmail.com", edPrivate, &fimpgo.Props{"device_id": "phone"})
+	require.NoError(t, err)
+	inner, auth, err := verifier.VerifySignedMessage(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "cmd.lock.set", inner.Type)
+	assert.Equal(t, &fimpgo.Authentication{UserId: "alex@gmail.com", DeviceId: "phone", Algorithm: security.AlgEd25519}, auth)
+
+	_, _, err = verifier.VerifySignedMessage(signed)
+	assert.True(t, IsReplayed(err))
+
+	// legacy messages without alg are verified with ES256 key
+	legacy, err := SignMessageES256(msg, nil, "alex@gmail.com", ecKey, &fimpgo.Props{"user_id": "alex@gmail.com", "device_id": "tablet"})
+	require.NoError(t, err)
+	delete(legacy.Properties, PropSignatureAlg)
+	_, auth, err = verifier.VerifySignedMessage(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, security.AlgEcdsa256, auth.Algorithm)
+
+	// key is bound to user and device
+	signed, err = SignMessage(msg, nil, "alex@gmail.com", edPrivate, &fimpgo.Props{"device_id": "tablet"})
+	require.NoError(t, err)
+	_, _, err = verifier.VerifySignedMessage(signed)
+	assert.True(t, IsBadSignature(err))
+
+	signed, err = SignMessage(msg, nil, "eve@gmail.com", edPrivate, &fimpgo.Props{"device_id": "phone"})
+	require.NoError(t, err)
+	_, _, err = verifier.VerifySignedMessage(signed)
+	assert.True(t, IsBadSignature(err))
+}